@@ -0,0 +1,308 @@
+package gostorage
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"sort"
+	"time"
+)
+
+// ArchiveFormat is the container format of an archive
+type ArchiveFormat string
+
+const (
+	// ArchiveZip is a zip archive with deflate compression
+	ArchiveZip ArchiveFormat = "zip"
+
+	// ArchiveTar is an uncompressed tar archive
+	ArchiveTar ArchiveFormat = "tar"
+
+	// ArchiveTarGz is a gzip-compressed tar archive
+	ArchiveTarGz ArchiveFormat = "tar.gz"
+)
+
+// contentType returns the MIME type of the format
+func (f ArchiveFormat) contentType() string {
+	switch f {
+	case ArchiveZip:
+		return "application/zip"
+	case ArchiveTar:
+		return "application/x-tar"
+	default:
+		return "application/gzip"
+	}
+}
+
+// validateArchiveFormat checks that format is one of the supported formats
+func validateArchiveFormat(format ArchiveFormat) error {
+	switch format {
+	case ArchiveZip, ArchiveTar, ArchiveTarGz:
+		return nil
+	default:
+		return fmt.Errorf("unknown archive format %q", format)
+	}
+}
+
+// Archive streams every file below prefix into w as a zip, tar or tar.gz archive.
+// Entries are named by their path relative to prefix and keep their modification time.
+// Files are read one at a time and nothing is staged, an empty prefix archives the whole disk.
+func (s *Storage) Archive(ctx context.Context, disk string, prefix string, format ArchiveFormat, w io.Writer) error {
+	d := s.getDisk(disk)
+	if d == nil {
+		return ErrDiskNotFound(disk)
+	}
+
+	start := time.Now()
+	dir, files, err := archiveFiles(ctx, d, prefix, format)
+	if err == nil {
+		err = writeArchive(ctx, d, dir, files, format, w)
+	}
+	s.logOperation(ctx, "archive", disk, prefix, start, err)
+	return err
+}
+
+// archiveFiles lists the files below prefix, sorted by path
+func archiveFiles(ctx context.Context, d Disk, prefix string, format ArchiveFormat) (string, []FileInfo, error) {
+	if err := validateArchiveFormat(format); err != nil {
+		return "", nil, &PathError{Op: "archive", Path: prefix, Err: err}
+	}
+
+	dir, err := relativePrefix(prefix)
+	if err != nil {
+		return "", nil, &PathError{Op: "archive", Path: prefix, Err: err}
+	}
+
+	listed, err := listFiles(ctx, d, dir)
+	if err != nil {
+		return "", nil, err
+	}
+
+	files := make([]FileInfo, 0, len(listed))
+	for rel, info := range listed {
+		info.Path = rel
+		files = append(files, info)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Path < files[j].Path
+	})
+
+	return dir, files, nil
+}
+
+// writeArchive writes files, named relative to dir, into w
+func writeArchive(ctx context.Context, d Disk, dir string, files []FileInfo, format ArchiveFormat, w io.Writer) error {
+	if format == ArchiveZip {
+		zw := zip.NewWriter(w)
+		for _, file := range files {
+			entry, err := zw.CreateHeader(&zip.FileHeader{
+				Name:     file.Path,
+				Method:   zip.Deflate,
+				Modified: file.LastModified,
+			})
+			if err != nil {
+				return &PathError{Op: "archive", Path: file.Path, Err: err}
+			}
+			if err := copyArchiveEntry(ctx, d, joinPrefix(dir, file.Path), entry); err != nil {
+				return err
+			}
+		}
+		return zw.Close()
+	}
+
+	var gz *gzip.Writer
+	if format == ArchiveTarGz {
+		gz = gzip.NewWriter(w)
+		w = gz
+	}
+
+	tw := tar.NewWriter(w)
+	for _, file := range files {
+		full := joinPrefix(dir, file.Path)
+
+		// The size has to be known up front, a file that changes size fails the archive.
+		// Ask the disk rather than trusting the listing, wrappers store content in another size.
+		size, err := d.size(ctx, full)
+		if err != nil {
+			return err
+		}
+
+		err = tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     file.Path,
+			Mode:     0644,
+			Size:     size,
+			ModTime:  file.LastModified,
+			Format:   tar.FormatPAX,
+		})
+		if err != nil {
+			return &PathError{Op: "archive", Path: file.Path, Err: err}
+		}
+		if err := copyArchiveEntry(ctx, d, full, tw); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	if gz != nil {
+		return gz.Close()
+	}
+	return nil
+}
+
+// copyArchiveEntry streams a file into the current archive entry
+func copyArchiveEntry(ctx context.Context, d Disk, path string, w io.Writer) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	reader, err := d.getStream(ctx, path)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	if _, err := io.Copy(w, reader); err != nil {
+		return &PathError{Op: "archive", Path: path, Err: err}
+	}
+	return nil
+}
+
+// ArchiveHandlerConfig configures an ArchiveHandler
+type ArchiveHandlerConfig struct {
+	// Disk is the disk to download from (required)
+	Disk string
+
+	// Prefix returns the prefix to download for a request, an error responds with 400 Bad Request.
+	// Default: the required "prefix" query parameter, which cannot name the whole disk; scope the
+	// Storage to limit what can be downloaded.
+	Prefix func(r *http.Request) (string, error)
+
+	// Format is the archive format unless the request's "format" query parameter names another
+	// (default: ArchiveZip)
+	Format ArchiveFormat
+}
+
+// ArchiveHandler serves "download folder" requests by streaming a prefix as an archive
+type ArchiveHandler struct {
+	storage *Storage
+	config  *ArchiveHandlerConfig
+}
+
+// NewArchiveHandler creates an http.Handler that streams a prefix of a disk as an archive
+func NewArchiveHandler(storage *Storage, cfg *ArchiveHandlerConfig) (*ArchiveHandler, error) {
+	if storage == nil {
+		return nil, fmt.Errorf("storage is required")
+	}
+	if cfg == nil || cfg.Disk == "" {
+		return nil, fmt.Errorf("disk is required")
+	}
+
+	config := *cfg
+	if config.Format == "" {
+		config.Format = ArchiveZip
+	}
+	if err := validateArchiveFormat(config.Format); err != nil {
+		return nil, err
+	}
+	if config.Prefix == nil {
+		config.Prefix = func(r *http.Request) (string, error) {
+			prefix := r.URL.Query().Get("prefix")
+			// ".", "/" and "./" name the whole disk as well
+			if dir, err := relativePrefix(prefix); err == nil && dir == "" {
+				return "", fmt.Errorf("prefix is required")
+			}
+			return prefix, nil
+		}
+	}
+
+	return &ArchiveHandler{storage: storage, config: &config}, nil
+}
+
+// ServeHTTP lists the prefix, then streams the archive as an attachment.
+// Errors found while listing get an error status, a failure while streaming aborts the
+// response so the client does not mistake a truncated archive for a complete one.
+func (h *ArchiveHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	format := h.config.Format
+	if f := r.URL.Query().Get("format"); f != "" {
+		format = ArchiveFormat(f)
+	}
+
+	prefix, err := h.config.Prefix(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	d := h.storage.getDisk(h.config.Disk)
+	if d == nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	ctx := r.Context()
+	start := time.Now()
+	dir, files, err := archiveFiles(ctx, d, prefix, format)
+	if err != nil {
+		h.storage.logOperation(ctx, "archive", h.config.Disk, prefix, start, err)
+		http.Error(w, http.StatusText(archiveStatus(err)), archiveStatus(err))
+		return
+	}
+	if len(files) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", format.contentType())
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": archiveName(dir, h.config.Disk) + "." + string(format),
+	}))
+	if r.Method == http.MethodHead {
+		return
+	}
+
+	err = writeArchive(ctx, d, dir, files, format, w)
+	h.storage.logOperation(ctx, "archive", h.config.Disk, prefix, start, err)
+	if err != nil {
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// archiveStatus maps an error found before streaming to an HTTP status
+func archiveStatus(err error) int {
+	var pathErr *PathError
+	switch {
+	case errors.Is(err, ErrFileNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrPermissionDenied):
+		return http.StatusForbidden
+	case errors.As(err, &pathErr) && pathErr.Op == "archive":
+		// Invalid prefixes and unknown formats
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// archiveName returns the download's file name, the last element of the prefix
+func archiveName(dir string, disk string) string {
+	if dir == "" {
+		return disk
+	}
+	return path.Base(dir)
+}
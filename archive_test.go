@@ -0,0 +1,196 @@
+package gostorage
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newArchiveTestStorage returns a Storage with a memory disk holding files below "projects/42"
+func newArchiveTestStorage(t *testing.T) *Storage {
+	storage := NewStorage()
+	storage.AddDisk("memory", NewMemoryDisk())
+
+	ctx := context.Background()
+	for _, path := range []string{"projects/42/a.txt", "projects/42/src/main.go", "projects/421/b.txt"} {
+		if err := storage.Put(ctx, "memory", path, []byte(path)); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+
+	return storage
+}
+
+// readTarGz returns the entries of a tar.gz archive by name
+func readTarGz(t *testing.T, data []byte) map[string]*tar.Header {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("gzip.NewReader failed: %v", err)
+	}
+
+	headers := make(map[string]*tar.Header)
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next failed: %v", err)
+		}
+
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatalf("ReadAll failed: %v", err)
+		}
+		if int64(len(content)) != header.Size {
+			t.Errorf("%s: expected %d bytes, got %d", header.Name, header.Size, len(content))
+		}
+		headers[header.Name] = header
+	}
+
+	return headers
+}
+
+func TestStorage_Archive(t *testing.T) {
+	storage := newArchiveTestStorage(t)
+	ctx := context.Background()
+
+	info, err := storage.Stat(ctx, "memory", "projects/42/src/main.go")
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := storage.Archive(ctx, "memory", "projects/42", ArchiveZip, &buf); err != nil {
+		t.Fatalf("Archive failed: %v", err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("zip.NewReader failed: %v", err)
+	}
+	if len(zr.File) != 2 || zr.File[0].Name != "a.txt" || zr.File[1].Name != "src/main.go" {
+		t.Fatalf("unexpected zip entries: %v", zr.File)
+	}
+
+	entry, err := zr.File[1].Open()
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	content, _ := io.ReadAll(entry)
+	entry.Close()
+	if string(content) != "projects/42/src/main.go" {
+		t.Errorf("unexpected content %q", content)
+	}
+	if !zr.File[1].Modified.Equal(info.LastModified.Truncate(time.Second)) {
+		t.Errorf("expected modification time %v, got %v", info.LastModified, zr.File[1].Modified)
+	}
+
+	buf.Reset()
+	if err := storage.Archive(ctx, "memory", "/projects/42/", ArchiveTarGz, &buf); err != nil {
+		t.Fatalf("Archive failed: %v", err)
+	}
+
+	headers := readTarGz(t, buf.Bytes())
+	if len(headers) != 2 || headers["src/main.go"] == nil {
+		t.Fatalf("unexpected tar entries: %v", headers)
+	}
+	if !headers["src/main.go"].ModTime.Equal(info.LastModified) {
+		t.Errorf("expected modification time %v, got %v", info.LastModified, headers["src/main.go"].ModTime)
+	}
+
+	if err := storage.Archive(ctx, "memory", "projects/42", "rar", io.Discard); err == nil {
+		t.Error("expected an unknown format to be rejected")
+	}
+	if err := storage.Archive(ctx, "memory", "../projects", ArchiveTar, io.Discard); err == nil {
+		t.Error("expected traversal to be rejected")
+	}
+}
+
+func TestStorage_Archive_WrappedDisk(t *testing.T) {
+	compressed, err := NewCompressedDisk(&CompressedDiskConfig{Disk: NewMemoryDisk()})
+	if err != nil {
+		t.Fatalf("Failed to create CompressedDisk: %v", err)
+	}
+
+	storage := NewStorage()
+	storage.AddDisk("compressed", compressed)
+
+	ctx := context.Background()
+	content := bytes.Repeat([]byte("compressible "), 1000)
+	if err := storage.Put(ctx, "compressed", "logs/app.log", content); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	// Tar headers need the size of the content, not the size stored below the wrapper
+	var buf bytes.Buffer
+	if err := storage.Archive(ctx, "compressed", "logs", ArchiveTarGz, &buf); err != nil {
+		t.Fatalf("Archive failed: %v", err)
+	}
+
+	headers := readTarGz(t, buf.Bytes())
+	if header := headers["app.log"]; header == nil || header.Size != int64(len(content)) {
+		t.Errorf("expected app.log with %d bytes, got %v", len(content), headers)
+	}
+}
+
+func TestArchiveHandler(t *testing.T) {
+	storage := newArchiveTestStorage(t)
+
+	handler, err := NewArchiveHandler(storage, &ArchiveHandlerConfig{Disk: "memory"})
+	if err != nil {
+		t.Fatalf("Failed to create ArchiveHandler: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/download?prefix=projects/42&format=tar.gz", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/gzip" {
+		t.Errorf("unexpected Content-Type %q", ct)
+	}
+	if cd := rec.Header().Get("Content-Disposition"); cd != `attachment; filename=42.tar.gz` {
+		t.Errorf("unexpected Content-Disposition %q", cd)
+	}
+	if headers := readTarGz(t, rec.Body.Bytes()); len(headers) != 2 {
+		t.Errorf("expected 2 entries, got %v", headers)
+	}
+
+	tests := []struct {
+		method string
+		target string
+		status int
+	}{
+		{http.MethodGet, "/download?prefix=projects/42", http.StatusOK},
+		{http.MethodHead, "/download?prefix=projects/42", http.StatusOK},
+		{http.MethodGet, "/download?prefix=projects/missing", http.StatusNotFound},
+		{http.MethodGet, "/download", http.StatusBadRequest},
+		{http.MethodGet, "/download?prefix=.", http.StatusBadRequest},
+		{http.MethodGet, "/download?prefix=/", http.StatusBadRequest},
+		{http.MethodGet, "/download?prefix=./", http.StatusBadRequest},
+		{http.MethodGet, "/download?prefix=../etc", http.StatusBadRequest},
+		{http.MethodGet, "/download?prefix=projects/42&format=rar", http.StatusBadRequest},
+		{http.MethodPost, "/download?prefix=projects/42", http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.target, nil))
+		if rec.Code != tt.status {
+			t.Errorf("%s %s: expected %d, got %d", tt.method, tt.target, tt.status, rec.Code)
+		}
+	}
+
+	if _, err := NewArchiveHandler(storage, &ArchiveHandlerConfig{}); err == nil {
+		t.Error("expected a missing disk to be rejected")
+	}
+}
@@ -0,0 +1,278 @@
+package gostorage
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultConcurrency is the number of files directory and batch operations work on at once
+const DefaultConcurrency = 10
+
+// BatchOption configures a directory or batch operation
+type BatchOption func(*batchOptions)
+
+// batchOptions holds the options of a directory or batch operation
+type batchOptions struct {
+	concurrency int
+}
+
+// WithConcurrency sets how many files are worked on at once (default: DefaultConcurrency)
+func WithConcurrency(n int) BatchOption {
+	return func(o *batchOptions) {
+		o.concurrency = n
+	}
+}
+
+// newBatchOptions applies opts to the defaults
+func newBatchOptions(opts []BatchOption) batchOptions {
+	options := batchOptions{concurrency: DefaultConcurrency}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.concurrency <= 0 {
+		options.concurrency = DefaultConcurrency
+	}
+	return options
+}
+
+// ObjectResult is the outcome for a single file of a directory or batch operation
+type ObjectResult struct {
+	// Path is the file operated on, the destination for copies and moves
+	Path string
+
+	// SourcePath is the file copied or moved from
+	SourcePath string
+
+	// Err is nil when the operation succeeded
+	Err error
+}
+
+// PutRequest is a single file written by PutMany
+type PutRequest struct {
+	Path    string
+	Content []byte
+
+	// Metadata is optional, nil writes the file like Put
+	Metadata *Metadata
+}
+
+// CopyRequest is a single file copied by CopyMany
+type CopyRequest struct {
+	SourcePath string
+	DestPath   string
+}
+
+// GetResult is the outcome for a single file of GetMany
+type GetResult struct {
+	Path    string
+	Content []byte
+
+	// Err is nil when the file was read
+	Err error
+}
+
+// batchDeleter is implemented by disks that can delete many files in a few requests
+type batchDeleter interface {
+	deleteMany(ctx context.Context, paths []string, concurrency int) []error
+}
+
+// deleteFiles deletes paths with the disk's batch delete if available, one by one otherwise.
+// The returned slice holds the error for each path.
+func deleteFiles(ctx context.Context, d Disk, paths []string, concurrency int) []error {
+	if bd, ok := d.(batchDeleter); ok {
+		return bd.deleteMany(ctx, paths, concurrency)
+	}
+
+	return runConcurrently(ctx, len(paths), concurrency, func(i int) error {
+		return d.delete(ctx, paths[i])
+	})
+}
+
+// runConcurrently calls fn for every index below n with at most concurrency calls at once.
+// Indexes not started before ctx is done get ctx's error.
+func runConcurrently(ctx context.Context, n int, concurrency int, fn func(i int) error) []error {
+	errs := make([]error, n)
+	sem := make(chan struct{}, concurrency)
+
+	var wg sync.WaitGroup
+	for i := range n {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			errs[i] = ctx.Err()
+			continue
+		}
+
+		wg.Go(func() {
+			defer func() { <-sem }()
+			errs[i] = fn(i)
+		})
+	}
+	wg.Wait()
+
+	return errs
+}
+
+// failures summarizes errs, nil when every file succeeded
+func failures(errs []error) error {
+	failed := 0
+	var first error
+	for _, err := range errs {
+		if err != nil {
+			if first == nil {
+				first = err
+			}
+			failed++
+		}
+	}
+
+	if failed == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("%d of %d files failed, first error: %w", failed, len(errs), first)
+}
+
+// resultErrors returns the error of every result
+func resultErrors(results []ObjectResult) []error {
+	errs := make([]error, len(results))
+	for i, result := range results {
+		errs[i] = result.Err
+	}
+	return errs
+}
+
+// resultsError summarizes the failed results of an operation, nil when every file succeeded
+func resultsError(op string, path string, results []ObjectResult) error {
+	if err := failures(resultErrors(results)); err != nil {
+		return &PathError{Op: op, Path: path, Err: err}
+	}
+	return nil
+}
+
+// batchError summarizes the failed files of a batch operation, nil when every file succeeded
+func batchError(op string, errs []error) error {
+	if err := failures(errs); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// PutMany writes files with at most DefaultConcurrency writes at once, see WithConcurrency.
+// Every file is reported in request order, the error is non-nil when any file could not be written.
+func (s *Storage) PutMany(ctx context.Context, disk string, requests []PutRequest, opts ...BatchOption) ([]ObjectResult, error) {
+	d := s.getDisk(disk)
+	if d == nil {
+		return nil, ErrDiskNotFound(disk)
+	}
+
+	options := newBatchOptions(opts)
+
+	start := time.Now()
+	errs := runConcurrently(ctx, len(requests), options.concurrency, func(i int) error {
+		req := requests[i]
+		if req.Metadata == nil {
+			return d.put(ctx, req.Path, req.Content)
+		}
+		return d.putWithMetadata(ctx, req.Path, req.Content, req.Metadata)
+	})
+	err := batchError("putMany", errs)
+	s.logOperation(ctx, "putMany", disk, "", start, err)
+
+	results := make([]ObjectResult, len(requests))
+	for i, req := range requests {
+		results[i] = ObjectResult{Path: req.Path, Err: errs[i]}
+		if errs[i] == nil {
+			s.emit(ctx, Event{Type: EventPut, Disk: disk, Path: req.Path, Size: int64(len(req.Content)), Metadata: req.Metadata, Operation: "PutMany"})
+		}
+	}
+
+	return results, err
+}
+
+// GetMany reads files with at most DefaultConcurrency reads at once, see WithConcurrency.
+// Every file is reported in request order, the error is non-nil when any file could not be read.
+func (s *Storage) GetMany(ctx context.Context, disk string, paths []string, opts ...BatchOption) ([]GetResult, error) {
+	d := s.getDisk(disk)
+	if d == nil {
+		return nil, ErrDiskNotFound(disk)
+	}
+
+	options := newBatchOptions(opts)
+
+	results := make([]GetResult, len(paths))
+	for i, path := range paths {
+		results[i].Path = path
+	}
+
+	start := time.Now()
+	errs := runConcurrently(ctx, len(paths), options.concurrency, func(i int) error {
+		content, err := d.get(ctx, paths[i])
+		results[i].Content = content
+		return err
+	})
+	err := batchError("getMany", errs)
+	s.logOperation(ctx, "getMany", disk, "", start, err)
+
+	for i := range results {
+		results[i].Err = errs[i]
+	}
+
+	return results, err
+}
+
+// DeleteMany deletes files with at most DefaultConcurrency deletes at once, see WithConcurrency.
+// S3 deletes up to 1000 files per request. Every file is reported in request order,
+// the error is non-nil when any file could not be deleted. Missing files fail with ErrFileNotFound,
+// except on S3, where deleting a missing object succeeds just like Delete does.
+func (s *Storage) DeleteMany(ctx context.Context, disk string, paths []string, opts ...BatchOption) ([]ObjectResult, error) {
+	d := s.getDisk(disk)
+	if d == nil {
+		return nil, ErrDiskNotFound(disk)
+	}
+
+	options := newBatchOptions(opts)
+
+	start := time.Now()
+	errs := deleteFiles(ctx, d, paths, options.concurrency)
+	err := batchError("deleteMany", errs)
+	s.logOperation(ctx, "deleteMany", disk, "", start, err)
+
+	results := make([]ObjectResult, len(paths))
+	for i, path := range paths {
+		results[i] = ObjectResult{Path: path, Err: errs[i]}
+		if errs[i] == nil {
+			s.emit(ctx, Event{Type: EventDelete, Disk: disk, Path: path, Size: -1, Operation: "DeleteMany"})
+		}
+	}
+
+	return results, err
+}
+
+// CopyMany copies files with at most DefaultConcurrency copies at once, see WithConcurrency.
+// Every file is reported in request order, the error is non-nil when any file could not be copied.
+func (s *Storage) CopyMany(ctx context.Context, disk string, requests []CopyRequest, opts ...BatchOption) ([]ObjectResult, error) {
+	d := s.getDisk(disk)
+	if d == nil {
+		return nil, ErrDiskNotFound(disk)
+	}
+
+	options := newBatchOptions(opts)
+
+	start := time.Now()
+	errs := runConcurrently(ctx, len(requests), options.concurrency, func(i int) error {
+		return d.copy(ctx, requests[i].SourcePath, requests[i].DestPath)
+	})
+	err := batchError("copyMany", errs)
+	s.logOperation(ctx, "copyMany", disk, "", start, err)
+
+	results := make([]ObjectResult, len(requests))
+	for i, req := range requests {
+		results[i] = ObjectResult{Path: req.DestPath, SourcePath: req.SourcePath, Err: errs[i]}
+	}
+
+	s.emitTransfers(ctx, EventCopy, disk, "CopyMany", results)
+	return results, err
+}
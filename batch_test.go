@@ -0,0 +1,167 @@
+package gostorage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// concurrencyDisk is a MemoryDisk that records the most writes in flight at once
+type concurrencyDisk struct {
+	*MemoryDisk
+	active atomic.Int32
+	peak   atomic.Int32
+}
+
+func (d *concurrencyDisk) put(ctx context.Context, path string, content []byte) error {
+	n := d.active.Add(1)
+	defer d.active.Add(-1)
+
+	for {
+		peak := d.peak.Load()
+		if n <= peak || d.peak.CompareAndSwap(peak, n) {
+			break
+		}
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	return d.MemoryDisk.put(ctx, path, content)
+}
+
+func TestStorage_BatchOperations(t *testing.T) {
+	storage := NewStorage()
+	storage.AddDisk("memory", NewMemoryDisk())
+	ctx := context.Background()
+
+	requests := make([]PutRequest, 20)
+	paths := make([]string, 20)
+	for i := range requests {
+		paths[i] = fmt.Sprintf("batch/%02d.txt", i)
+		requests[i] = PutRequest{Path: paths[i], Content: []byte(paths[i])}
+	}
+	requests[3].Metadata = &Metadata{ContentType: "text/csv"}
+
+	results, err := storage.PutMany(ctx, "memory", requests)
+	if err != nil {
+		t.Fatalf("PutMany failed: %v", err)
+	}
+	if len(results) != len(requests) {
+		t.Fatalf("expected %d results, got %d", len(requests), len(results))
+	}
+
+	metadata, err := storage.GetMetadata(ctx, "memory", paths[3])
+	if err != nil {
+		t.Fatalf("GetMetadata failed: %v", err)
+	}
+	if metadata.ContentType != "text/csv" {
+		t.Errorf("expected text/csv, got %q", metadata.ContentType)
+	}
+
+	// Results keep the request order, missing files fail on their own
+	gets, err := storage.GetMany(ctx, "memory", append(paths, "batch/missing.txt"))
+	if err == nil {
+		t.Error("expected an error for the missing file")
+	}
+	for i, result := range gets[:len(paths)] {
+		if result.Err != nil || result.Path != paths[i] || string(result.Content) != paths[i] {
+			t.Errorf("unexpected result %d: %+v", i, result)
+		}
+	}
+	if last := gets[len(paths)]; !errors.Is(last.Err, ErrFileNotFound) {
+		t.Errorf("expected ErrFileNotFound, got %v", last.Err)
+	}
+
+	copies := []CopyRequest{
+		{SourcePath: paths[0], DestPath: "copies/00.txt"},
+		{SourcePath: paths[1], DestPath: "copies/01.txt"},
+	}
+	results, err = storage.CopyMany(ctx, "memory", copies)
+	if err != nil {
+		t.Fatalf("CopyMany failed: %v", err)
+	}
+	if results[1].Path != "copies/01.txt" || results[1].SourcePath != paths[1] {
+		t.Errorf("unexpected copy result: %+v", results[1])
+	}
+	content, err := storage.Get(ctx, "memory", "copies/01.txt")
+	if err != nil || string(content) != paths[1] {
+		t.Errorf("expected copied content, got %q, %v", content, err)
+	}
+
+	if _, err := storage.DeleteMany(ctx, "memory", paths); err != nil {
+		t.Fatalf("DeleteMany failed: %v", err)
+	}
+	for _, path := range paths {
+		if exists, _ := storage.Exists(ctx, "memory", path); exists {
+			t.Errorf("%s still exists", path)
+		}
+	}
+
+	if _, err := storage.DeleteMany(ctx, "missing", paths); err == nil {
+		t.Error("expected an error for an unknown disk")
+	}
+}
+
+func TestStorage_BatchConcurrency(t *testing.T) {
+	disk := &concurrencyDisk{MemoryDisk: NewMemoryDisk()}
+	storage := NewStorage()
+	storage.AddDisk("disk", disk)
+
+	requests := make([]PutRequest, 30)
+	for i := range requests {
+		requests[i] = PutRequest{Path: fmt.Sprintf("%d.txt", i), Content: []byte("x")}
+	}
+
+	if _, err := storage.PutMany(context.Background(), "disk", requests, WithConcurrency(3)); err != nil {
+		t.Fatalf("PutMany failed: %v", err)
+	}
+
+	if peak := disk.peak.Load(); peak > 3 {
+		t.Errorf("expected at most 3 writes at once, got %d", peak)
+	}
+	if peak := disk.peak.Load(); peak < 2 {
+		t.Errorf("expected writes to run concurrently, got %d at once", peak)
+	}
+}
+
+func TestStorage_DeleteMany_PartialFailure(t *testing.T) {
+	disk, err := NewPolicyDisk(NewMemoryDisk(), &Policy{
+		Rules: []PolicyRule{
+			{Prefix: "locked", Operations: []Operation{OpDelete}, Allow: false},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create PolicyDisk: %v", err)
+	}
+
+	storage := NewStorage()
+	storage.AddDisk("disk", disk)
+	ctx := context.Background()
+
+	paths := []string{"a.txt", "locked/b.txt", "../c.txt"}
+	for _, path := range paths[:2] {
+		if err := storage.Put(ctx, "disk", path, []byte(path)); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+
+	results, err := storage.DeleteMany(ctx, "disk", paths)
+	if err == nil {
+		t.Fatal("expected an error for the failed files")
+	}
+	if results[0].Err != nil {
+		t.Errorf("expected a.txt to be deleted, got %v", results[0].Err)
+	}
+	if !errors.Is(results[1].Err, ErrPermissionDenied) {
+		t.Errorf("expected ErrPermissionDenied, got %v", results[1].Err)
+	}
+	if results[2].Err == nil {
+		t.Error("expected traversal to be rejected")
+	}
+
+	if exists, _ := storage.Exists(ctx, "disk", "locked/b.txt"); !exists {
+		t.Error("locked file should not have been deleted")
+	}
+}
@@ -0,0 +1,786 @@
+package gostorage
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"hash/fnv"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultCacheMaxBytes is the default total size of the files kept by a CachedDisk
+	DefaultCacheMaxBytes = 256 << 20
+
+	// DefaultCacheTTL is how long a cached file is served before it is revalidated against the origin
+	DefaultCacheTTL = time.Minute
+
+	// DefaultFlushInterval is how often a write-back CachedDisk writes pending changes to the origin
+	DefaultFlushInterval = 5 * time.Second
+)
+
+// WriteMode decides when writes to a CachedDisk reach the origin
+type WriteMode int
+
+const (
+	// WriteThrough writes to the cache and the origin before returning
+	WriteThrough WriteMode = iota
+
+	// WriteBack writes to the cache and flushes to the origin in the background
+	WriteBack
+)
+
+// CachedDiskConfig holds configuration for a CachedDisk
+type CachedDiskConfig struct {
+	// Origin is the slow disk holding the authoritative copy, e.g. an S3Disk
+	Origin Disk
+
+	// Cache is the fast disk holding cached copies (default: a new MemoryDisk)
+	Cache Disk
+
+	// MaxBytes bounds the total size of cached files, least recently used files are evicted first (default: 256 MiB)
+	MaxBytes int64
+
+	// TTL is how long a cached file is served before its ETag, or its size and modification
+	// time, are compared with the origin, a negative TTL never revalidates (default: 1m)
+	TTL time.Duration
+
+	// WriteMode decides when writes reach the origin (default: WriteThrough)
+	WriteMode WriteMode
+
+	// FlushInterval is how often pending writes are flushed in WriteBack mode (default: 5s)
+	FlushInterval time.Duration
+
+	// Logger receives flush and eviction failures (default: discard)
+	Logger *slog.Logger
+}
+
+// CachedDisk implements Disk interface by serving reads from a fast cache disk and
+// filling it from a slower origin disk. Only files read or written through the
+// CachedDisk are tracked, the cache disk should not be shared with other users.
+type CachedDisk struct {
+	origin    Disk
+	cache     Disk
+	maxBytes  int64
+	ttl       time.Duration
+	writeBack bool
+	logger    *slog.Logger
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+	bytes   int64
+	stale   []string
+
+	// keyLocks serialize fills, writes and flushes of the same path
+	keyLocks [64]sync.Mutex
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// cacheEntry is a file held by the cache
+type cacheEntry struct {
+	key       string
+	size      int64
+	metadata  *Metadata
+	validated time.Time
+	dirty     bool
+}
+
+// NewCachedDisk creates a new CachedDisk, in WriteBack mode it starts flushing in the background
+func NewCachedDisk(cfg *CachedDiskConfig) (*CachedDisk, error) {
+	if cfg == nil {
+		return nil, errors.New("config cannot be nil")
+	}
+
+	if cfg.Origin == nil {
+		return nil, errors.New("origin disk is required")
+	}
+
+	cache := cfg.Cache
+	if cache == nil {
+		cache = NewMemoryDisk()
+	}
+
+	maxBytes := cfg.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = DefaultCacheMaxBytes
+	}
+
+	ttl := cfg.TTL
+	if ttl == 0 {
+		ttl = DefaultCacheTTL
+	}
+
+	d := &CachedDisk{
+		origin:    cfg.Origin,
+		cache:     cache,
+		maxBytes:  maxBytes,
+		ttl:       ttl,
+		writeBack: cfg.WriteMode == WriteBack,
+		logger:    loggerOrDiscard(cfg.Logger),
+		entries:   make(map[string]*list.Element),
+		lru:       list.New(),
+	}
+
+	if d.writeBack {
+		interval := cfg.FlushInterval
+		if interval <= 0 {
+			interval = DefaultFlushInterval
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		d.cancel = cancel
+		d.done = make(chan struct{})
+		go d.flushLoop(ctx, interval)
+	}
+
+	return d, nil
+}
+
+// Close stops the background flush and writes pending changes to the origin
+func (d *CachedDisk) Close() error {
+	if d.cancel != nil {
+		d.cancel()
+		<-d.done
+	}
+
+	return d.Flush(context.Background())
+}
+
+// Flush writes every pending change to the origin
+func (d *CachedDisk) Flush(ctx context.Context) error {
+	d.mu.Lock()
+	var dirty []string
+	for key, elem := range d.entries {
+		if elem.Value.(*cacheEntry).dirty {
+			dirty = append(dirty, key)
+		}
+	}
+	d.mu.Unlock()
+
+	var errs []error
+	for _, key := range dirty {
+		if err := d.flushKey(ctx, key); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// flushLoop flushes pending writes until the disk is closed
+func (d *CachedDisk) flushLoop(ctx context.Context, interval time.Duration) {
+	defer close(d.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := d.Flush(ctx); err != nil && ctx.Err() == nil {
+				d.logger.WarnContext(ctx, "cache flush failed", slog.Any("error", err))
+			}
+		}
+	}
+}
+
+// flushKey writes a pending change of a single file to the origin
+func (d *CachedDisk) flushKey(ctx context.Context, key string) error {
+	lock := d.keyLock(key)
+	lock.Lock()
+	defer lock.Unlock()
+
+	return d.flushLocked(ctx, key)
+}
+
+// flushLocked writes a pending change to the origin, the key lock must be held
+func (d *CachedDisk) flushLocked(ctx context.Context, key string) error {
+	d.mu.Lock()
+	elem, ok := d.entries[key]
+	if !ok || !elem.Value.(*cacheEntry).dirty {
+		d.mu.Unlock()
+		return nil
+	}
+	size := elem.Value.(*cacheEntry).size
+	d.mu.Unlock()
+
+	metadata, err := d.upload(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	d.track(ctx, key, size, metadata, false)
+	return nil
+}
+
+// keyLock returns the lock serializing changes to a path
+func (d *CachedDisk) keyLock(key string) *sync.Mutex {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return &d.keyLocks[h.Sum32()%uint32(len(d.keyLocks))]
+}
+
+// cacheKey validates a path and returns the key it is cached under
+func cacheKey(path string) (string, error) {
+	validPath, err := ValidatePath(path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(validPath), nil
+}
+
+// lookup returns a copy of the entry for key if the cache holds a valid version of the file.
+// Entries older than the TTL are revalidated against the origin and dropped when the file changed.
+func (d *CachedDisk) lookup(ctx context.Context, key string) (cacheEntry, bool) {
+	d.mu.Lock()
+	elem, ok := d.entries[key]
+	if !ok {
+		d.mu.Unlock()
+		return cacheEntry{}, false
+	}
+
+	d.lru.MoveToFront(elem)
+	entry := elem.Value.(*cacheEntry)
+	snapshot := *entry
+	d.mu.Unlock()
+
+	if snapshot.dirty || d.ttl < 0 || time.Since(snapshot.validated) < d.ttl {
+		return snapshot, true
+	}
+
+	current, err := d.originVersion(ctx, key)
+	if err != nil || !sameVersion(snapshot.metadata, current) {
+		// The key lock may or may not be held by the caller
+		if d.drop(key, entry) {
+			d.evict(ctx, []string{key}, "")
+		}
+		return cacheEntry{}, false
+	}
+
+	d.mu.Lock()
+	entry.validated = time.Now()
+	d.mu.Unlock()
+
+	return snapshot, true
+}
+
+// sameVersion reports whether two metadata values describe the same version of a file,
+// comparing ETags when both are known and size and modification time otherwise
+func sameVersion(known, current *Metadata) bool {
+	if known == nil || current == nil {
+		return false
+	}
+	if known.ETag != "" && current.ETag != "" {
+		return known.ETag == current.ETag
+	}
+	if known.LastModified.IsZero() {
+		return false
+	}
+	return known.Size == current.Size && known.LastModified.Equal(current.LastModified)
+}
+
+// track records a file in the cache and evicts least recently used files above MaxBytes
+func (d *CachedDisk) track(ctx context.Context, key string, size int64, metadata *Metadata, dirty bool) {
+	entry := &cacheEntry{
+		key:       key,
+		size:      size,
+		metadata:  metadata,
+		validated: time.Now(),
+		dirty:     dirty,
+	}
+
+	d.mu.Lock()
+	if elem, ok := d.entries[key]; ok {
+		d.bytes -= elem.Value.(*cacheEntry).size
+		elem.Value = entry
+		d.lru.MoveToFront(elem)
+	} else {
+		d.entries[key] = d.lru.PushFront(entry)
+	}
+	d.bytes += size
+
+	// Pending writes cannot be evicted until they are flushed
+	var victims []string
+	for elem := d.lru.Back(); elem != nil && d.bytes > d.maxBytes; {
+		prev := elem.Prev()
+		if victim := elem.Value.(*cacheEntry); !victim.dirty {
+			d.lru.Remove(elem)
+			delete(d.entries, victim.key)
+			d.bytes -= victim.size
+			victims = append(victims, victim.key)
+		}
+		elem = prev
+	}
+	victims = append(victims, d.stale...)
+	d.stale = nil
+	d.mu.Unlock()
+
+	d.evict(ctx, victims, key)
+}
+
+// evict deletes the cache files of keys that are no longer tracked. The caller holds the
+// lock of held, files whose lock is busy are left for the next eviction so a concurrent
+// write of the same path never loses its new content.
+func (d *CachedDisk) evict(ctx context.Context, keys []string, held string) {
+	for _, key := range keys {
+		lock := d.keyLock(key)
+		if held == "" || lock != d.keyLock(held) {
+			if !lock.TryLock() {
+				d.mu.Lock()
+				d.stale = append(d.stale, key)
+				d.mu.Unlock()
+				continue
+			}
+		}
+
+		// Written again since it was dropped
+		d.mu.Lock()
+		_, tracked := d.entries[key]
+		d.mu.Unlock()
+
+		if !tracked {
+			if err := d.cache.delete(ctx, key); err != nil && !errors.Is(err, ErrFileNotFound) {
+				d.logger.WarnContext(ctx, "cache eviction failed", slog.String("path", key), slog.Any("error", err))
+			}
+		}
+
+		if held == "" || lock != d.keyLock(held) {
+			lock.Unlock()
+		}
+	}
+}
+
+// untrack forgets a file and deletes its cache file, the key lock must be held
+func (d *CachedDisk) untrack(ctx context.Context, key string) {
+	if d.drop(key, nil) {
+		d.evict(ctx, []string{key}, key)
+	}
+}
+
+// forget untracks a file that was removed from the cache disk behind our back
+func (d *CachedDisk) forget(ctx context.Context, key string) {
+	lock := d.keyLock(key)
+	lock.Lock()
+	defer lock.Unlock()
+
+	// Replaced by a concurrent write in the meantime
+	if exists, err := d.cache.exists(ctx, key); err == nil && exists {
+		return
+	}
+	d.untrack(ctx, key)
+}
+
+// drop removes a file from the index, if entry is given only while it is still the current entry
+func (d *CachedDisk) drop(key string, entry *cacheEntry) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	elem, ok := d.entries[key]
+	if !ok || (entry != nil && elem.Value.(*cacheEntry) != entry) {
+		return false
+	}
+	d.lru.Remove(elem)
+	delete(d.entries, key)
+	d.bytes -= elem.Value.(*cacheEntry).size
+	return true
+}
+
+// fill copies a file from the origin into the cache.
+// It reports false without an error for files larger than MaxBytes.
+func (d *CachedDisk) fill(ctx context.Context, key string) (bool, error) {
+	lock := d.keyLock(key)
+	lock.Lock()
+	defer lock.Unlock()
+
+	// Filled by a concurrent read in the meantime
+	if _, ok := d.lookup(ctx, key); ok {
+		return true, nil
+	}
+
+	metadata, err := d.originVersion(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	if metadata.Size > d.maxBytes {
+		return false, nil
+	}
+
+	reader, err := d.origin.getStream(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	defer reader.Close()
+
+	if err := d.cache.putStream(ctx, key, reader, metadata); err != nil {
+		return false, err
+	}
+
+	size, err := d.cache.size(ctx, key)
+	if err != nil {
+		return false, err
+	}
+
+	d.track(ctx, key, size, metadata, false)
+	return true, nil
+}
+
+// upload copies a file from the cache to the origin and returns the origin's metadata
+func (d *CachedDisk) upload(ctx context.Context, key string) (*Metadata, error) {
+	reader, err := d.cache.getStream(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	// Metadata is best effort, the content is what matters
+	metadata, _ := d.cache.getMetadata(ctx, key)
+
+	if err := d.origin.putStream(ctx, key, reader, metadata); err != nil {
+		return nil, err
+	}
+
+	// Without origin metadata the file is simply revalidated by downloading it again
+	current, _ := d.originVersion(ctx, key)
+	return current, nil
+}
+
+// originVersion returns the origin's metadata for a file, including its size, modification time and ETag
+func (d *CachedDisk) originVersion(ctx context.Context, key string) (*Metadata, error) {
+	info, err := statDisk(ctx, d.origin, key)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir {
+		return nil, &PathError{Op: "stat", Path: key, Err: ErrFileNotFound}
+	}
+	return info.Metadata, nil
+}
+
+// write stores a file in the cache and, in WriteThrough mode, in the origin
+func (d *CachedDisk) write(ctx context.Context, op string, path string, write func(key string) error) error {
+	key, err := cacheKey(path)
+	if err != nil {
+		return &PathError{Op: op, Path: path, Err: err}
+	}
+
+	lock := d.keyLock(key)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if err := write(key); err != nil {
+		d.untrack(ctx, key)
+		return err
+	}
+
+	size, err := d.cache.size(ctx, key)
+	if err != nil {
+		d.untrack(ctx, key)
+		return &PathError{Op: op, Path: path, Err: err}
+	}
+
+	if d.writeBack {
+		d.track(ctx, key, size, nil, true)
+		return nil
+	}
+
+	metadata, err := d.upload(ctx, key)
+	if err != nil {
+		d.untrack(ctx, key)
+		return err
+	}
+
+	d.track(ctx, key, size, metadata, false)
+	return nil
+}
+
+// invalidate flushes a pending write of a file and forgets it, so the next read refetches it
+func (d *CachedDisk) invalidate(ctx context.Context, key string) error {
+	lock := d.keyLock(key)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if err := d.flushLocked(ctx, key); err != nil {
+		return err
+	}
+
+	d.untrack(ctx, key)
+	return nil
+}
+
+// put writes content to the cache and the origin
+func (d *CachedDisk) put(ctx context.Context, path string, content []byte) error {
+	return d.write(ctx, "put", path, func(key string) error {
+		return d.cache.put(ctx, key, content)
+	})
+}
+
+// get reads content from the cache, filling it from the origin on a miss
+func (d *CachedDisk) get(ctx context.Context, path string) ([]byte, error) {
+	key, err := cacheKey(path)
+	if err != nil {
+		return nil, &PathError{Op: "get", Path: path, Err: err}
+	}
+
+	if _, ok := d.lookup(ctx, key); ok {
+		if content, err := d.cache.get(ctx, key); err == nil {
+			return content, nil
+		}
+		d.forget(ctx, key)
+	}
+
+	cached, err := d.fill(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if cached {
+		if content, err := d.cache.get(ctx, key); err == nil {
+			return content, nil
+		}
+	}
+
+	return d.origin.get(ctx, key)
+}
+
+// delete removes a file from the cache and the origin
+func (d *CachedDisk) delete(ctx context.Context, path string) error {
+	key, err := cacheKey(path)
+	if err != nil {
+		return &PathError{Op: "delete", Path: path, Err: err}
+	}
+
+	lock := d.keyLock(key)
+	lock.Lock()
+	defer lock.Unlock()
+
+	d.mu.Lock()
+	elem, pending := d.entries[key]
+	pending = pending && elem.Value.(*cacheEntry).dirty
+	d.mu.Unlock()
+
+	d.untrack(ctx, key)
+
+	err = d.origin.delete(ctx, key)
+	if err != nil && !(pending && errors.Is(err, ErrFileNotFound)) {
+		// A file that was never flushed does not exist on the origin yet
+		return err
+	}
+
+	return nil
+}
+
+// putStream writes content from a reader to the cache and the origin
+func (d *CachedDisk) putStream(ctx context.Context, path string, reader io.Reader, metadata *Metadata) error {
+	return d.write(ctx, "putStream", path, func(key string) error {
+		return d.cache.putStream(ctx, key, reader, metadata)
+	})
+}
+
+// getStream returns a reader from the cache, filling it from the origin on a miss.
+// Files larger than MaxBytes are streamed from the origin.
+func (d *CachedDisk) getStream(ctx context.Context, path string) (io.ReadCloser, error) {
+	key, err := cacheKey(path)
+	if err != nil {
+		return nil, &PathError{Op: "getStream", Path: path, Err: err}
+	}
+
+	if _, ok := d.lookup(ctx, key); ok {
+		if reader, err := d.cache.getStream(ctx, key); err == nil {
+			return reader, nil
+		}
+		d.forget(ctx, key)
+	}
+
+	cached, err := d.fill(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if cached {
+		if reader, err := d.cache.getStream(ctx, key); err == nil {
+			return reader, nil
+		}
+	}
+
+	return d.origin.getStream(ctx, key)
+}
+
+// exists checks the cache before asking the origin
+func (d *CachedDisk) exists(ctx context.Context, path string) (bool, error) {
+	key, err := cacheKey(path)
+	if err != nil {
+		return false, &PathError{Op: "exists", Path: path, Err: err}
+	}
+
+	if _, ok := d.lookup(ctx, key); ok {
+		return true, nil
+	}
+
+	return d.origin.exists(ctx, key)
+}
+
+// size checks the cache before asking the origin
+func (d *CachedDisk) size(ctx context.Context, path string) (int64, error) {
+	key, err := cacheKey(path)
+	if err != nil {
+		return 0, &PathError{Op: "size", Path: path, Err: err}
+	}
+
+	if entry, ok := d.lookup(ctx, key); ok {
+		return entry.size, nil
+	}
+
+	return d.origin.size(ctx, key)
+}
+
+// list returns the files on the origin, after flushing pending writes
+func (d *CachedDisk) list(ctx context.Context, prefix string) ([]FileInfo, error) {
+	if d.writeBack {
+		if err := d.Flush(ctx); err != nil {
+			return nil, &PathError{Op: "list", Path: prefix, Err: err}
+		}
+	}
+
+	return d.origin.list(ctx, prefix)
+}
+
+// copy copies a file on the origin, after flushing a pending write of the source
+func (d *CachedDisk) copy(ctx context.Context, sourcePath, destPath string) error {
+	sourceKey, err := cacheKey(sourcePath)
+	if err != nil {
+		return &PathError{Op: "copy", Path: sourcePath, Err: err}
+	}
+
+	destKey, err := cacheKey(destPath)
+	if err != nil {
+		return &PathError{Op: "copy", Path: destPath, Err: err}
+	}
+
+	if err := d.flushKey(ctx, sourceKey); err != nil {
+		return &PathError{Op: "copy", Path: sourcePath, Err: err}
+	}
+
+	lock := d.keyLock(destKey)
+	lock.Lock()
+	defer lock.Unlock()
+
+	d.untrack(ctx, destKey)
+	return d.origin.copy(ctx, sourceKey, destKey)
+}
+
+// move moves a file on the origin, after flushing a pending write of the source
+func (d *CachedDisk) move(ctx context.Context, sourcePath, destPath string) error {
+	sourceKey, err := cacheKey(sourcePath)
+	if err != nil {
+		return &PathError{Op: "move", Path: sourcePath, Err: err}
+	}
+
+	destKey, err := cacheKey(destPath)
+	if err != nil {
+		return &PathError{Op: "move", Path: destPath, Err: err}
+	}
+
+	if err := d.invalidate(ctx, sourceKey); err != nil {
+		return &PathError{Op: "move", Path: sourcePath, Err: err}
+	}
+
+	lock := d.keyLock(destKey)
+	lock.Lock()
+	defer lock.Unlock()
+
+	d.untrack(ctx, destKey)
+	return d.origin.move(ctx, sourceKey, destKey)
+}
+
+// putWithMetadata writes content and metadata to the cache and the origin
+func (d *CachedDisk) putWithMetadata(ctx context.Context, path string, content []byte, metadata *Metadata) error {
+	return d.write(ctx, "putWithMetadata", path, func(key string) error {
+		return d.cache.putWithMetadata(ctx, key, content, metadata)
+	})
+}
+
+// getMetadata returns the origin metadata recorded when the file was cached
+func (d *CachedDisk) getMetadata(ctx context.Context, path string) (*Metadata, error) {
+	key, err := cacheKey(path)
+	if err != nil {
+		return nil, &PathError{Op: "getMetadata", Path: path, Err: err}
+	}
+
+	if entry, ok := d.lookup(ctx, key); ok {
+		if entry.dirty {
+			return d.cache.getMetadata(ctx, key)
+		}
+		if entry.metadata != nil {
+			return cloneMetadata(entry.metadata), nil
+		}
+	}
+
+	return d.origin.getMetadata(ctx, key)
+}
+
+// setMetadata updates metadata on the origin and drops the cached copy
+func (d *CachedDisk) setMetadata(ctx context.Context, path string, metadata *Metadata) error {
+	key, err := cacheKey(path)
+	if err != nil {
+		return &PathError{Op: "setMetadata", Path: path, Err: err}
+	}
+
+	if err := d.invalidate(ctx, key); err != nil {
+		return &PathError{Op: "setMetadata", Path: path, Err: err}
+	}
+
+	return d.origin.setMetadata(ctx, key, metadata)
+}
+
+// ping checks the origin and the cache
+func (d *CachedDisk) ping(ctx context.Context) error {
+	if err := d.origin.ping(ctx); err != nil {
+		return err
+	}
+	return d.cache.ping(ctx)
+}
+
+// setVisibility changes the visibility on the origin, flushing a pending write first
+func (d *CachedDisk) setVisibility(ctx context.Context, path string, visibility Visibility) error {
+	key, err := cacheKey(path)
+	if err != nil {
+		return &PathError{Op: "setVisibility", Path: path, Err: err}
+	}
+
+	if err := d.flushKey(ctx, key); err != nil {
+		return &PathError{Op: "setVisibility", Path: path, Err: err}
+	}
+
+	return setDiskVisibility(ctx, d.origin, key, visibility)
+}
+
+// getVisibility returns the visibility on the origin, flushing a pending write first
+func (d *CachedDisk) getVisibility(ctx context.Context, path string) (Visibility, error) {
+	key, err := cacheKey(path)
+	if err != nil {
+		return "", &PathError{Op: "getVisibility", Path: path, Err: err}
+	}
+
+	if err := d.flushKey(ctx, key); err != nil {
+		return "", &PathError{Op: "getVisibility", Path: path, Err: err}
+	}
+
+	return diskVisibility(ctx, d.origin, key)
+}
+
+// url returns the public URL of a file on the origin
+func (d *CachedDisk) url(ctx context.Context, path string) (string, error) {
+	key, err := cacheKey(path)
+	if err != nil {
+		return "", &PathError{Op: "url", Path: path, Err: err}
+	}
+
+	return diskURL(ctx, d.origin, key)
+}
@@ -0,0 +1,270 @@
+package gostorage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingDisk is a MemoryDisk that counts content reads
+type countingDisk struct {
+	*MemoryDisk
+	reads atomic.Int32
+}
+
+func (d *countingDisk) get(ctx context.Context, path string) ([]byte, error) {
+	d.reads.Add(1)
+	return d.MemoryDisk.get(ctx, path)
+}
+
+func (d *countingDisk) getStream(ctx context.Context, path string) (io.ReadCloser, error) {
+	d.reads.Add(1)
+	return d.MemoryDisk.getStream(ctx, path)
+}
+
+func TestCachedDisk_ReadThrough(t *testing.T) {
+	ctx := context.Background()
+	origin := &countingDisk{MemoryDisk: NewMemoryDisk()}
+
+	if err := origin.putWithMetadata(ctx, "img/a.png", []byte("aaaa"), &Metadata{ContentType: "image/png"}); err != nil {
+		t.Fatalf("putWithMetadata failed: %v", err)
+	}
+
+	disk, err := NewCachedDisk(&CachedDiskConfig{Origin: origin, TTL: time.Hour})
+	if err != nil {
+		t.Fatalf("Failed to create CachedDisk: %v", err)
+	}
+	defer disk.Close()
+
+	for range 5 {
+		data, err := disk.get(ctx, "img/a.png")
+		if err != nil || string(data) != "aaaa" {
+			t.Fatalf("Expected cached content, got %q, %v", data, err)
+		}
+	}
+	if reads := origin.reads.Load(); reads != 1 {
+		t.Errorf("Expected 1 origin read, got %d", reads)
+	}
+
+	metadata, err := disk.getMetadata(ctx, "img/a.png")
+	if err != nil || metadata.ContentType != "image/png" {
+		t.Errorf("Expected origin metadata, got %+v, %v", metadata, err)
+	}
+
+	if _, err := disk.get(ctx, "img/missing.png"); !errors.Is(err, ErrFileNotFound) {
+		t.Errorf("Expected ErrFileNotFound, got %v", err)
+	}
+}
+
+func TestCachedDisk_Revalidation(t *testing.T) {
+	ctx := context.Background()
+	origin := &countingDisk{MemoryDisk: NewMemoryDisk()}
+
+	if err := origin.putWithMetadata(ctx, "a.txt", []byte("v1"), &Metadata{}); err != nil {
+		t.Fatalf("putWithMetadata failed: %v", err)
+	}
+
+	disk, err := NewCachedDisk(&CachedDiskConfig{Origin: origin, TTL: time.Nanosecond})
+	if err != nil {
+		t.Fatalf("Failed to create CachedDisk: %v", err)
+	}
+	defer disk.Close()
+
+	if _, err := disk.get(ctx, "a.txt"); err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+
+	// Unchanged files are served from the cache after revalidation
+	if _, err := disk.get(ctx, "a.txt"); err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if reads := origin.reads.Load(); reads != 1 {
+		t.Errorf("Expected 1 origin read, got %d", reads)
+	}
+
+	// Changed files are fetched again
+	time.Sleep(time.Millisecond)
+	if err := origin.putWithMetadata(ctx, "a.txt", []byte("v2!"), &Metadata{}); err != nil {
+		t.Fatalf("putWithMetadata failed: %v", err)
+	}
+	data, err := disk.get(ctx, "a.txt")
+	if err != nil || string(data) != "v2!" {
+		t.Errorf("Expected new content, got %q, %v", data, err)
+	}
+}
+
+func TestCachedDisk_Eviction(t *testing.T) {
+	ctx := context.Background()
+	origin := NewMemoryDisk()
+	cache := NewMemoryDisk()
+
+	for _, name := range []string{"a", "b", "c"} {
+		if err := origin.put(ctx, name, bytes.Repeat([]byte(name), 40)); err != nil {
+			t.Fatalf("put failed: %v", err)
+		}
+	}
+
+	disk, err := NewCachedDisk(&CachedDiskConfig{Origin: origin, Cache: cache, MaxBytes: 100})
+	if err != nil {
+		t.Fatalf("Failed to create CachedDisk: %v", err)
+	}
+	defer disk.Close()
+
+	for _, name := range []string{"a", "b", "a", "c"} {
+		if _, err := disk.get(ctx, name); err != nil {
+			t.Fatalf("get failed: %v", err)
+		}
+	}
+
+	// b is the least recently used file
+	if exists, _ := cache.exists(ctx, "b"); exists {
+		t.Error("Expected b to be evicted")
+	}
+	for _, name := range []string{"a", "c"} {
+		if exists, _ := cache.exists(ctx, name); !exists {
+			t.Errorf("Expected %s to be cached", name)
+		}
+	}
+}
+
+func TestCachedDisk_WriteModes(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("write-through", func(t *testing.T) {
+		origin := NewMemoryDisk()
+		disk, err := NewCachedDisk(&CachedDiskConfig{Origin: origin})
+		if err != nil {
+			t.Fatalf("Failed to create CachedDisk: %v", err)
+		}
+		defer disk.Close()
+
+		if err := disk.put(ctx, "a.txt", []byte("data")); err != nil {
+			t.Fatalf("put failed: %v", err)
+		}
+		if exists, _ := origin.exists(ctx, "a.txt"); !exists {
+			t.Error("Expected write to reach the origin")
+		}
+	})
+
+	t.Run("write-back", func(t *testing.T) {
+		origin := NewMemoryDisk()
+		disk, err := NewCachedDisk(&CachedDiskConfig{Origin: origin, WriteMode: WriteBack, FlushInterval: time.Hour})
+		if err != nil {
+			t.Fatalf("Failed to create CachedDisk: %v", err)
+		}
+		defer disk.Close()
+
+		if err := disk.put(ctx, "a.txt", []byte("data")); err != nil {
+			t.Fatalf("put failed: %v", err)
+		}
+		if exists, _ := origin.exists(ctx, "a.txt"); exists {
+			t.Error("Expected write to stay in the cache until flushed")
+		}
+		data, err := disk.get(ctx, "a.txt")
+		if err != nil || string(data) != "data" {
+			t.Errorf("Expected pending write to be readable, got %q, %v", data, err)
+		}
+
+		if err := disk.Flush(ctx); err != nil {
+			t.Fatalf("Flush failed: %v", err)
+		}
+		if exists, _ := origin.exists(ctx, "a.txt"); !exists {
+			t.Error("Expected write to reach the origin after Flush")
+		}
+
+		// Deleting a file that was never flushed succeeds
+		if err := disk.put(ctx, "b.txt", []byte("tmp")); err != nil {
+			t.Fatalf("put failed: %v", err)
+		}
+		if err := disk.delete(ctx, "b.txt"); err != nil {
+			t.Errorf("delete of pending write failed: %v", err)
+		}
+		if exists, _ := disk.exists(ctx, "b.txt"); exists {
+			t.Error("Expected b.txt to be deleted")
+		}
+	})
+}
+
+// hookedDeleteDisk is a MemoryDisk that runs a hook before deleting a file
+type hookedDeleteDisk struct {
+	*MemoryDisk
+	beforeDelete func(path string)
+}
+
+func (d *hookedDeleteDisk) delete(ctx context.Context, path string) error {
+	if d.beforeDelete != nil {
+		d.beforeDelete(path)
+	}
+	return d.MemoryDisk.delete(ctx, path)
+}
+
+func TestCachedDisk_ConcurrentEviction(t *testing.T) {
+	ctx := context.Background()
+	origin := NewMemoryDisk()
+	cache := &hookedDeleteDisk{MemoryDisk: NewMemoryDisk()}
+
+	disk, err := NewCachedDisk(&CachedDiskConfig{
+		Origin:        origin,
+		Cache:         cache,
+		MaxBytes:      100,
+		WriteMode:     WriteBack,
+		FlushInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create CachedDisk: %v", err)
+	}
+	defer disk.Close()
+
+	for _, name := range []string{"a", "b"} {
+		if err := disk.put(ctx, name, bytes.Repeat([]byte(name), 40)); err != nil {
+			t.Fatalf("put failed: %v", err)
+		}
+	}
+	if err := disk.Flush(ctx); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	// Rewrite the evicted file while its cache file is being deleted
+	var once sync.Once
+	var wg sync.WaitGroup
+	var putErr error
+	var victim string
+	cache.beforeDelete = func(path string) {
+		once.Do(func() {
+			victim = path
+			wg.Add(1)
+			done := make(chan struct{})
+			go func() {
+				defer wg.Done()
+				defer close(done)
+				putErr = disk.put(ctx, path, []byte("new"))
+			}()
+			select {
+			case <-done:
+			case <-time.After(50 * time.Millisecond):
+			}
+		})
+	}
+
+	// c pushes the cache over MaxBytes and evicts a or b
+	if err := disk.put(ctx, "c", bytes.Repeat([]byte("c"), 40)); err != nil {
+		t.Fatalf("put failed: %v", err)
+	}
+	wg.Wait()
+	if putErr != nil {
+		t.Fatalf("Concurrent put failed: %v", putErr)
+	}
+
+	if err := disk.Flush(ctx); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	data, err := origin.get(ctx, victim)
+	if err != nil || string(data) != "new" {
+		t.Errorf("Expected the concurrent write of %s to reach the origin, got %q, %v", victim, data, err)
+	}
+}
@@ -0,0 +1,489 @@
+package gostorage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"path/filepath"
+	"strconv"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	// CodecHeader is the custom header recording the codec a file was compressed with
+	CodecHeader = "gostorage-codec"
+
+	// OriginalSizeHeader is the custom header recording the size of a file before compression
+	OriginalSizeHeader = "gostorage-original-size"
+)
+
+// DefaultSkipContentTypes lists content types that are already compressed.
+// A trailing "/*" matches every subtype.
+var DefaultSkipContentTypes = []string{
+	"image/jpeg",
+	"image/png",
+	"image/gif",
+	"image/webp",
+	"image/avif",
+	"video/*",
+	"audio/*",
+	"font/woff",
+	"font/woff2",
+	"application/zip",
+	"application/gzip",
+	"application/x-gzip",
+	"application/zstd",
+	"application/x-bzip2",
+	"application/x-xz",
+	"application/x-7z-compressed",
+	"application/vnd.rar",
+	"application/x-rar-compressed",
+}
+
+// Codec compresses and decompresses content for CompressedDisk
+type Codec interface {
+	// Name identifies the codec in the CodecHeader of stored files
+	Name() string
+
+	// NewWriter returns a writer that compresses into w
+	NewWriter(w io.Writer) (io.WriteCloser, error)
+
+	// NewReader returns a reader that decompresses r
+	NewReader(r io.Reader) (io.ReadCloser, error)
+}
+
+var (
+	// GzipCodec compresses with gzip
+	GzipCodec Codec = gzipCodec{}
+
+	// ZstdCodec compresses with Zstandard
+	ZstdCodec Codec = zstdCodec{}
+)
+
+type gzipCodec struct{}
+
+func (gzipCodec) Name() string { return "gzip" }
+
+func (gzipCodec) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return gzip.NewWriter(w), nil
+}
+
+func (gzipCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+type zstdCodec struct{}
+
+func (zstdCodec) Name() string { return "zstd" }
+
+func (zstdCodec) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return zstd.NewWriter(w)
+}
+
+func (zstdCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	decoder, err := zstd.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return decoder.IOReadCloser(), nil
+}
+
+// CompressedDiskConfig contains configuration for a compressing disk wrapper
+type CompressedDiskConfig struct {
+	// Disk is the disk that stores the compressed content (required)
+	Disk Disk
+
+	// Codec compresses new files (default: GzipCodec)
+	Codec Codec
+
+	// Codecs are additional codecs that can be read, GzipCodec and ZstdCodec are always readable
+	Codecs []Codec
+
+	// SkipContentTypes are stored as is because they are already compressed (default: DefaultSkipContentTypes)
+	SkipContentTypes []string
+}
+
+// CompressedDisk wraps a Disk and compresses content on write and decompresses it on read.
+// The codec and the original size are recorded in Metadata.CustomHeaders, files without
+// a codec header are read as is. Sizes are logical sizes, use Sizes for the stored size of a file.
+// Streams that cannot seek have no recorded size, it is counted by decompressing them when asked.
+type CompressedDisk struct {
+	disk   Disk
+	codec  Codec
+	codecs map[string]Codec
+	skip   []string
+}
+
+// NewCompressedDisk creates a new CompressedDisk with the given configuration
+func NewCompressedDisk(cfg *CompressedDiskConfig) (*CompressedDisk, error) {
+	if cfg == nil {
+		return nil, errors.New("CompressedDiskConfig cannot be nil")
+	}
+
+	if cfg.Disk == nil {
+		return nil, errors.New("disk is required")
+	}
+
+	codec := cfg.Codec
+	if codec == nil {
+		codec = GzipCodec
+	}
+
+	codecs := map[string]Codec{
+		GzipCodec.Name(): GzipCodec,
+		ZstdCodec.Name(): ZstdCodec,
+	}
+	for _, c := range cfg.Codecs {
+		codecs[c.Name()] = c
+	}
+	codecs[codec.Name()] = codec
+
+	skip := cfg.SkipContentTypes
+	if skip == nil {
+		skip = DefaultSkipContentTypes
+	}
+
+	return &CompressedDisk{
+		disk:   cfg.Disk,
+		codec:  codec,
+		codecs: codecs,
+		skip:   skip,
+	}, nil
+}
+
+// Sizes returns the logical size of a file and the size it occupies on the underlying disk
+func (d *CompressedDisk) Sizes(ctx context.Context, path string) (logical int64, stored int64, err error) {
+	stored, err = d.disk.size(ctx, path)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	logical, err = d.size(ctx, path)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return logical, stored, nil
+}
+
+// put compresses and writes content
+func (d *CompressedDisk) put(ctx context.Context, path string, content []byte) error {
+	return d.putWithMetadata(ctx, path, content, nil)
+}
+
+// get reads and decompresses content
+func (d *CompressedDisk) get(ctx context.Context, path string) ([]byte, error) {
+	reader, err := d.open(ctx, "get", path)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, &PathError{Op: "get", Path: path, Err: err}
+	}
+
+	return content, nil
+}
+
+// delete removes a file
+func (d *CompressedDisk) delete(ctx context.Context, path string) error {
+	return d.disk.delete(ctx, path)
+}
+
+// putStream compresses content from a reader while writing it
+func (d *CompressedDisk) putStream(ctx context.Context, path string, reader io.Reader, metadata *Metadata) error {
+	if d.shouldSkip(path, metadata) {
+		return d.disk.putStream(ctx, path, reader, metadata)
+	}
+
+	// The original size of other streams is only known once they are consumed, size counts it on demand
+	originalSize := int64(-1)
+	if size, ok := remainingSize(reader); ok {
+		originalSize = size
+	}
+
+	// The underlying disk only sees compressed bytes, detect the content type first
+	reader, metadata, err := detectStream(diskDetector(d.disk), path, reader, metadata)
+	if err != nil {
+		return &PathError{Op: "putStream", Path: path, Err: err}
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(d.compress(pw, reader))
+	}()
+
+	err = d.disk.putStream(ctx, path, pr, d.withCodec(metadata, originalSize))
+	pr.Close()
+	return err
+}
+
+// getStream returns a reader that decompresses content while reading it
+func (d *CompressedDisk) getStream(ctx context.Context, path string) (io.ReadCloser, error) {
+	return d.open(ctx, "getStream", path)
+}
+
+// exists checks if a file exists
+func (d *CompressedDisk) exists(ctx context.Context, path string) (bool, error) {
+	return d.disk.exists(ctx, path)
+}
+
+// size returns the size of a file before compression
+func (d *CompressedDisk) size(ctx context.Context, path string) (int64, error) {
+	metadata, err := d.disk.getMetadata(ctx, path)
+	if err != nil {
+		return 0, err
+	}
+
+	if codecName(metadata) == "" {
+		return d.disk.size(ctx, path)
+	}
+
+	if size, err := strconv.ParseInt(metadata.CustomHeaders[OriginalSizeHeader], 10, 64); err == nil {
+		return size, nil
+	}
+
+	// The size was never recorded, count it the slow way
+	reader, err := d.open(ctx, "size", path)
+	if err != nil {
+		return 0, err
+	}
+	defer reader.Close()
+
+	size, err := io.Copy(io.Discard, reader)
+	if err != nil {
+		return 0, &PathError{Op: "size", Path: path, Err: err}
+	}
+
+	return size, nil
+}
+
+// list returns a list of files matching a prefix with their sizes before compression
+func (d *CompressedDisk) list(ctx context.Context, prefix string) ([]FileInfo, error) {
+	files, err := d.disk.list(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	return logicalSizes(ctx, files, d.size)
+}
+
+// copy copies a file, the codec headers travel with the metadata
+func (d *CompressedDisk) copy(ctx context.Context, sourcePath, destPath string) error {
+	return d.disk.copy(ctx, sourcePath, destPath)
+}
+
+// move moves a file, the codec headers travel with the metadata
+func (d *CompressedDisk) move(ctx context.Context, sourcePath, destPath string) error {
+	return d.disk.move(ctx, sourcePath, destPath)
+}
+
+// putWithMetadata compresses and writes content together with metadata
+func (d *CompressedDisk) putWithMetadata(ctx context.Context, path string, content []byte, metadata *Metadata) error {
+	if d.shouldSkip(path, metadata) {
+		if metadata == nil {
+			return d.disk.put(ctx, path, content)
+		}
+		return d.disk.putWithMetadata(ctx, path, content, metadata)
+	}
+
+	var compressed bytes.Buffer
+	if err := d.compress(&compressed, bytes.NewReader(content)); err != nil {
+		return &PathError{Op: "putWithMetadata", Path: path, Err: err}
+	}
+
+	// The underlying disk only sees compressed bytes, detect the content type first
+	metadata = detectMetadata(diskDetector(d.disk), path, content, metadata)
+
+	return d.disk.putWithMetadata(ctx, path, compressed.Bytes(), d.withCodec(metadata, int64(len(content))))
+}
+
+// getMetadata retrieves metadata for a file as it was before compression
+func (d *CompressedDisk) getMetadata(ctx context.Context, path string) (*Metadata, error) {
+	metadata, err := d.disk.getMetadata(ctx, path)
+	if err != nil || codecName(metadata) == "" {
+		return metadata, err
+	}
+
+	logical := cloneMetadata(metadata)
+	if size, err := strconv.ParseInt(logical.CustomHeaders[OriginalSizeHeader], 10, 64); err == nil {
+		logical.Size = size
+	}
+
+	// Codec headers describe the stored bytes, not the content callers see
+	delete(logical.CustomHeaders, CodecHeader)
+	delete(logical.CustomHeaders, OriginalSizeHeader)
+	if len(logical.CustomHeaders) == 0 {
+		logical.CustomHeaders = nil
+	}
+
+	return logical, nil
+}
+
+// setMetadata updates metadata for a file, keeping the codec headers
+func (d *CompressedDisk) setMetadata(ctx context.Context, path string, metadata *Metadata) error {
+	current, err := d.disk.getMetadata(ctx, path)
+	if err != nil {
+		return err
+	}
+
+	name := codecName(current)
+	if name == "" {
+		return d.disk.setMetadata(ctx, path, metadata)
+	}
+
+	updated := cloneMetadata(metadata)
+	if updated == nil {
+		updated = &Metadata{}
+	}
+	if updated.CustomHeaders == nil {
+		updated.CustomHeaders = make(map[string]string)
+	}
+	updated.CustomHeaders[CodecHeader] = name
+	if size, ok := current.CustomHeaders[OriginalSizeHeader]; ok {
+		updated.CustomHeaders[OriginalSizeHeader] = size
+	}
+
+	return d.disk.setMetadata(ctx, path, updated)
+}
+
+// open returns a reader for a file, decompressing it if it was stored compressed
+func (d *CompressedDisk) open(ctx context.Context, op string, path string) (io.ReadCloser, error) {
+	metadata, err := d.disk.getMetadata(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := d.disk.getStream(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	name := codecName(metadata)
+	if name == "" {
+		return stored, nil
+	}
+
+	codec, ok := d.codecs[name]
+	if !ok {
+		stored.Close()
+		return nil, &PathError{Op: op, Path: path, Err: fmt.Errorf("%w: unknown codec %q", ErrOperationNotSupported, name)}
+	}
+
+	decompressed, err := codec.NewReader(stored)
+	if err != nil {
+		stored.Close()
+		return nil, &PathError{Op: op, Path: path, Err: err}
+	}
+
+	return &decompressingReader{ReadCloser: decompressed, stored: stored}, nil
+}
+
+// compress writes the compressed form of r to w
+func (d *CompressedDisk) compress(w io.Writer, r io.Reader) error {
+	writer, err := d.codec.NewWriter(w)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(writer, r); err != nil {
+		writer.Close()
+		return err
+	}
+
+	return writer.Close()
+}
+
+// withCodec returns a copy of metadata with the codec headers set, a negative size is left out
+func (d *CompressedDisk) withCodec(metadata *Metadata, originalSize int64) *Metadata {
+	stored := cloneMetadata(metadata)
+	if stored == nil {
+		stored = &Metadata{}
+	}
+	if stored.CustomHeaders == nil {
+		stored.CustomHeaders = make(map[string]string)
+	}
+
+	stored.CustomHeaders[CodecHeader] = d.codec.Name()
+	if originalSize >= 0 {
+		stored.CustomHeaders[OriginalSizeHeader] = strconv.FormatInt(originalSize, 10)
+	}
+
+	return stored
+}
+
+// shouldSkip reports whether content is already compressed, judged by content type or extension
+func (d *CompressedDisk) shouldSkip(path string, metadata *Metadata) bool {
+	contentType := ""
+	if metadata != nil {
+		contentType = metadata.ContentType
+	}
+	if contentType == "" {
+		contentType = mime.TypeByExtension(filepath.Ext(path))
+	}
+	if contentType == "" {
+		return false
+	}
+
+	return matchContentType(contentType, d.skip)
+}
+
+// codecName returns the codec recorded in metadata, or "" for files stored as is
+func codecName(metadata *Metadata) string {
+	if metadata == nil {
+		return ""
+	}
+	return metadata.CustomHeaders[CodecHeader]
+}
+
+// decompressingReader closes both the decompressor and the stored stream
+type decompressingReader struct {
+	io.ReadCloser
+	stored io.Closer
+}
+
+func (r *decompressingReader) Close() error {
+	err := r.ReadCloser.Close()
+	if storedErr := r.stored.Close(); err == nil {
+		err = storedErr
+	}
+	return err
+}
+
+// ping checks the underlying disk
+func (d *CompressedDisk) ping(ctx context.Context) error {
+	return d.disk.ping(ctx)
+}
+
+// setVisibility changes the visibility of a file.
+// There is no url, a public URL would serve the compressed content.
+func (d *CompressedDisk) setVisibility(ctx context.Context, path string, visibility Visibility) error {
+	return setDiskVisibility(ctx, d.disk, path, visibility)
+}
+
+// getVisibility returns the visibility of a file
+func (d *CompressedDisk) getVisibility(ctx context.Context, path string) (Visibility, error) {
+	return diskVisibility(ctx, d.disk, path)
+}
+
+// deleteMany deletes files, in batches if the underlying disk supports it
+func (d *CompressedDisk) deleteMany(ctx context.Context, paths []string, concurrency int) []error {
+	return deleteFiles(ctx, d.disk, paths, concurrency)
+}
+
+// typeDetector returns the detector of the underlying disk
+func (d *CompressedDisk) typeDetector() *ContentTypeDetector {
+	return diskDetector(d.disk)
+}
+
+// removeDirectory removes an empty directory
+func (d *CompressedDisk) removeDirectory(ctx context.Context, path string) error {
+	return removeDiskDirectory(ctx, d.disk, path)
+}
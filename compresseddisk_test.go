@@ -0,0 +1,165 @@
+package gostorage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestCompressedDisk_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+	content := []byte(strings.Repeat(`{"level":"info","msg":"request served"}`+"\n", 1000))
+
+	for _, codec := range []Codec{GzipCodec, ZstdCodec} {
+		t.Run(codec.Name(), func(t *testing.T) {
+			backend, err := NewLocalDisk(&LocalDiskConfig{Path: t.TempDir()})
+			if err != nil {
+				t.Fatalf("Failed to create LocalDisk: %v", err)
+			}
+
+			disk, err := NewCompressedDisk(&CompressedDiskConfig{Disk: backend, Codec: codec})
+			if err != nil {
+				t.Fatalf("Failed to create CompressedDisk: %v", err)
+			}
+
+			// Seekable streams record the original size up front
+			meta := &Metadata{ContentType: "application/x-ndjson", CustomHeaders: map[string]string{"source": "api"}}
+			if err := disk.putStream(ctx, "logs/app.log", bytes.NewReader(content), meta); err != nil {
+				t.Fatalf("PutStream failed: %v", err)
+			}
+
+			reader, err := disk.getStream(ctx, "logs/app.log")
+			if err != nil {
+				t.Fatalf("GetStream failed: %v", err)
+			}
+			data, err := io.ReadAll(reader)
+			reader.Close()
+			if err != nil {
+				t.Fatalf("Reading stream failed: %v", err)
+			}
+			if !bytes.Equal(data, content) {
+				t.Error("Decompressed content differs from original")
+			}
+
+			logical, stored, err := disk.Sizes(ctx, "logs/app.log")
+			if err != nil {
+				t.Fatalf("Sizes failed: %v", err)
+			}
+			if logical != int64(len(content)) {
+				t.Errorf("Expected logical size %d, got %d", len(content), logical)
+			}
+			if stored >= logical/10 {
+				t.Errorf("Expected at least 10x compression, stored %d of %d bytes", stored, logical)
+			}
+
+			files, err := disk.list(ctx, "logs")
+			if err != nil {
+				t.Fatalf("List failed: %v", err)
+			}
+			for _, file := range files {
+				if !file.IsDir && file.Size != logical {
+					t.Errorf("Expected list to report size %d, got %d", logical, file.Size)
+				}
+			}
+
+			// Codec headers are internal
+			retrieved, err := disk.getMetadata(ctx, "logs/app.log")
+			if err != nil {
+				t.Fatalf("GetMetadata failed: %v", err)
+			}
+			if _, ok := retrieved.CustomHeaders[CodecHeader]; ok {
+				t.Error("Codec header should not be visible")
+			}
+			if retrieved.CustomHeaders["source"] != "api" {
+				t.Error("Custom header 'source' mismatch")
+			}
+
+			// Replacing metadata keeps the file readable
+			if err := disk.setMetadata(ctx, "logs/app.log", &Metadata{ContentType: "text/plain"}); err != nil {
+				t.Fatalf("SetMetadata failed: %v", err)
+			}
+			data, err = disk.get(ctx, "logs/app.log")
+			if err != nil {
+				t.Fatalf("Get after SetMetadata failed: %v", err)
+			}
+			if !bytes.Equal(data, content) {
+				t.Error("Content differs after SetMetadata")
+			}
+		})
+	}
+}
+
+// readOnlyMetadataDisk is a MemoryDisk whose metadata cannot be replaced after a write
+type readOnlyMetadataDisk struct {
+	*MemoryDisk
+}
+
+func (d *readOnlyMetadataDisk) setMetadata(_ context.Context, path string, _ *Metadata) error {
+	return &PathError{Op: "setMetadata", Path: path, Err: errors.New("object too large to copy")}
+}
+
+func TestCompressedDisk_PutStreamWritesOnce(t *testing.T) {
+	ctx := context.Background()
+	content := []byte(strings.Repeat("GET /health 200\n", 500))
+
+	disk, err := NewCompressedDisk(&CompressedDiskConfig{Disk: &readOnlyMetadataDisk{MemoryDisk: NewMemoryDisk()}})
+	if err != nil {
+		t.Fatalf("Failed to create CompressedDisk: %v", err)
+	}
+
+	// A stream that cannot seek, its size is counted when asked for
+	if err := disk.putStream(ctx, "logs/pipe.log", io.MultiReader(bytes.NewReader(content)), nil); err != nil {
+		t.Fatalf("PutStream failed: %v", err)
+	}
+	if size, err := disk.size(ctx, "logs/pipe.log"); err != nil || size != int64(len(content)) {
+		t.Errorf("Expected size %d, got %d, %v", len(content), size, err)
+	}
+
+	if err := disk.putStream(ctx, "logs/file.log", bytes.NewReader(content), nil); err != nil {
+		t.Fatalf("PutStream failed: %v", err)
+	}
+	metadata, err := disk.disk.getMetadata(ctx, "logs/file.log")
+	if err != nil {
+		t.Fatalf("GetMetadata failed: %v", err)
+	}
+	if metadata.CustomHeaders[OriginalSizeHeader] != strconv.Itoa(len(content)) {
+		t.Errorf("Expected original size %d to be recorded, got %q", len(content), metadata.CustomHeaders[OriginalSizeHeader])
+	}
+}
+
+func TestCompressedDisk_SkipsCompressedContent(t *testing.T) {
+	ctx := context.Background()
+	backend := NewMemoryDisk()
+
+	disk, err := NewCompressedDisk(&CompressedDiskConfig{Disk: backend})
+	if err != nil {
+		t.Fatalf("Failed to create CompressedDisk: %v", err)
+	}
+
+	photo := []byte("\xff\xd8\xff\xe0 pretend this is a jpeg")
+	if err := disk.put(ctx, "photo.jpg", photo); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := disk.putWithMetadata(ctx, "clip", photo, &Metadata{ContentType: "video/mp4"}); err != nil {
+		t.Fatalf("PutWithMetadata failed: %v", err)
+	}
+
+	for _, path := range []string{"photo.jpg", "clip"} {
+		stored, _ := backend.get(ctx, path)
+		if !bytes.Equal(stored, photo) {
+			t.Errorf("%s should be stored as is", path)
+		}
+
+		data, err := disk.get(ctx, path)
+		if err != nil {
+			t.Fatalf("Get %s failed: %v", path, err)
+		}
+		if !bytes.Equal(data, photo) {
+			t.Errorf("%s content mismatch", path)
+		}
+	}
+}
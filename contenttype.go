@@ -0,0 +1,186 @@
+package gostorage
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+)
+
+// sniffLen is the number of bytes http.DetectContentType looks at
+const sniffLen = 512
+
+// ContentTypeDetector detects the content type of a file from its extension,
+// falling back to sniffing the first bytes of its content
+type ContentTypeDetector struct {
+	mu         sync.RWMutex
+	extensions map[string]string
+}
+
+// DefaultContentTypeDetector is used by disks that do not configure their own detector
+var DefaultContentTypeDetector = NewContentTypeDetector()
+
+// NewContentTypeDetector creates a detector using the system MIME types and content sniffing
+func NewContentTypeDetector() *ContentTypeDetector {
+	return &ContentTypeDetector{
+		extensions: make(map[string]string),
+	}
+}
+
+// Register maps a file extension such as ".webmanifest" to a content type, overriding the system MIME types
+func (d *ContentTypeDetector) Register(ext, contentType string) {
+	ext = strings.ToLower(ext)
+	if !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.extensions[ext] = contentType
+}
+
+// Detect returns the content type of a file from its name, or from the first bytes of its content
+func (d *ContentTypeDetector) Detect(name string, head []byte) string {
+	if contentType := d.byExtension(name); contentType != "" {
+		return contentType
+	}
+	return http.DetectContentType(head)
+}
+
+// implies reports whether the extension of a file determines its content type, false for a nil detector
+func (d *ContentTypeDetector) implies(name string) bool {
+	return d != nil && d.byExtension(name) != ""
+}
+
+// byExtension returns the content type registered for the extension of a file, or ""
+func (d *ContentTypeDetector) byExtension(name string) string {
+	ext := strings.ToLower(path.Ext(name))
+	if ext == "" {
+		return ""
+	}
+
+	d.mu.RLock()
+	contentType, ok := d.extensions[ext]
+	d.mu.RUnlock()
+	if ok {
+		return contentType
+	}
+
+	return mime.TypeByExtension(ext)
+}
+
+// detectMetadata returns metadata with the detected content type filled in.
+// The caller's metadata is never modified, nil is returned unchanged when detection is disabled.
+func detectMetadata(detector *ContentTypeDetector, name string, head []byte, metadata *Metadata) *Metadata {
+	if detector == nil || (metadata != nil && metadata.ContentType != "") {
+		return metadata
+	}
+
+	detected := &Metadata{}
+	if metadata != nil {
+		copied := *metadata
+		detected = &copied
+	}
+	detected.ContentType = detector.Detect(name, head)
+
+	return detected
+}
+
+// detectStream is detectMetadata for a stream, it peeks at the start of the stream when
+// the extension is not enough and returns a reader that still yields the whole stream
+func detectStream(detector *ContentTypeDetector, name string, reader io.Reader, metadata *Metadata) (io.Reader, *Metadata, error) {
+	if detector == nil || (metadata != nil && metadata.ContentType != "") {
+		return reader, metadata, nil
+	}
+
+	if detector.byExtension(name) != "" {
+		return reader, detectMetadata(detector, name, nil, metadata), nil
+	}
+
+	reader, head, err := peek(reader, sniffLen)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return reader, detectMetadata(detector, name, head, metadata), nil
+}
+
+// peek reads up to n bytes from the start of a stream and returns a reader that still yields the whole stream
+func peek(reader io.Reader, n int) (io.Reader, []byte, error) {
+	// Rewind seekable readers instead of wrapping them, the S3 SDK needs to seek to sign the payload
+	seeker, seekable := reader.(io.ReadSeeker)
+	var start int64
+	if seekable {
+		var err error
+		if start, err = seeker.Seek(0, io.SeekCurrent); err != nil {
+			seekable = false
+		}
+	}
+
+	head := make([]byte, n)
+	read, err := io.ReadFull(reader, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, nil, err
+	}
+	head = head[:read]
+
+	if seekable {
+		if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+			return nil, nil, err
+		}
+		return seeker, head, nil
+	}
+
+	return io.MultiReader(bytes.NewReader(head), reader), head, nil
+}
+
+// matchContentType reports whether a content type matches any pattern such as "image/png" or "image/*".
+// Parameters such as "; charset=utf-8" are ignored.
+func matchContentType(contentType string, patterns []string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.ToLower(contentType)
+	}
+
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+		if strings.HasSuffix(pattern, "/*") {
+			if strings.HasPrefix(mediaType, strings.TrimSuffix(pattern, "*")) {
+				return true
+			}
+		} else if mediaType == pattern {
+			return true
+		}
+	}
+
+	return false
+}
+
+// contentTypeDetector returns the detector a disk uses, nil when detection is disabled
+func contentTypeDetector(detector *ContentTypeDetector, disabled bool) *ContentTypeDetector {
+	if disabled {
+		return nil
+	}
+	if detector == nil {
+		return DefaultContentTypeDetector
+	}
+	return detector
+}
+
+// detectingDisk is implemented by disks that fill in the content type of files written without one
+type detectingDisk interface {
+	typeDetector() *ContentTypeDetector
+}
+
+// diskDetector returns the detector of a disk, nil when its detection is disabled.
+// Wrappers that transform content use it to detect the type before the disk only sees the stored bytes,
+// disks that do not detect content types get DefaultContentTypeDetector.
+func diskDetector(d Disk) *ContentTypeDetector {
+	if detecting, ok := d.(detectingDisk); ok {
+		return detecting.typeDetector()
+	}
+	return DefaultContentTypeDetector
+}
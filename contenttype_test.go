@@ -0,0 +1,179 @@
+package gostorage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func TestContentTypeDetector(t *testing.T) {
+	detector := NewContentTypeDetector()
+	detector.Register("WEBMANIFEST", "application/manifest+json")
+	detector.Register(".json", "application/vnd.custom+json")
+
+	tests := []struct {
+		name string
+		head []byte
+		want string
+	}{
+		{"style.css", nil, "text/css; charset=utf-8"},
+		{"site.webmanifest", nil, "application/manifest+json"},
+		{"data.JSON", nil, "application/vnd.custom+json"},
+		{"avatar", pngHeader, "image/png"},
+		{"notes", []byte("plain text"), "text/plain; charset=utf-8"},
+		{"blob", nil, "text/plain; charset=utf-8"},
+		{"blob.unknownext", []byte{0x00, 0x01, 0x02}, "application/octet-stream"},
+	}
+
+	for _, tt := range tests {
+		if got := detector.Detect(tt.name, tt.head); got != tt.want {
+			t.Errorf("Detect(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDetectStream(t *testing.T) {
+	content := append(append([]byte{}, pngHeader...), bytes.Repeat([]byte{0xff}, 2*sniffLen)...)
+
+	// A plain reader is wrapped, a seekable one is rewound
+	readers := map[string]io.Reader{
+		"reader":   io.MultiReader(bytes.NewReader(content)),
+		"seekable": bytes.NewReader(content),
+	}
+
+	for name, reader := range readers {
+		got, metadata, err := detectStream(DefaultContentTypeDetector, "avatar", reader, nil)
+		if err != nil {
+			t.Fatalf("%s: detectStream failed: %v", name, err)
+		}
+		if metadata == nil || metadata.ContentType != "image/png" {
+			t.Errorf("%s: expected image/png, got %+v", name, metadata)
+		}
+
+		data, err := io.ReadAll(got)
+		if err != nil {
+			t.Fatalf("%s: read failed: %v", name, err)
+		}
+		if !bytes.Equal(data, content) {
+			t.Errorf("%s: stream content changed, got %d bytes", name, len(data))
+		}
+	}
+
+	// The caller's metadata is never modified
+	given := &Metadata{CustomHeaders: map[string]string{"owner": "me"}}
+	_, metadata, err := detectStream(DefaultContentTypeDetector, "notes.txt", strings.NewReader("hi"), given)
+	if err != nil {
+		t.Fatalf("detectStream failed: %v", err)
+	}
+	if given.ContentType != "" {
+		t.Error("detectStream modified the given metadata")
+	}
+	if metadata.ContentType != "text/plain; charset=utf-8" || metadata.CustomHeaders["owner"] != "me" {
+		t.Errorf("unexpected metadata: %+v", metadata)
+	}
+}
+
+func TestLocalDisk_ContentTypeDetection(t *testing.T) {
+	disk, err := NewLocalDisk(&LocalDiskConfig{Path: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create LocalDisk: %v", err)
+	}
+
+	ctx := context.Background()
+
+	if err := disk.put(ctx, "avatar", pngHeader); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := disk.putStream(ctx, "index.html", strings.NewReader("<p>hi</p>"), nil); err != nil {
+		t.Fatalf("PutStream failed: %v", err)
+	}
+	if err := disk.putWithMetadata(ctx, "data.bin", []byte("{}"), &Metadata{ContentType: "application/json"}); err != nil {
+		t.Fatalf("PutWithMetadata failed: %v", err)
+	}
+
+	want := map[string]string{
+		"avatar":     "image/png",
+		"index.html": "text/html; charset=utf-8",
+		"data.bin":   "application/json",
+	}
+	for path, contentType := range want {
+		metadata, err := disk.getMetadata(ctx, path)
+		if err != nil {
+			t.Fatalf("GetMetadata failed: %v", err)
+		}
+		if metadata == nil || metadata.ContentType != contentType {
+			t.Errorf("%s: expected %q, got %+v", path, contentType, metadata)
+		}
+	}
+
+	// Types implied by the extension are derived on read, plain writes then need no sidecar
+	if _, err := os.Stat(filepath.Join(disk.config.Path, "index.html.metadata.json")); !os.IsNotExist(err) {
+		t.Errorf("expected no sidecar for index.html, got %v", err)
+	}
+
+	// Detection can be turned off
+	disabled, err := NewLocalDisk(&LocalDiskConfig{Path: t.TempDir(), DisableContentTypeDetection: true})
+	if err != nil {
+		t.Fatalf("Failed to create LocalDisk: %v", err)
+	}
+	if err := disabled.put(ctx, "avatar", pngHeader); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	metadata, err := disabled.getMetadata(ctx, "avatar")
+	if err != nil {
+		t.Fatalf("GetMetadata failed: %v", err)
+	}
+	if metadata != nil {
+		t.Errorf("expected no metadata, got %+v", metadata)
+	}
+}
+
+func TestContentTypeDetection_Wrappers(t *testing.T) {
+	ctx := context.Background()
+
+	backend, err := NewLocalDisk(&LocalDiskConfig{Path: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create LocalDisk: %v", err)
+	}
+
+	_, _, keys := newTestEncryptedDisk(t)
+	encrypted, err := NewEncryptedDisk(backend, keys)
+	if err != nil {
+		t.Fatalf("Failed to create EncryptedDisk: %v", err)
+	}
+
+	compressed, err := NewCompressedDisk(&CompressedDiskConfig{Disk: backend})
+	if err != nil {
+		t.Fatalf("Failed to create CompressedDisk: %v", err)
+	}
+
+	// The backend only sees ciphertext or compressed bytes, the wrappers detect the type of the content
+	for name, disk := range map[string]Disk{"encrypted": encrypted, "compressed": compressed} {
+		if err := disk.put(ctx, name+"/avatar", pngHeader); err != nil {
+			t.Fatalf("%s: Put failed: %v", name, err)
+		}
+		if err := disk.putStream(ctx, name+"/notes", strings.NewReader("plain text"), nil); err != nil {
+			t.Fatalf("%s: PutStream failed: %v", name, err)
+		}
+
+		want := map[string]string{
+			name + "/avatar": "image/png",
+			name + "/notes":  "text/plain; charset=utf-8",
+		}
+		for path, contentType := range want {
+			metadata, err := disk.getMetadata(ctx, path)
+			if err != nil {
+				t.Fatalf("%s: GetMetadata failed: %v", name, err)
+			}
+			if metadata == nil || metadata.ContentType != contentType {
+				t.Errorf("%s: expected %q, got %+v", path, contentType, metadata)
+			}
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package gostorage
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DeleteDirectory deletes every file below prefix, "docs" covers "docs/a.txt" and "docs/sub/b.txt"
+// but not "docs2/c.txt". Local directories left empty are removed as well.
+// Every file is reported, followed by any directory that could not be removed.
+// The error is non-nil when listing fails or any file or directory could not be deleted.
+// S3 deletes up to 1000 files per request.
+func (s *Storage) DeleteDirectory(ctx context.Context, disk string, prefix string, opts ...BatchOption) ([]ObjectResult, error) {
+	d := s.getDisk(disk)
+	if d == nil {
+		return nil, ErrDiskNotFound(disk)
+	}
+
+	options := newBatchOptions(opts)
+
+	start := time.Now()
+	results, err := deleteDirectory(ctx, d, prefix, options.concurrency)
+	s.logOperation(ctx, "deleteDirectory", disk, prefix, start, err)
+
+	for _, result := range results {
+		if result.Err == nil {
+			s.emit(ctx, Event{Type: EventDelete, Disk: disk, Path: result.Path, Size: -1, Operation: "DeleteDirectory"})
+		}
+	}
+
+	return results, err
+}
+
+// CopyDirectory copies every file below sourcePrefix to the same relative path below destPrefix.
+// Every file is reported, the error is non-nil when listing fails or any file could not be copied.
+func (s *Storage) CopyDirectory(ctx context.Context, disk string, sourcePrefix, destPrefix string, opts ...BatchOption) ([]ObjectResult, error) {
+	d := s.getDisk(disk)
+	if d == nil {
+		return nil, ErrDiskNotFound(disk)
+	}
+
+	options := newBatchOptions(opts)
+
+	start := time.Now()
+	results, err := transferDirectory(ctx, d, "copyDirectory", sourcePrefix, destPrefix, options.concurrency, d.copy)
+	s.logOperation(ctx, "copyDirectory", disk, sourcePrefix, start, err)
+
+	s.emitTransfers(ctx, EventCopy, disk, "CopyDirectory", results)
+	return results, err
+}
+
+// MoveDirectory moves every file below sourcePrefix to the same relative path below destPrefix.
+// Local directories left empty are removed, those that could not be are reported after the files.
+// Files that failed to move stay where they were.
+func (s *Storage) MoveDirectory(ctx context.Context, disk string, sourcePrefix, destPrefix string, opts ...BatchOption) ([]ObjectResult, error) {
+	d := s.getDisk(disk)
+	if d == nil {
+		return nil, ErrDiskNotFound(disk)
+	}
+
+	options := newBatchOptions(opts)
+
+	start := time.Now()
+	results, err := transferDirectory(ctx, d, "moveDirectory", sourcePrefix, destPrefix, options.concurrency, d.move)
+	s.logOperation(ctx, "moveDirectory", disk, sourcePrefix, start, err)
+
+	s.emitTransfers(ctx, EventMove, disk, "MoveDirectory", results)
+	return results, err
+}
+
+// emitTransfers emits an event for every successful copy or move
+func (s *Storage) emitTransfers(ctx context.Context, eventType EventType, disk string, operation string, results []ObjectResult) {
+	for _, result := range results {
+		if result.Err != nil {
+			continue
+		}
+
+		s.emit(ctx, Event{
+			Type:       eventType,
+			Disk:       disk,
+			Path:       result.Path,
+			SourceDisk: disk,
+			SourcePath: result.SourcePath,
+			Size:       -1,
+			Operation:  operation,
+		})
+	}
+}
+
+// directoryPrefix validates a directory prefix and returns it without surrounding slashes
+func directoryPrefix(prefix string) (string, error) {
+	dir, err := relativePrefix(prefix)
+	if err != nil {
+		return "", err
+	}
+
+	if dir == "" {
+		return "", fmt.Errorf("%w: a directory is required", ErrInvalidPath)
+	}
+
+	return dir, nil
+}
+
+// listDirectory returns the files below dir and, on disks that have them, its directories
+// including dir itself, deepest first
+func listDirectory(ctx context.Context, d Disk, dir string) (files []string, dirs []string, err error) {
+	entries, err := d.list(ctx, dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, entry := range entries {
+		p := filepath.ToSlash(entry.Path)
+
+		// Disks that match prefixes as strings also list "docs2/a.txt" for "docs"
+		if entry.IsDir {
+			if p == dir || strings.HasPrefix(p, dir+"/") {
+				dirs = append(dirs, p)
+			}
+			continue
+		}
+		if strings.HasPrefix(p, dir+"/") {
+			files = append(files, p)
+		}
+	}
+
+	sort.Slice(dirs, func(i, j int) bool {
+		return strings.Count(dirs[i], "/") > strings.Count(dirs[j], "/")
+	})
+
+	return files, dirs, nil
+}
+
+// directoryRemover is implemented by disks with real directories, which remain after their files are deleted
+type directoryRemover interface {
+	removeDirectory(ctx context.Context, path string) error
+}
+
+// removeDiskDirectory removes an empty directory on disks that have real directories
+func removeDiskDirectory(ctx context.Context, d Disk, path string) error {
+	if r, ok := d.(directoryRemover); ok {
+		return r.removeDirectory(ctx, path)
+	}
+	return nil
+}
+
+// removeEmptyDirectories removes directories deepest first, directories that still hold files are kept.
+// Directories that could not be removed are returned as failed results.
+func removeEmptyDirectories(ctx context.Context, d Disk, dirs []string) []ObjectResult {
+	var failed []ObjectResult
+	for _, dir := range dirs {
+		if err := removeDiskDirectory(ctx, d, dir); err != nil {
+			failed = append(failed, ObjectResult{Path: dir, Err: err})
+		}
+	}
+	return failed
+}
+
+// deleteDirectory deletes every file below prefix and then the directories left empty
+func deleteDirectory(ctx context.Context, d Disk, prefix string, concurrency int) ([]ObjectResult, error) {
+	dir, err := directoryPrefix(prefix)
+	if err != nil {
+		return nil, &PathError{Op: "deleteDirectory", Path: prefix, Err: err}
+	}
+
+	files, dirs, err := listDirectory(ctx, d, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	errs := deleteFiles(ctx, d, files, concurrency)
+
+	results := make([]ObjectResult, len(files))
+	for i, file := range files {
+		results[i] = ObjectResult{Path: file, Err: errs[i]}
+	}
+
+	results = append(results, removeEmptyDirectories(ctx, d, dirs)...)
+
+	return results, resultsError("deleteDirectory", prefix, results)
+}
+
+// transferDirectory copies or moves every file below sourcePrefix to destPrefix
+func transferDirectory(ctx context.Context, d Disk, op string, sourcePrefix, destPrefix string, concurrency int, transfer func(ctx context.Context, sourcePath, destPath string) error) ([]ObjectResult, error) {
+	sourceDir, err := directoryPrefix(sourcePrefix)
+	if err != nil {
+		return nil, &PathError{Op: op, Path: sourcePrefix, Err: err}
+	}
+
+	destDir, err := directoryPrefix(destPrefix)
+	if err != nil {
+		return nil, &PathError{Op: op, Path: destPrefix, Err: err}
+	}
+
+	// Copying "docs" into "docs/backup" would pick up its own copies
+	if destDir == sourceDir || strings.HasPrefix(destDir, sourceDir+"/") {
+		return nil, &PathError{Op: op, Path: destPrefix, Err: fmt.Errorf("%w: directories overlap", ErrInvalidPath)}
+	}
+
+	files, dirs, err := listDirectory(ctx, d, sourceDir)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]ObjectResult, len(files))
+	for i, file := range files {
+		results[i] = ObjectResult{
+			Path:       destDir + "/" + strings.TrimPrefix(file, sourceDir+"/"),
+			SourcePath: file,
+		}
+	}
+
+	errs := runConcurrently(ctx, len(results), concurrency, func(i int) error {
+		return transfer(ctx, results[i].SourcePath, results[i].Path)
+	})
+	for i, err := range errs {
+		results[i].Err = err
+	}
+
+	if op == "moveDirectory" {
+		results = append(results, removeEmptyDirectories(ctx, d, dirs)...)
+	}
+
+	return results, resultsError(op, sourcePrefix, results)
+}
+
+// relativePrefix validates a prefix and returns it without surrounding slashes, "" for the whole disk
+func relativePrefix(prefix string) (string, error) {
+	validPrefix, err := ValidatePrefix(prefix)
+	if err != nil {
+		return "", err
+	}
+
+	dir := strings.Trim(filepath.ToSlash(validPrefix), "/")
+	if dir == "." {
+		return "", nil
+	}
+
+	return dir, nil
+}
+
+// prefixContains reports whether dir is inside parent, every directory is inside ""
+func prefixContains(parent, dir string) bool {
+	return parent == "" || dir == parent || strings.HasPrefix(dir, parent+"/")
+}
+
+// joinPrefix joins a directory prefix and a relative path
+func joinPrefix(dir, rel string) string {
+	if dir == "" {
+		return rel
+	}
+	return dir + "/" + rel
+}
+
+// listFiles returns the files below dir by their path relative to dir, directories are left out
+func listFiles(ctx context.Context, d Disk, dir string) (map[string]FileInfo, error) {
+	entries, err := d.list(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]FileInfo, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir {
+			continue
+		}
+
+		p := filepath.ToSlash(entry.Path)
+		switch {
+		case dir == "":
+			files[p] = entry
+		case strings.HasPrefix(p, dir+"/"):
+			// Disks that match prefixes as strings also list "docs2/a.txt" for "docs"
+			files[strings.TrimPrefix(p, dir+"/")] = entry
+		}
+	}
+
+	return files, nil
+}
@@ -0,0 +1,212 @@
+package gostorage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+// newDirectoryTestStorage returns a Storage with a memory and a local disk holding the same files
+func newDirectoryTestStorage(t *testing.T) (*Storage, string) {
+	tmpDir := t.TempDir()
+	local, err := NewLocalDisk(&LocalDiskConfig{Path: tmpDir})
+	if err != nil {
+		t.Fatalf("Failed to create LocalDisk: %v", err)
+	}
+
+	storage := NewStorage()
+	storage.AddDisk("memory", NewMemoryDisk())
+	storage.AddDisk("local", local)
+
+	ctx := context.Background()
+	for _, disk := range []string{"memory", "local"} {
+		for _, path := range []string{"docs/a.txt", "docs/sub/b.txt", "docs2/c.txt"} {
+			if err := storage.Put(ctx, disk, path, []byte(path)); err != nil {
+				t.Fatalf("Put failed: %v", err)
+			}
+		}
+	}
+
+	return storage, tmpDir
+}
+
+// resultPaths returns the sorted paths of successful results
+func resultPaths(results []ObjectResult) []string {
+	var paths []string
+	for _, result := range results {
+		if result.Err == nil {
+			paths = append(paths, result.Path)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func TestStorage_DeleteDirectory(t *testing.T) {
+	storage, tmpDir := newDirectoryTestStorage(t)
+	ctx := context.Background()
+
+	for _, disk := range []string{"memory", "local"} {
+		results, err := storage.DeleteDirectory(ctx, disk, "/docs/")
+		if err != nil {
+			t.Fatalf("DeleteDirectory failed: %v", err)
+		}
+
+		paths := resultPaths(results)
+		if len(paths) != 2 || paths[0] != "docs/a.txt" || paths[1] != "docs/sub/b.txt" {
+			t.Errorf("%s: unexpected results: %v", disk, paths)
+		}
+
+		if exists, _ := storage.Exists(ctx, disk, "docs/sub/b.txt"); exists {
+			t.Errorf("%s: deleted file still exists", disk)
+		}
+		if exists, _ := storage.Exists(ctx, disk, "docs2/c.txt"); !exists {
+			t.Errorf("%s: file outside the directory was deleted", disk)
+		}
+	}
+
+	// The local directory is gone, including the sidecars written by content type detection
+	if _, err := os.Stat(filepath.Join(tmpDir, "docs")); !os.IsNotExist(err) {
+		t.Errorf("expected the docs directory to be removed, got %v", err)
+	}
+
+	if _, err := storage.DeleteDirectory(ctx, "memory", "/"); !errors.Is(err, ErrInvalidPath) {
+		t.Errorf("expected deleting the root to be rejected, got %v", err)
+	}
+}
+
+func TestStorage_DeleteDirectory_PartialFailure(t *testing.T) {
+	memory := NewMemoryDisk()
+	disk, err := NewPolicyDisk(memory, &Policy{
+		Rules: []PolicyRule{
+			{Prefix: "docs/locked", Operations: []Operation{OpDelete}, Allow: false},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create PolicyDisk: %v", err)
+	}
+
+	storage := NewStorage()
+	storage.AddDisk("disk", disk)
+
+	ctx := context.Background()
+	for _, path := range []string{"docs/a.txt", "docs/locked/b.txt", "docs/c.txt"} {
+		if err := storage.Put(ctx, "disk", path, []byte(path)); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+
+	results, err := storage.DeleteDirectory(ctx, "disk", "docs", WithConcurrency(1))
+	if err == nil {
+		t.Fatal("expected an error for the locked file")
+	}
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(results))
+	}
+
+	for _, result := range results {
+		locked := result.Path == "docs/locked/b.txt"
+		if locked != errors.Is(result.Err, ErrPermissionDenied) {
+			t.Errorf("unexpected result for %s: %v", result.Path, result.Err)
+		}
+	}
+
+	if exists, _ := storage.Exists(ctx, "disk", "docs/locked/b.txt"); !exists {
+		t.Error("locked file should not have been deleted")
+	}
+}
+
+func TestStorage_CopyAndMoveDirectory(t *testing.T) {
+	storage, tmpDir := newDirectoryTestStorage(t)
+	ctx := context.Background()
+
+	for _, disk := range []string{"memory", "local"} {
+		results, err := storage.CopyDirectory(ctx, disk, "docs", "backup/docs")
+		if err != nil {
+			t.Fatalf("CopyDirectory failed: %v", err)
+		}
+		paths := resultPaths(results)
+		if len(paths) != 2 || paths[0] != "backup/docs/a.txt" || paths[1] != "backup/docs/sub/b.txt" {
+			t.Errorf("%s: unexpected copy results: %v", disk, paths)
+		}
+
+		results, err = storage.MoveDirectory(ctx, disk, "docs", "archive")
+		if err != nil {
+			t.Fatalf("MoveDirectory failed: %v", err)
+		}
+		for _, result := range results {
+			if result.SourcePath == "docs/sub/b.txt" && result.Path != "archive/sub/b.txt" {
+				t.Errorf("%s: unexpected move destination %s", disk, result.Path)
+			}
+		}
+
+		content, err := storage.Get(ctx, disk, "archive/sub/b.txt")
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		if string(content) != "docs/sub/b.txt" {
+			t.Errorf("%s: unexpected content %q", disk, content)
+		}
+
+		if exists, _ := storage.Exists(ctx, disk, "docs/a.txt"); exists {
+			t.Errorf("%s: moved file still exists at its source", disk)
+		}
+		if exists, _ := storage.Exists(ctx, disk, "backup/docs/a.txt"); !exists {
+			t.Errorf("%s: copied file is missing", disk)
+		}
+
+		if _, err := storage.CopyDirectory(ctx, disk, "archive", "archive/nested"); !errors.Is(err, ErrInvalidPath) {
+			t.Errorf("%s: expected overlapping directories to be rejected, got %v", disk, err)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(tmpDir, "docs")); !os.IsNotExist(err) {
+		t.Errorf("expected the moved directory to be removed, got %v", err)
+	}
+}
+
+func TestStorage_DeleteDirectory_Union(t *testing.T) {
+	topDir, lowerDir := t.TempDir(), t.TempDir()
+	top, err := NewLocalDisk(&LocalDiskConfig{Path: topDir})
+	if err != nil {
+		t.Fatalf("Failed to create LocalDisk: %v", err)
+	}
+	lower, err := NewLocalDisk(&LocalDiskConfig{Path: lowerDir})
+	if err != nil {
+		t.Fatalf("Failed to create LocalDisk: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(lowerDir, "docs", "empty"), 0755); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+
+	union, err := NewUnionDisk(top, lower)
+	if err != nil {
+		t.Fatalf("Failed to create UnionDisk: %v", err)
+	}
+
+	storage := NewStorage()
+	storage.AddDisk("union", union)
+
+	ctx := context.Background()
+	if err := storage.Put(ctx, "union", "docs/a.txt", []byte("a")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	if _, err := storage.DeleteDirectory(ctx, "union", "docs"); err != nil {
+		t.Fatalf("DeleteDirectory failed: %v", err)
+	}
+
+	// Directories are not files, removing them must not write whiteouts
+	err = filepath.WalkDir(topDir, func(path string, entry os.DirEntry, err error) error {
+		if err == nil && !entry.IsDir() {
+			t.Errorf("unexpected file %s", path)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatalf("WalkDir failed: %v", err)
+	}
+}
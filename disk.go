@@ -12,6 +12,19 @@ type Metadata struct {
 	Size          int64
 	LastModified  time.Time
 	CustomHeaders map[string]string
+	ETag          string
+	StorageClass  string
+
+	// Standard HTTP headers, stored natively on S3 and in the sidecar file on local disks
+	CacheControl       string
+	ContentDisposition string
+	ContentEncoding    string
+	ContentLanguage    string
+	Expires            time.Time
+
+	// Visibility is applied when writing, empty keeps the disk's default.
+	// GetMetadata may leave it empty, use Storage.GetVisibility to read it.
+	Visibility Visibility
 }
 
 // FileInfo represents file information
@@ -44,4 +57,7 @@ type Disk interface {
 	putWithMetadata(ctx context.Context, path string, content []byte, metadata *Metadata) error
 	getMetadata(ctx context.Context, path string) (*Metadata, error)
 	setMetadata(ctx context.Context, path string, metadata *Metadata) error
+
+	// Health check
+	ping(ctx context.Context) error
 }
@@ -0,0 +1,428 @@
+package gostorage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DiskFS exposes a Disk as a read-only fs.FS, so it can be used with http.FS,
+// template.ParseFS, fs.WalkDir and friends. Directories are derived from listings,
+// which also makes them work on backends without real directories such as S3.
+type DiskFS struct {
+	disk Disk
+	ctx  context.Context
+}
+
+var (
+	_ fs.ReadDirFS  = (*DiskFS)(nil)
+	_ fs.ReadFileFS = (*DiskFS)(nil)
+	_ fs.StatFS     = (*DiskFS)(nil)
+	_ fs.GlobFS     = (*DiskFS)(nil)
+)
+
+// NewDiskFS creates a new DiskFS for the given disk
+func NewDiskFS(d Disk) *DiskFS {
+	return &DiskFS{
+		disk: d,
+		ctx:  context.Background(),
+	}
+}
+
+// FS returns a read-only fs.FS view of a disk
+func (s *Storage) FS(disk string) (*DiskFS, error) {
+	d := s.getDisk(disk)
+	if d == nil {
+		return nil, ErrDiskNotFound(disk)
+	}
+
+	return NewDiskFS(d), nil
+}
+
+// WithContext returns a copy of the file system that uses ctx for disk operations
+func (f *DiskFS) WithContext(ctx context.Context) *DiskFS {
+	return &DiskFS{
+		disk: f.disk,
+		ctx:  ctx,
+	}
+}
+
+// Open implements fs.FS
+func (f *DiskFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	info, err := f.stat(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	if info.IsDir() {
+		entries, err := f.readDir(name)
+		if err != nil {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+		}
+		return &diskDir{info: info, entries: entries}, nil
+	}
+
+	reader, err := f.disk.getStream(f.ctx, name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fsError(err)}
+	}
+
+	return &diskFile{fs: f, name: name, info: info, reader: reader}, nil
+}
+
+// Stat implements fs.StatFS
+func (f *DiskFS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+
+	info, err := f.stat(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+
+	return info, nil
+}
+
+// ReadDir implements fs.ReadDirFS
+func (f *DiskFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+
+	info, err := f.stat(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+	if !info.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+
+	entries, err := f.readDir(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+
+	return entries, nil
+}
+
+// ReadFile implements fs.ReadFileFS
+func (f *DiskFS) ReadFile(name string) ([]byte, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: fs.ErrInvalid}
+	}
+
+	info, err := f.stat(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: err}
+	}
+	if info.IsDir() {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: errors.New("is a directory")}
+	}
+
+	content, err := f.disk.get(f.ctx, name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: fsError(err)}
+	}
+
+	return content, nil
+}
+
+// Glob implements fs.GlobFS with a single listing of the disk
+func (f *DiskFS) Glob(pattern string) ([]string, error) {
+	// Report malformed patterns the way fs.Glob does
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+
+	files, err := f.disk.list(f.ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var matches []string
+	for _, file := range files {
+		// Match the file and every directory above it
+		for name := file.Path; name != "." && name != "/" && name != ""; name = path.Dir(name) {
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+
+			if ok, _ := path.Match(pattern, name); ok {
+				matches = append(matches, name)
+			}
+		}
+	}
+
+	sort.Strings(matches)
+	return matches, nil
+}
+
+// stat looks a name up as a file or a directory
+func (f *DiskFS) stat(name string) (*diskFileInfo, error) {
+	if name == "." {
+		return &diskFileInfo{name: ".", dir: true}, nil
+	}
+
+	files, err := f.disk.list(f.ctx, name)
+	if err != nil {
+		return nil, fsError(err)
+	}
+
+	var dir *diskFileInfo
+	for _, file := range files {
+		switch {
+		case file.Path == name && !file.IsDir:
+			return newDiskFileInfo(path.Base(name), file), nil
+		case file.Path == name && file.IsDir:
+			dir = newDiskFileInfo(path.Base(name), file)
+		case dir == nil && strings.HasPrefix(file.Path, name+"/"):
+			// Directory implied by the files below it
+			dir = &diskFileInfo{name: path.Base(name), dir: true}
+		}
+	}
+
+	if dir == nil {
+		return nil, fs.ErrNotExist
+	}
+
+	return dir, nil
+}
+
+// readDir returns the sorted entries directly below a directory
+func (f *DiskFS) readDir(name string) ([]fs.DirEntry, error) {
+	prefix := ""
+	if name != "." {
+		prefix = name
+	}
+
+	files, err := f.disk.list(f.ctx, prefix)
+	if err != nil {
+		return nil, fsError(err)
+	}
+
+	children := make(map[string]*diskFileInfo)
+	for _, file := range files {
+		rel := file.Path
+		if prefix != "" {
+			if !strings.HasPrefix(rel, prefix+"/") {
+				continue
+			}
+			rel = strings.TrimPrefix(rel, prefix+"/")
+		}
+
+		child, _, nested := strings.Cut(rel, "/")
+		if child == "" {
+			continue
+		}
+
+		switch {
+		case nested:
+			// Directory implied by the files below it
+			if existing, ok := children[child]; !ok || !existing.dir {
+				children[child] = &diskFileInfo{name: child, dir: true}
+			}
+		case file.IsDir:
+			// The directory's own entry carries its real modification time
+			children[child] = newDiskFileInfo(child, file)
+		default:
+			if _, ok := children[child]; !ok {
+				children[child] = newDiskFileInfo(child, file)
+			}
+		}
+	}
+
+	entries := make([]fs.DirEntry, 0, len(children))
+	for _, child := range children {
+		entries = append(entries, child)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+
+	return entries, nil
+}
+
+// fsError translates disk errors into their io/fs equivalents
+func fsError(err error) error {
+	if errors.Is(err, ErrFileNotFound) {
+		return fs.ErrNotExist
+	}
+	return err
+}
+
+// diskFileInfo implements fs.FileInfo and fs.DirEntry
+type diskFileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	dir     bool
+	file    FileInfo
+}
+
+func newDiskFileInfo(name string, file FileInfo) *diskFileInfo {
+	return &diskFileInfo{
+		name:    name,
+		size:    file.Size,
+		modTime: file.LastModified,
+		dir:     file.IsDir,
+		file:    file,
+	}
+}
+
+func (i *diskFileInfo) Name() string               { return i.name }
+func (i *diskFileInfo) Size() int64                { return i.size }
+func (i *diskFileInfo) ModTime() time.Time         { return i.modTime }
+func (i *diskFileInfo) IsDir() bool                { return i.dir }
+func (i *diskFileInfo) Type() fs.FileMode          { return i.Mode().Type() }
+func (i *diskFileInfo) Info() (fs.FileInfo, error) { return i, nil }
+
+// Sys returns the FileInfo reported by the disk
+func (i *diskFileInfo) Sys() any { return i.file }
+
+func (i *diskFileInfo) Mode() fs.FileMode {
+	if i.dir {
+		return fs.ModeDir | 0555
+	}
+	return 0444
+}
+
+// diskFile is an open file of a DiskFS.
+// It implements io.Seeker for http.FileServer, seeking backwards reopens the stream
+// unless the disk's reader can seek itself.
+type diskFile struct {
+	fs     *DiskFS
+	name   string
+	info   *diskFileInfo
+	reader io.ReadCloser
+
+	// pos is the position of reader, offset is where the next Read starts
+	pos    int64
+	offset int64
+}
+
+func (f *diskFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+
+func (f *diskFile) Read(p []byte) (int, error) {
+	if err := f.reposition(); err != nil {
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: fsError(err)}
+	}
+	// Seeked past the end of the file
+	if f.pos < f.offset {
+		return 0, io.EOF
+	}
+
+	n, err := f.reader.Read(p)
+	f.pos += int64(n)
+	f.offset = f.pos
+	return n, err
+}
+
+// Seek implements io.Seeker, the stream is only moved by the next Read
+func (f *diskFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.info.size
+	default:
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrInvalid}
+	}
+	if offset < 0 {
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: errors.New("negative position")}
+	}
+
+	f.offset = offset
+	return offset, nil
+}
+
+// reposition moves the stream to offset, skipping forward or reopening it to go back
+func (f *diskFile) reposition() error {
+	if f.reader != nil && f.pos != f.offset {
+		if seeker, ok := f.reader.(io.Seeker); ok {
+			pos, err := seeker.Seek(f.offset, io.SeekStart)
+			if err != nil {
+				return err
+			}
+			f.pos = pos
+			return nil
+		}
+
+		if f.offset < f.pos {
+			err := f.reader.Close()
+			f.reader = nil
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if f.reader == nil {
+		reader, err := f.fs.disk.getStream(f.fs.ctx, f.name)
+		if err != nil {
+			return err
+		}
+		f.reader = reader
+		f.pos = 0
+	}
+
+	if f.pos < f.offset {
+		n, err := io.CopyN(io.Discard, f.reader, f.offset-f.pos)
+		f.pos += n
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (f *diskFile) Close() error {
+	if f.reader == nil {
+		return nil
+	}
+	return f.reader.Close()
+}
+
+// diskDir is an open directory of a DiskFS
+type diskDir struct {
+	info    *diskFileInfo
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *diskDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *diskDir) Close() error               { return nil }
+
+func (d *diskDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.name, Err: errors.New("is a directory")}
+}
+
+// ReadDir implements fs.ReadDirFile
+func (d *diskDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	remaining := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return remaining, nil
+	}
+
+	if len(remaining) == 0 {
+		return nil, io.EOF
+	}
+
+	n = min(n, len(remaining))
+	d.offset += n
+	return remaining[:n], nil
+}
@@ -0,0 +1,114 @@
+package gostorage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestDiskFS(t *testing.T) {
+	local, err := NewLocalDisk(&LocalDiskConfig{Path: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create LocalDisk: %v", err)
+	}
+
+	ctx := context.Background()
+	files := map[string]string{
+		"index.html":           "<h1>Home</h1>",
+		"css/site.css":         "body {}",
+		"templates/base.tmpl":  "{{ block \"content\" . }}{{ end }}",
+		"templates/pages/a.md": "# A",
+	}
+
+	for name, disk := range map[string]Disk{"local": local, "memory": NewMemoryDisk()} {
+		t.Run(name, func(t *testing.T) {
+			for path, content := range files {
+				if err := disk.put(ctx, path, []byte(content)); err != nil {
+					t.Fatalf("Put %s failed: %v", path, err)
+				}
+			}
+			// Metadata sidecars must stay invisible
+			if err := disk.putWithMetadata(ctx, "data.json", []byte("{}"), &Metadata{ContentType: "application/json"}); err != nil {
+				t.Fatalf("PutWithMetadata failed: %v", err)
+			}
+
+			fsys := NewDiskFS(disk)
+			if err := fstest.TestFS(fsys, "index.html", "css/site.css", "templates/base.tmpl", "templates/pages/a.md", "data.json"); err != nil {
+				t.Fatal(err)
+			}
+
+			matches, err := fs.Glob(fsys, "templates/*.tmpl")
+			if err != nil {
+				t.Fatalf("Glob failed: %v", err)
+			}
+			if len(matches) != 1 || matches[0] != "templates/base.tmpl" {
+				t.Errorf("Unexpected glob matches: %v", matches)
+			}
+
+			if _, err := fsys.Open("missing.txt"); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("Expected fs.ErrNotExist, got %v", err)
+			}
+		})
+	}
+}
+
+func TestDiskFS_HTTPFileServer(t *testing.T) {
+	ctx := context.Background()
+	memory := NewMemoryDisk()
+	if err := memory.put(ctx, "docs/notes", []byte("<html><body>hello world</body></html>")); err != nil {
+		t.Fatalf("put failed: %v", err)
+	}
+
+	server := httptest.NewServer(http.FileServer(http.FS(NewDiskFS(memory))))
+	defer server.Close()
+
+	get := func(rangeHeader string) (*http.Response, string) {
+		t.Helper()
+
+		req, err := http.NewRequest(http.MethodGet, server.URL+"/docs/notes", nil)
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		if rangeHeader != "" {
+			req.Header.Set("Range", rangeHeader)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("Failed to read body: %v", err)
+		}
+		return resp, string(body)
+	}
+
+	// The content type of a file without extension is sniffed, which seeks back to the start
+	resp, body := get("")
+	if resp.StatusCode != http.StatusOK || body != "<html><body>hello world</body></html>" {
+		t.Errorf("Expected full file, got %d %q", resp.StatusCode, body)
+	}
+	if contentType := resp.Header.Get("Content-Type"); !strings.HasPrefix(contentType, "text/html") {
+		t.Errorf("Expected sniffed text/html, got %q", contentType)
+	}
+
+	tests := map[string]string{
+		"bytes=12-16": "hello",
+		"bytes=-7":    "</html>",
+	}
+	for rangeHeader, want := range tests {
+		resp, body := get(rangeHeader)
+		if resp.StatusCode != http.StatusPartialContent || body != want {
+			t.Errorf("Range %s: expected 206 %q, got %d %q", rangeHeader, want, resp.StatusCode, body)
+		}
+	}
+}
@@ -0,0 +1,562 @@
+package gostorage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Encrypted objects are stored as a header followed by AES-256-GCM sealed chunks.
+//
+//	header: magic (4) | nonce prefix (8) | key ID length (1) | key ID | wrapped key length (2) | wrapped key
+//	chunk:  ciphertext of up to encryptionChunkSize bytes followed by a 16 byte tag
+//
+// Each chunk nonce is the nonce prefix followed by the big endian chunk counter, and the chunk
+// is authenticated together with a flag marking the final chunk, so that reordered, dropped or
+// truncated chunks are detected. Empty content is stored as a single empty final chunk.
+const (
+	encryptionMagic       = "GSE1"
+	encryptionChunkSize   = 64 * 1024
+	encryptionNoncePrefix = 8
+	encryptionKeySize     = 32
+)
+
+// KeyProvider wraps and unwraps the per-object data keys used by EncryptedDisk
+type KeyProvider interface {
+	// WrapKey encrypts a data key and returns it together with the ID of the key that wrapped it
+	WrapKey(ctx context.Context, dataKey []byte) (keyID string, wrapped []byte, err error)
+
+	// UnwrapKey decrypts a data key that was wrapped by the key with the given ID
+	UnwrapKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error)
+}
+
+// StaticKeyProvider wraps data keys with AES-256-GCM master keys held in memory.
+// To rotate, add a new key and point CurrentKeyID at it. Old keys must stay in Keys
+// for as long as objects wrapped with them need to be read.
+type StaticKeyProvider struct {
+	// Keys maps key IDs to 32 byte master keys
+	Keys map[string][]byte
+
+	// CurrentKeyID selects the master key used to wrap new data keys
+	CurrentKeyID string
+}
+
+// WrapKey implements KeyProvider
+func (p *StaticKeyProvider) WrapKey(_ context.Context, dataKey []byte) (string, []byte, error) {
+	aead, err := p.aead(p.CurrentKeyID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", nil, err
+	}
+
+	return p.CurrentKeyID, aead.Seal(nonce, nonce, dataKey, []byte(p.CurrentKeyID)), nil
+}
+
+// UnwrapKey implements KeyProvider
+func (p *StaticKeyProvider) UnwrapKey(_ context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	aead, err := p.aead(keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(wrapped) < aead.NonceSize() {
+		return nil, ErrDecryptionFailed
+	}
+
+	nonce, ciphertext := wrapped[:aead.NonceSize()], wrapped[aead.NonceSize():]
+	dataKey, err := aead.Open(nil, nonce, ciphertext, []byte(keyID))
+	if err != nil {
+		return nil, ErrDecryptionFailed
+	}
+
+	return dataKey, nil
+}
+
+// aead returns the cipher for a master key
+func (p *StaticKeyProvider) aead(keyID string) (cipher.AEAD, error) {
+	key, ok := p.Keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown encryption key %q", keyID)
+	}
+	if len(key) != encryptionKeySize {
+		return nil, fmt.Errorf("encryption key %q must be %d bytes", keyID, encryptionKeySize)
+	}
+	return newGCM(key)
+}
+
+// EncryptedDisk wraps a Disk and encrypts content on write and decrypts it on read.
+// Every object gets its own random data key, wrapped by the KeyProvider and stored in the object header.
+// Metadata and paths are not encrypted. Sizes are those of the decrypted content, list reads
+// every file's header to compute them.
+type EncryptedDisk struct {
+	disk Disk
+	keys KeyProvider
+}
+
+// NewEncryptedDisk creates a new EncryptedDisk on top of disk
+func NewEncryptedDisk(disk Disk, keys KeyProvider) (*EncryptedDisk, error) {
+	if disk == nil {
+		return nil, errors.New("disk cannot be nil")
+	}
+
+	if keys == nil {
+		return nil, errors.New("KeyProvider cannot be nil")
+	}
+
+	return &EncryptedDisk{
+		disk: disk,
+		keys: keys,
+	}, nil
+}
+
+// put encrypts and writes content
+func (d *EncryptedDisk) put(ctx context.Context, path string, content []byte) error {
+	return d.write(ctx, "put", path, bytes.NewReader(content), nil)
+}
+
+// get reads and decrypts content
+func (d *EncryptedDisk) get(ctx context.Context, path string) ([]byte, error) {
+	reader, err := d.open(ctx, "get", path)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, &PathError{Op: "get", Path: path, Err: err}
+	}
+
+	return content, nil
+}
+
+// delete removes a file
+func (d *EncryptedDisk) delete(ctx context.Context, path string) error {
+	return d.disk.delete(ctx, path)
+}
+
+// putStream encrypts content from a reader while writing it
+func (d *EncryptedDisk) putStream(ctx context.Context, path string, reader io.Reader, metadata *Metadata) error {
+	return d.write(ctx, "putStream", path, reader, metadata)
+}
+
+// getStream returns a reader that decrypts content while reading it
+func (d *EncryptedDisk) getStream(ctx context.Context, path string) (io.ReadCloser, error) {
+	return d.open(ctx, "getStream", path)
+}
+
+// exists checks if a file exists
+func (d *EncryptedDisk) exists(ctx context.Context, path string) (bool, error) {
+	return d.disk.exists(ctx, path)
+}
+
+// size returns the size of the decrypted content
+func (d *EncryptedDisk) size(ctx context.Context, path string) (int64, error) {
+	stored, err := d.disk.size(ctx, path)
+	if err != nil {
+		return 0, err
+	}
+
+	return d.plaintextSize(ctx, "size", path, stored)
+}
+
+// list returns a list of files matching a prefix with the sizes of the decrypted content
+func (d *EncryptedDisk) list(ctx context.Context, prefix string) ([]FileInfo, error) {
+	files, err := d.disk.list(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	return logicalSizes(ctx, files, d.size)
+}
+
+// copy copies a file, the wrapped data key travels with the content
+func (d *EncryptedDisk) copy(ctx context.Context, sourcePath, destPath string) error {
+	return d.disk.copy(ctx, sourcePath, destPath)
+}
+
+// move moves a file, the wrapped data key travels with the content
+func (d *EncryptedDisk) move(ctx context.Context, sourcePath, destPath string) error {
+	return d.disk.move(ctx, sourcePath, destPath)
+}
+
+// putWithMetadata encrypts and writes content together with metadata
+func (d *EncryptedDisk) putWithMetadata(ctx context.Context, path string, content []byte, metadata *Metadata) error {
+	return d.write(ctx, "putWithMetadata", path, bytes.NewReader(content), metadata)
+}
+
+// getMetadata retrieves metadata for a file, reporting the decrypted size
+func (d *EncryptedDisk) getMetadata(ctx context.Context, path string) (*Metadata, error) {
+	metadata, err := d.disk.getMetadata(ctx, path)
+	if err != nil || metadata == nil {
+		return metadata, err
+	}
+
+	// The recorded size may be missing or come from the caller, the stored object is authoritative
+	size, err := d.size(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	metadata.Size = size
+
+	return metadata, nil
+}
+
+// setMetadata updates metadata for a file
+func (d *EncryptedDisk) setMetadata(ctx context.Context, path string, metadata *Metadata) error {
+	return d.disk.setMetadata(ctx, path, metadata)
+}
+
+// write encrypts reader into the underlying disk
+func (d *EncryptedDisk) write(ctx context.Context, op string, path string, reader io.Reader, metadata *Metadata) error {
+	// The underlying disk only sees ciphertext, detect the content type first unless the extension implies it
+	detector := diskDetector(d.disk)
+	if metadata != nil || !detector.implies(path) {
+		var err error
+		reader, metadata, err = detectStream(detector, path, reader, metadata)
+		if err != nil {
+			return &PathError{Op: op, Path: path, Err: err}
+		}
+	}
+
+	dataKey := make([]byte, encryptionKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return &PathError{Op: op, Path: path, Err: err}
+	}
+
+	keyID, wrapped, err := d.keys.WrapKey(ctx, dataKey)
+	if err != nil {
+		return &PathError{Op: op, Path: path, Err: err}
+	}
+
+	noncePrefix := make([]byte, encryptionNoncePrefix)
+	if _, err := rand.Read(noncePrefix); err != nil {
+		return &PathError{Op: op, Path: path, Err: err}
+	}
+
+	header, err := encodeEncryptionHeader(noncePrefix, keyID, wrapped)
+	if err != nil {
+		return &PathError{Op: op, Path: path, Err: err}
+	}
+
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return &PathError{Op: op, Path: path, Err: err}
+	}
+
+	encrypted := io.MultiReader(bytes.NewReader(header), &encryptReader{
+		source:      bufio.NewReaderSize(reader, encryptionChunkSize),
+		aead:        aead,
+		noncePrefix: noncePrefix,
+		chunk:       make([]byte, encryptionChunkSize),
+	})
+
+	return d.disk.putStream(ctx, path, encrypted, metadata)
+}
+
+// open returns a decrypting reader for a file
+func (d *EncryptedDisk) open(ctx context.Context, op string, path string) (io.ReadCloser, error) {
+	stored, err := d.disk.getStream(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	source := bufio.NewReaderSize(stored, encryptionChunkSize+16)
+
+	header, err := readEncryptionHeader(source)
+	if err != nil {
+		stored.Close()
+		return nil, &PathError{Op: op, Path: path, Err: err}
+	}
+
+	dataKey, err := d.keys.UnwrapKey(ctx, header.keyID, header.wrappedKey)
+	if err != nil {
+		stored.Close()
+		return nil, &PathError{Op: op, Path: path, Err: err}
+	}
+
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		stored.Close()
+		return nil, &PathError{Op: op, Path: path, Err: err}
+	}
+
+	return &decryptReader{
+		source:      source,
+		closer:      stored,
+		aead:        aead,
+		noncePrefix: header.noncePrefix,
+		chunk:       make([]byte, encryptionChunkSize+aead.Overhead()),
+	}, nil
+}
+
+// plaintextSize converts a stored size into the size of the decrypted content
+func (d *EncryptedDisk) plaintextSize(ctx context.Context, op string, path string, stored int64) (int64, error) {
+	reader, err := d.disk.getStream(ctx, path)
+	if err != nil {
+		return 0, err
+	}
+	defer reader.Close()
+
+	header, err := readEncryptionHeader(bufio.NewReader(reader))
+	if err != nil {
+		return 0, &PathError{Op: op, Path: path, Err: err}
+	}
+
+	// Every chunk adds a tag, and there is at least one chunk
+	body := stored - int64(header.length)
+	fullChunk := int64(encryptionChunkSize + 16)
+	chunks := max((body+fullChunk-1)/fullChunk, 1)
+	size := body - chunks*16
+	if size < 0 {
+		return 0, &PathError{Op: op, Path: path, Err: ErrDecryptionFailed}
+	}
+
+	return size, nil
+}
+
+// encryptionHeader is the parsed header of an encrypted object
+type encryptionHeader struct {
+	noncePrefix []byte
+	keyID       string
+	wrappedKey  []byte
+	length      int
+}
+
+// encodeEncryptionHeader serializes an object header
+func encodeEncryptionHeader(noncePrefix []byte, keyID string, wrapped []byte) ([]byte, error) {
+	if len(keyID) > 255 {
+		return nil, errors.New("encryption key ID is longer than 255 bytes")
+	}
+	if len(wrapped) > 65535 {
+		return nil, errors.New("wrapped data key is longer than 65535 bytes")
+	}
+
+	header := make([]byte, 0, len(encryptionMagic)+len(noncePrefix)+1+len(keyID)+2+len(wrapped))
+	header = append(header, encryptionMagic...)
+	header = append(header, noncePrefix...)
+	header = append(header, byte(len(keyID)))
+	header = append(header, keyID...)
+	header = binary.BigEndian.AppendUint16(header, uint16(len(wrapped)))
+	header = append(header, wrapped...)
+
+	return header, nil
+}
+
+// readEncryptionHeader parses an object header from the start of a stream
+func readEncryptionHeader(r io.Reader) (*encryptionHeader, error) {
+	fixed := make([]byte, len(encryptionMagic)+encryptionNoncePrefix+1)
+	if _, err := io.ReadFull(r, fixed); err != nil {
+		return nil, ErrDecryptionFailed
+	}
+	if string(fixed[:len(encryptionMagic)]) != encryptionMagic {
+		return nil, fmt.Errorf("%w: not an encrypted object", ErrDecryptionFailed)
+	}
+
+	header := &encryptionHeader{
+		noncePrefix: fixed[len(encryptionMagic) : len(encryptionMagic)+encryptionNoncePrefix],
+	}
+
+	keyID := make([]byte, fixed[len(fixed)-1])
+	if _, err := io.ReadFull(r, keyID); err != nil {
+		return nil, ErrDecryptionFailed
+	}
+	header.keyID = string(keyID)
+
+	var wrappedLen uint16
+	if err := binary.Read(r, binary.BigEndian, &wrappedLen); err != nil {
+		return nil, ErrDecryptionFailed
+	}
+
+	header.wrappedKey = make([]byte, wrappedLen)
+	if _, err := io.ReadFull(r, header.wrappedKey); err != nil {
+		return nil, ErrDecryptionFailed
+	}
+
+	header.length = len(fixed) + len(keyID) + 2 + int(wrappedLen)
+	return header, nil
+}
+
+// newGCM creates an AES-GCM cipher
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// chunkNonce builds the nonce for a chunk
+func chunkNonce(prefix []byte, counter uint32) []byte {
+	nonce := make([]byte, 0, encryptionNoncePrefix+4)
+	nonce = append(nonce, prefix...)
+	return binary.BigEndian.AppendUint32(nonce, counter)
+}
+
+// chunkAdditionalData marks whether a chunk is the last one
+func chunkAdditionalData(final bool) []byte {
+	if final {
+		return []byte{1}
+	}
+	return []byte{0}
+}
+
+// encryptReader produces sealed chunks from a plaintext stream
+type encryptReader struct {
+	source      *bufio.Reader
+	aead        cipher.AEAD
+	noncePrefix []byte
+	chunk       []byte
+	counter     uint32
+	buffer      []byte
+	pending     []byte
+	done        bool
+}
+
+func (r *encryptReader) Read(p []byte) (int, error) {
+	for len(r.pending) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		if err := r.sealNext(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+// sealNext encrypts the next chunk into pending
+func (r *encryptReader) sealNext() error {
+	n, err := io.ReadFull(r.source, r.chunk)
+	final := false
+	switch {
+	case err == io.EOF || err == io.ErrUnexpectedEOF:
+		final = true
+	case err != nil:
+		return err
+	default:
+		// A full chunk is final only if nothing follows it
+		if _, err := r.source.Peek(1); err == io.EOF {
+			final = true
+		} else if err != nil {
+			return err
+		}
+	}
+
+	if r.counter == ^uint32(0) {
+		return errors.New("content too large to encrypt")
+	}
+
+	nonce := chunkNonce(r.noncePrefix, r.counter)
+	r.buffer = r.aead.Seal(r.buffer[:0], nonce, r.chunk[:n], chunkAdditionalData(final))
+	r.pending = r.buffer
+	r.counter++
+	r.done = final
+	return nil
+}
+
+// decryptReader opens sealed chunks from an encrypted stream
+type decryptReader struct {
+	source      *bufio.Reader
+	closer      io.Closer
+	aead        cipher.AEAD
+	noncePrefix []byte
+	chunk       []byte
+	counter     uint32
+	buffer      []byte
+	pending     []byte
+	done        bool
+}
+
+func (r *decryptReader) Read(p []byte) (int, error) {
+	for len(r.pending) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		if err := r.openNext(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+func (r *decryptReader) Close() error {
+	return r.closer.Close()
+}
+
+// openNext decrypts the next chunk into pending
+func (r *decryptReader) openNext() error {
+	n, err := io.ReadFull(r.source, r.chunk)
+	final := false
+	switch {
+	case err == io.EOF || err == io.ErrUnexpectedEOF:
+		final = true
+	case err != nil:
+		return err
+	default:
+		if _, err := r.source.Peek(1); err == io.EOF {
+			final = true
+		} else if err != nil {
+			return err
+		}
+	}
+
+	nonce := chunkNonce(r.noncePrefix, r.counter)
+	r.buffer, err = r.aead.Open(r.buffer[:0], nonce, r.chunk[:n], chunkAdditionalData(final))
+	if err != nil {
+		return ErrDecryptionFailed
+	}
+
+	r.pending = r.buffer
+	r.counter++
+	r.done = final
+	return nil
+}
+
+// ping checks the underlying disk
+func (d *EncryptedDisk) ping(ctx context.Context) error {
+	return d.disk.ping(ctx)
+}
+
+// setVisibility changes the visibility of a file.
+// There is no url, a public URL would serve the encrypted content.
+func (d *EncryptedDisk) setVisibility(ctx context.Context, path string, visibility Visibility) error {
+	return setDiskVisibility(ctx, d.disk, path, visibility)
+}
+
+// getVisibility returns the visibility of a file
+func (d *EncryptedDisk) getVisibility(ctx context.Context, path string) (Visibility, error) {
+	return diskVisibility(ctx, d.disk, path)
+}
+
+// deleteMany deletes files, in batches if the underlying disk supports it
+func (d *EncryptedDisk) deleteMany(ctx context.Context, paths []string, concurrency int) []error {
+	return deleteFiles(ctx, d.disk, paths, concurrency)
+}
+
+// typeDetector returns the detector of the underlying disk
+func (d *EncryptedDisk) typeDetector() *ContentTypeDetector {
+	return diskDetector(d.disk)
+}
+
+// removeDirectory removes an empty directory
+func (d *EncryptedDisk) removeDirectory(ctx context.Context, path string) error {
+	return removeDiskDirectory(ctx, d.disk, path)
+}
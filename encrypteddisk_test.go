@@ -0,0 +1,163 @@
+package gostorage
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"io"
+	"testing"
+)
+
+func newTestEncryptedDisk(t *testing.T) (*EncryptedDisk, *MemoryDisk, *StaticKeyProvider) {
+	t.Helper()
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	keys := &StaticKeyProvider{
+		Keys:         map[string][]byte{"v1": key},
+		CurrentKeyID: "v1",
+	}
+
+	backend := NewMemoryDisk()
+	disk, err := NewEncryptedDisk(backend, keys)
+	if err != nil {
+		t.Fatalf("Failed to create EncryptedDisk: %v", err)
+	}
+
+	return disk, backend, keys
+}
+
+func TestEncryptedDisk_RoundTrip(t *testing.T) {
+	disk, backend, _ := newTestEncryptedDisk(t)
+	ctx := context.Background()
+
+	sizes := []int{0, 1, encryptionChunkSize - 1, encryptionChunkSize, 3*encryptionChunkSize + 17}
+	for _, n := range sizes {
+		content := make([]byte, n)
+		if _, err := rand.Read(content); err != nil {
+			t.Fatalf("Failed to generate content: %v", err)
+		}
+
+		if err := disk.putStream(ctx, "doc.bin", bytes.NewReader(content), nil); err != nil {
+			t.Fatalf("PutStream of %d bytes failed: %v", n, err)
+		}
+
+		// Content must not be stored in plain text; short content may match ciphertext by chance
+		stored, _ := backend.get(ctx, "doc.bin")
+		if n >= 16 && bytes.Contains(stored, content) {
+			t.Errorf("Content of %d bytes stored unencrypted", n)
+		}
+
+		data, err := disk.get(ctx, "doc.bin")
+		if err != nil {
+			t.Fatalf("Get of %d bytes failed: %v", n, err)
+		}
+		if !bytes.Equal(data, content) {
+			t.Errorf("Round trip of %d bytes returned different content", n)
+		}
+
+		size, err := disk.size(ctx, "doc.bin")
+		if err != nil {
+			t.Fatalf("Size failed: %v", err)
+		}
+		if size != int64(n) {
+			t.Errorf("Expected size %d, got %d", n, size)
+		}
+
+		files, err := disk.list(ctx, "")
+		if err != nil {
+			t.Fatalf("List failed: %v", err)
+		}
+		if len(files) != 1 || files[0].Size != int64(n) {
+			t.Errorf("Expected list to report size %d, got %+v", n, files)
+		}
+	}
+
+	// A caller supplied size is not trusted
+	if err := disk.putWithMetadata(ctx, "note.txt", []byte("hello world"), &Metadata{Size: 1}); err != nil {
+		t.Fatalf("PutWithMetadata failed: %v", err)
+	}
+	metadata, err := disk.getMetadata(ctx, "note.txt")
+	if err != nil {
+		t.Fatalf("GetMetadata failed: %v", err)
+	}
+	if metadata.Size != 11 {
+		t.Errorf("Expected metadata size 11, got %d", metadata.Size)
+	}
+}
+
+func TestEncryptedDisk_Tampering(t *testing.T) {
+	disk, backend, _ := newTestEncryptedDisk(t)
+	ctx := context.Background()
+
+	content := bytes.Repeat([]byte("secret "), encryptionChunkSize/3)
+	if err := disk.put(ctx, "doc.txt", content); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	stored, _ := backend.get(ctx, "doc.txt")
+
+	// Flipped bit
+	tampered := bytes.Clone(stored)
+	tampered[len(tampered)-20] ^= 1
+	_ = backend.put(ctx, "doc.txt", tampered)
+	if _, err := disk.get(ctx, "doc.txt"); !errors.Is(err, ErrDecryptionFailed) {
+		t.Errorf("Expected ErrDecryptionFailed for modified content, got %v", err)
+	}
+
+	// Dropped final chunk
+	_ = backend.put(ctx, "doc.txt", stored[:len(stored)-(len(stored)-len(content))/2])
+	reader, err := disk.getStream(ctx, "doc.txt")
+	if err != nil {
+		t.Fatalf("GetStream failed: %v", err)
+	}
+	_, err = io.ReadAll(reader)
+	reader.Close()
+	if !errors.Is(err, ErrDecryptionFailed) {
+		t.Errorf("Expected ErrDecryptionFailed for truncated content, got %v", err)
+	}
+
+	// Plain content
+	_ = backend.put(ctx, "plain.txt", []byte("not encrypted"))
+	if _, err := disk.get(ctx, "plain.txt"); !errors.Is(err, ErrDecryptionFailed) {
+		t.Errorf("Expected ErrDecryptionFailed for plain content, got %v", err)
+	}
+}
+
+func TestEncryptedDisk_KeyRotation(t *testing.T) {
+	disk, _, keys := newTestEncryptedDisk(t)
+	ctx := context.Background()
+
+	if err := disk.put(ctx, "old.txt", []byte("written with v1")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	newKey := make([]byte, 32)
+	_, _ = rand.Read(newKey)
+	keys.Keys["v2"] = newKey
+	keys.CurrentKeyID = "v2"
+
+	if err := disk.put(ctx, "new.txt", []byte("written with v2")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	for path, want := range map[string]string{"old.txt": "written with v1", "new.txt": "written with v2"} {
+		data, err := disk.get(ctx, path)
+		if err != nil {
+			t.Fatalf("Get %s failed: %v", path, err)
+		}
+		if string(data) != want {
+			t.Errorf("Expected %q, got %q", want, data)
+		}
+	}
+
+	// Retiring the old key makes old objects unreadable
+	delete(keys.Keys, "v1")
+	if _, err := disk.get(ctx, "old.txt"); err == nil {
+		t.Error("Get should fail once the wrapping key is gone")
+	}
+}
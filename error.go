@@ -14,6 +14,16 @@ var (
 
 	// ErrOperationNotSupported is returned when an operation is not supported
 	ErrOperationNotSupported = errors.New("operation not supported")
+
+	// ErrPermissionDenied is returned when a policy does not allow an operation
+	ErrPermissionDenied = errors.New("permission denied")
+
+	// ErrDecryptionFailed is returned when encrypted content is corrupt, truncated or was encrypted with another key
+	ErrDecryptionFailed = errors.New("decryption failed")
+
+	// ErrValidationFailed is matched by every error a ValidatingDisk rejects an upload with,
+	// and by the limit and file name errors of Extract
+	ErrValidationFailed = errors.New("validation failed")
 )
 
 // DiskNotFoundError represents a disk not found error
@@ -43,3 +53,58 @@ func (e *PathError) Error() string {
 func (e *PathError) Unwrap() error {
 	return e.Err
 }
+
+// FileTooLargeError is returned when an upload exceeds a size limit
+type FileTooLargeError struct {
+	Limit int64
+}
+
+func (e *FileTooLargeError) Error() string {
+	return fmt.Sprintf("file exceeds the size limit of %d bytes", e.Limit)
+}
+
+func (e *FileTooLargeError) Is(target error) bool {
+	return target == ErrValidationFailed
+}
+
+// ContentTypeNotAllowedError is returned when the sniffed content type of an upload is not allowed
+type ContentTypeNotAllowedError struct {
+	ContentType string
+}
+
+func (e *ContentTypeNotAllowedError) Error() string {
+	return fmt.Sprintf("content type %s is not allowed", e.ContentType)
+}
+
+func (e *ContentTypeNotAllowedError) Is(target error) bool {
+	return target == ErrValidationFailed
+}
+
+// InvalidFilenameError is returned when a path does not satisfy a FilenamePolicy
+type InvalidFilenameError struct {
+	Name   string
+	Reason string
+}
+
+func (e *InvalidFilenameError) Error() string {
+	return fmt.Sprintf("invalid file name %q: %s", e.Name, e.Reason)
+}
+
+func (e *InvalidFilenameError) Is(target error) bool {
+	return target == ErrValidationFailed || target == ErrInvalidPath
+}
+
+// ArchiveLimitError is returned when an extracted archive exceeds a limit
+type ArchiveLimitError struct {
+	// Limit is the exceeded limit, "entries" or "total size"
+	Limit string
+	Max   int64
+}
+
+func (e *ArchiveLimitError) Error() string {
+	return fmt.Sprintf("archive exceeds the %s limit of %d", e.Limit, e.Max)
+}
+
+func (e *ArchiveLimitError) Is(target error) bool {
+	return target == ErrValidationFailed
+}
@@ -0,0 +1,86 @@
+package gostorage
+
+import (
+	"context"
+	"io"
+)
+
+// EventType identifies a storage lifecycle event
+type EventType string
+
+const (
+	// EventPut is emitted after a file has been written
+	EventPut EventType = "put"
+
+	// EventDelete is emitted after a file has been deleted
+	EventDelete EventType = "delete"
+
+	// EventCopy is emitted after a file has been copied
+	EventCopy EventType = "copy"
+
+	// EventMove is emitted after a file has been moved
+	EventMove EventType = "move"
+)
+
+// Event describes a successfully completed storage operation
+type Event struct {
+	// Type is the kind of event
+	Type EventType
+
+	// Disk and Path identify the file that was written or deleted.
+	// For copy and move events they identify the destination.
+	Disk string
+	Path string
+
+	// SourceDisk and SourcePath identify the source of copy and move events
+	SourceDisk string
+	SourcePath string
+
+	// Size is the number of bytes written, or -1 if it is not known without an extra round-trip
+	Size int64
+
+	// Metadata is the metadata supplied with the write, if any
+	Metadata *Metadata
+
+	// Operation is the Storage method that triggered the event (e.g. "PutStream", "MoveBetweenDisks")
+	Operation string
+}
+
+// EventHandler is called after a storage operation has completed successfully
+type EventHandler func(ctx context.Context, event Event)
+
+// On registers a handler for the given event type.
+// Handlers run synchronously in registration order, so slow work such as
+// thumbnail generation should be handed off to a queue.
+func (s *Storage) On(eventType EventType, handler EventHandler) {
+	s.handlersMu.Lock()
+	defer s.handlersMu.Unlock()
+
+	if s.handlers == nil {
+		s.handlers = make(map[EventType][]EventHandler)
+	}
+	s.handlers[eventType] = append(s.handlers[eventType], handler)
+}
+
+// emit calls every handler registered for the event type
+func (s *Storage) emit(ctx context.Context, event Event) {
+	s.handlersMu.RLock()
+	handlers := s.handlers[event.Type]
+	s.handlersMu.RUnlock()
+
+	for _, handler := range handlers {
+		handler(ctx, event)
+	}
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	reader io.Reader
+	n      int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,74 @@
+package gostorage
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestStorage_Events(t *testing.T) {
+	local, err := NewLocalDisk(&LocalDiskConfig{Path: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create LocalDisk: %v", err)
+	}
+	backup, err := NewLocalDisk(&LocalDiskConfig{Path: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create LocalDisk: %v", err)
+	}
+
+	storage := NewStorage()
+	storage.AddDisk("local", local)
+	storage.AddDisk("backup", backup)
+
+	var events []Event
+	record := func(_ context.Context, e Event) {
+		events = append(events, e)
+	}
+	storage.On(EventPut, record)
+	storage.On(EventDelete, record)
+	storage.On(EventCopy, record)
+	storage.On(EventMove, record)
+
+	ctx := context.Background()
+	meta := &Metadata{ContentType: "text/plain"}
+
+	if err := storage.PutStream(ctx, "local", "a.txt", bytes.NewReader([]byte("hello")), meta); err != nil {
+		t.Fatalf("PutStream failed: %v", err)
+	}
+	if err := storage.Copy(ctx, "local", "a.txt", "b.txt"); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	if err := storage.MoveBetweenDisks(ctx, "local", "backup", "b.txt", "c.txt"); err != nil {
+		t.Fatalf("MoveBetweenDisks failed: %v", err)
+	}
+	if err := storage.Delete(ctx, "local", "a.txt"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	// Failed operations do not emit events
+	if err := storage.Delete(ctx, "local", "missing.txt"); err == nil {
+		t.Fatal("Delete should fail for missing file")
+	}
+
+	if len(events) != 4 {
+		t.Fatalf("Expected 4 events, got %d: %+v", len(events), events)
+	}
+
+	put := events[0]
+	if put.Type != EventPut || put.Disk != "local" || put.Path != "a.txt" || put.Size != 5 || put.Metadata != meta || put.Operation != "PutStream" {
+		t.Errorf("Unexpected put event: %+v", put)
+	}
+
+	if events[1].Type != EventCopy || events[1].SourcePath != "a.txt" || events[1].Path != "b.txt" {
+		t.Errorf("Unexpected copy event: %+v", events[1])
+	}
+
+	move := events[2]
+	if move.Type != EventMove || move.SourceDisk != "local" || move.Disk != "backup" || move.Path != "c.txt" || move.Size != 5 {
+		t.Errorf("Unexpected move event: %+v", move)
+	}
+
+	if events[3].Type != EventDelete || events[3].Path != "a.txt" {
+		t.Errorf("Unexpected delete event: %+v", events[3])
+	}
+}
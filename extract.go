@@ -0,0 +1,313 @@
+package gostorage
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultExtractMaxEntries is the default maximum number of entries in an extracted archive
+	DefaultExtractMaxEntries = 10000
+
+	// DefaultExtractMaxTotalSize is the default maximum uncompressed size of an extracted archive (1 GiB)
+	DefaultExtractMaxTotalSize = 1 << 30
+)
+
+// ExtractOptions configures Extract
+type ExtractOptions struct {
+	// MaxEntries is the maximum number of entries, directories included (default: DefaultExtractMaxEntries)
+	MaxEntries int
+
+	// MaxFileSize is the maximum uncompressed size of a single file, 0 only applies MaxTotalSize
+	MaxFileSize int64
+
+	// MaxTotalSize is the maximum uncompressed size of all files together (default: DefaultExtractMaxTotalSize).
+	// Zip archives read from something other than an io.ReaderAt are buffered in memory up to this size.
+	MaxTotalSize int64
+
+	// ContentTypeDetector detects the content type of every extracted file (default: DefaultContentTypeDetector)
+	ContentTypeDetector *ContentTypeDetector
+
+	// DisableContentTypeDetection leaves the content type to the disk
+	DisableContentTypeDetection bool
+}
+
+// extractor writes the entries of an archive to a disk while enforcing the limits
+type extractor struct {
+	storage  *Storage
+	disk     string
+	d        Disk
+	dir      string
+	options  ExtractOptions
+	detector *ContentTypeDetector
+
+	entries int
+	total   int64
+	results []ObjectResult
+}
+
+// Extract unpacks a zip, tar or tar.gz archive below destPrefix on disk.
+// Every entry name has to be a clean relative path, names that are absolute, escape destPrefix
+// or contain backslashes are rejected, as are symbolic links, hard links and device files.
+// Directories are implied by the file paths and not created. Archives that exceed the entry or
+// size limits are rejected before the excess is decompressed.
+//
+// Zip archives are checked completely before anything is written, tar archives are checked while
+// streaming. An error stops the extraction, files written until then stay on the disk and are
+// listed in the results.
+func (s *Storage) Extract(ctx context.Context, reader io.Reader, format ArchiveFormat, disk string, destPrefix string, opts ExtractOptions) ([]ObjectResult, error) {
+	d := s.getDisk(disk)
+	if d == nil {
+		return nil, ErrDiskNotFound(disk)
+	}
+
+	start := time.Now()
+	results, err := s.extract(ctx, reader, format, d, disk, destPrefix, opts)
+	s.logOperation(ctx, "extract", disk, destPrefix, start, err)
+	return results, err
+}
+
+// extract validates the options and unpacks the archive
+func (s *Storage) extract(ctx context.Context, reader io.Reader, format ArchiveFormat, d Disk, disk string, destPrefix string, opts ExtractOptions) ([]ObjectResult, error) {
+	if err := validateArchiveFormat(format); err != nil {
+		return nil, &PathError{Op: "extract", Path: destPrefix, Err: err}
+	}
+
+	dir, err := relativePrefix(destPrefix)
+	if err != nil {
+		return nil, &PathError{Op: "extract", Path: destPrefix, Err: err}
+	}
+
+	if opts.MaxEntries <= 0 {
+		opts.MaxEntries = DefaultExtractMaxEntries
+	}
+	if opts.MaxTotalSize <= 0 {
+		opts.MaxTotalSize = DefaultExtractMaxTotalSize
+	}
+
+	e := &extractor{
+		storage:  s,
+		disk:     disk,
+		d:        d,
+		dir:      dir,
+		options:  opts,
+		detector: contentTypeDetector(opts.ContentTypeDetector, opts.DisableContentTypeDetection),
+	}
+
+	switch format {
+	case ArchiveZip:
+		err = e.extractZip(ctx, reader)
+	case ArchiveTarGz:
+		var gz *gzip.Reader
+		gz, err = gzip.NewReader(reader)
+		if err != nil {
+			return nil, &PathError{Op: "extract", Path: destPrefix, Err: err}
+		}
+		defer gz.Close()
+		err = e.extractTar(ctx, gz)
+	default:
+		err = e.extractTar(ctx, reader)
+	}
+
+	return e.results, err
+}
+
+// extractZip checks every entry of a zip archive, then extracts the files
+func (e *extractor) extractZip(ctx context.Context, reader io.Reader) error {
+	zr, err := e.openZip(reader)
+	if err != nil {
+		return err
+	}
+
+	// The central directory lists every entry and its size, check them all before writing
+	type zipEntry struct {
+		file *zip.File
+		path string
+	}
+	var files []zipEntry
+	for _, f := range zr.File {
+		if err := e.countEntry(f.Name); err != nil {
+			return err
+		}
+
+		mode := f.Mode()
+		if mode.IsDir() {
+			continue
+		}
+		if !mode.IsRegular() {
+			return unsafeEntry(f.Name, "only regular files and directories are allowed")
+		}
+
+		p, err := e.entryPath(f.Name)
+		if err != nil {
+			return err
+		}
+
+		if err := e.reserve(f.Name, int64(min(f.UncompressedSize64, 1<<62))); err != nil {
+			return err
+		}
+
+		files = append(files, zipEntry{file: f, path: p})
+	}
+
+	for _, entry := range files {
+		// The zip reader fails an entry that decompresses to more than its declared size
+		rc, err := entry.file.Open()
+		if err != nil {
+			return &PathError{Op: "extract", Path: entry.file.Name, Err: err}
+		}
+
+		err = e.write(ctx, entry.file.Name, entry.path, rc, int64(entry.file.UncompressedSize64))
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// openZip opens a zip archive, buffering readers that do not support random access
+func (e *extractor) openZip(reader io.Reader) (*zip.Reader, error) {
+	ra, ok := reader.(io.ReaderAt)
+	size, sized := remainingSize(reader)
+	if !ok || !sized {
+		data, err := io.ReadAll(io.LimitReader(reader, e.options.MaxTotalSize+1))
+		if err != nil {
+			return nil, &PathError{Op: "extract", Path: e.dir, Err: err}
+		}
+		if int64(len(data)) > e.options.MaxTotalSize {
+			return nil, &PathError{Op: "extract", Path: e.dir, Err: &ArchiveLimitError{Limit: "total size", Max: e.options.MaxTotalSize}}
+		}
+		ra, size = bytes.NewReader(data), int64(len(data))
+	}
+
+	zr, err := zip.NewReader(ra, size)
+	if err != nil && !errors.Is(err, zip.ErrInsecurePath) {
+		// Insecure names are reported per entry by entryPath
+		return nil, &PathError{Op: "extract", Path: e.dir, Err: err}
+	}
+
+	return zr, nil
+}
+
+// extractTar streams the files of a tar archive to the disk
+func (e *extractor) extractTar(ctx context.Context, reader io.Reader) error {
+	tr := tar.NewReader(reader)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil && !errors.Is(err, tar.ErrInsecurePath) {
+			// Insecure names are reported by entryPath
+			return &PathError{Op: "extract", Path: e.dir, Err: err}
+		}
+
+		if err := e.countEntry(header.Name); err != nil {
+			return err
+		}
+
+		switch header.Typeflag {
+		case tar.TypeReg:
+		case tar.TypeDir, tar.TypeXGlobalHeader:
+			// Skipping the entry reads its data, count it so it cannot be a bomb either
+			if err := e.reserve(header.Name, header.Size); err != nil {
+				return err
+			}
+			continue
+		default:
+			return unsafeEntry(header.Name, "only regular files and directories are allowed")
+		}
+
+		p, err := e.entryPath(header.Name)
+		if err != nil {
+			return err
+		}
+
+		// The tar reader never returns more than the declared size
+		if err := e.reserve(header.Name, header.Size); err != nil {
+			return err
+		}
+
+		if err := e.write(ctx, header.Name, p, tr, header.Size); err != nil {
+			return err
+		}
+	}
+}
+
+// countEntry counts an entry against MaxEntries
+func (e *extractor) countEntry(name string) error {
+	e.entries++
+	if e.entries > e.options.MaxEntries {
+		return &PathError{Op: "extract", Path: name, Err: &ArchiveLimitError{Limit: "entries", Max: int64(e.options.MaxEntries)}}
+	}
+	return nil
+}
+
+// reserve counts the uncompressed size of an entry against MaxFileSize and MaxTotalSize
+func (e *extractor) reserve(name string, size int64) error {
+	if e.options.MaxFileSize > 0 && size > e.options.MaxFileSize {
+		return &PathError{Op: "extract", Path: name, Err: &FileTooLargeError{Limit: e.options.MaxFileSize}}
+	}
+
+	if size > e.options.MaxTotalSize-e.total {
+		return &PathError{Op: "extract", Path: name, Err: &ArchiveLimitError{Limit: "total size", Max: e.options.MaxTotalSize}}
+	}
+
+	e.total += size
+	return nil
+}
+
+// entryPath returns the destination of an entry, rejecting names that could escape the prefix
+func (e *extractor) entryPath(name string) (string, error) {
+	if strings.Contains(name, `\`) {
+		return "", unsafeEntry(name, "backslashes are not allowed")
+	}
+	if path.IsAbs(name) || (len(name) >= 2 && name[1] == ':') {
+		return "", unsafeEntry(name, "absolute paths are not allowed")
+	}
+
+	validPath, err := ValidatePath(name)
+	if err != nil {
+		return "", unsafeEntry(name, err.Error())
+	}
+	if validPath == "." {
+		return "", unsafeEntry(name, "the name is empty")
+	}
+
+	return joinPrefix(e.dir, validPath), nil
+}
+
+// write stores a single file with its detected content type
+func (e *extractor) write(ctx context.Context, name string, dest string, reader io.Reader, size int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	reader, metadata, err := detectStream(e.detector, dest, reader, nil)
+	if err != nil {
+		return &PathError{Op: "extract", Path: name, Err: err}
+	}
+
+	if err := e.d.putStream(ctx, dest, reader, metadata); err != nil {
+		return err
+	}
+
+	e.results = append(e.results, ObjectResult{Path: dest, SourcePath: name})
+	e.storage.emit(ctx, Event{Type: EventPut, Disk: e.disk, Path: dest, Size: size, Metadata: metadata, Operation: "Extract"})
+	return nil
+}
+
+// unsafeEntry returns the error for an entry that is not extracted for security reasons
+func unsafeEntry(name string, reason string) error {
+	return &PathError{Op: "extract", Path: name, Err: &InvalidFilenameError{Name: name, Reason: reason}}
+}
@@ -0,0 +1,175 @@
+package gostorage
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// zipArchive returns a zip archive holding the given files
+func zipArchive(t *testing.T, files map[string][]byte) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		if _, err := w.Write(content); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// tarArchive returns a tar archive holding the given headers, regular files get their name as content
+func tarArchive(t *testing.T, headers ...*tar.Header) []byte {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, header := range headers {
+		if header.Typeflag == tar.TypeReg {
+			header.Size = int64(len(header.Name))
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatalf("WriteHeader failed: %v", err)
+		}
+		if header.Typeflag == tar.TypeReg {
+			if _, err := tw.Write([]byte(header.Name)); err != nil {
+				t.Fatalf("Write failed: %v", err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestStorage_Extract(t *testing.T) {
+	storage := newArchiveTestStorage(t)
+	ctx := context.Background()
+
+	for _, format := range []ArchiveFormat{ArchiveZip, ArchiveTar, ArchiveTarGz} {
+		var buf bytes.Buffer
+		if err := storage.Archive(ctx, "memory", "projects/42", format, &buf); err != nil {
+			t.Fatalf("Archive failed: %v", err)
+		}
+
+		// A plain io.Reader, zip archives are buffered
+		dest := "restored/" + string(format)
+		results, err := storage.Extract(ctx, io.MultiReader(&buf), format, "memory", dest, ExtractOptions{})
+		if err != nil {
+			t.Fatalf("%s: Extract failed: %v", format, err)
+		}
+		if len(results) != 2 || results[1].Path != dest+"/src/main.go" || results[1].SourcePath != "src/main.go" {
+			t.Errorf("%s: unexpected results: %+v", format, results)
+		}
+
+		content, err := storage.Get(ctx, "memory", dest+"/src/main.go")
+		if err != nil || string(content) != "projects/42/src/main.go" {
+			t.Errorf("%s: expected extracted content, got %q, %v", format, content, err)
+		}
+
+		metadata, err := storage.GetMetadata(ctx, "memory", dest+"/a.txt")
+		if err != nil {
+			t.Fatalf("GetMetadata failed: %v", err)
+		}
+		if metadata == nil || !strings.HasPrefix(metadata.ContentType, "text/plain") {
+			t.Errorf("%s: expected a detected text/plain content type, got %+v", format, metadata)
+		}
+	}
+
+	// A bytes.Reader is read in place
+	archive := zipArchive(t, map[string][]byte{"docs/": nil, "docs/readme.md": []byte("# hi")})
+	if _, err := storage.Extract(ctx, bytes.NewReader(archive), ArchiveZip, "memory", "", ExtractOptions{}); err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	if exists, _ := storage.Exists(ctx, "memory", "docs/readme.md"); !exists {
+		t.Error("expected docs/readme.md to be extracted")
+	}
+}
+
+func TestStorage_Extract_UnsafeEntries(t *testing.T) {
+	storage := NewStorage()
+	storage.AddDisk("memory", NewMemoryDisk())
+	ctx := context.Background()
+
+	zips := map[string][]byte{
+		"zip slip":  zipArchive(t, map[string][]byte{"ok.txt": []byte("ok"), "../../evil.txt": []byte("x")}),
+		"absolute":  zipArchive(t, map[string][]byte{"ok.txt": []byte("ok"), "/etc/cron.d/evil": []byte("x")}),
+		"backslash": zipArchive(t, map[string][]byte{"ok.txt": []byte("ok"), `..\evil.txt`: []byte("x")}),
+	}
+	for name, archive := range zips {
+		results, err := storage.Extract(ctx, bytes.NewReader(archive), ArchiveZip, "memory", "uploads", ExtractOptions{})
+		if !errors.Is(err, ErrInvalidPath) {
+			t.Errorf("%s: expected ErrInvalidPath, got %v", name, err)
+		}
+		if len(results) != 0 {
+			t.Errorf("%s: expected nothing to be written, got %+v", name, results)
+		}
+	}
+
+	tars := map[string][]byte{
+		"symlink":   tarArchive(t, &tar.Header{Typeflag: tar.TypeSymlink, Name: "link", Linkname: "/etc/passwd"}),
+		"hard link": tarArchive(t, &tar.Header{Typeflag: tar.TypeLink, Name: "link", Linkname: "../secret"}),
+		"zip slip":  tarArchive(t, &tar.Header{Typeflag: tar.TypeReg, Name: "a/../../evil.txt", Mode: 0644}),
+	}
+	for name, archive := range tars {
+		if _, err := storage.Extract(ctx, bytes.NewReader(archive), ArchiveTar, "memory", "uploads", ExtractOptions{}); !errors.Is(err, ErrInvalidPath) {
+			t.Errorf("%s: expected ErrInvalidPath, got %v", name, err)
+		}
+	}
+
+	files, err := storage.List(ctx, "memory", "")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(files) != 0 {
+		t.Errorf("expected nothing to be extracted, got %+v", files)
+	}
+}
+
+func TestStorage_Extract_Limits(t *testing.T) {
+	storage := NewStorage()
+	storage.AddDisk("memory", NewMemoryDisk())
+	ctx := context.Background()
+
+	// 10 MB of zeros compress to a few KB
+	bomb := zipArchive(t, map[string][]byte{"zeros.bin": make([]byte, 10<<20)})
+	_, err := storage.Extract(ctx, bytes.NewReader(bomb), ArchiveZip, "memory", "", ExtractOptions{MaxTotalSize: 1 << 20})
+	var limitErr *ArchiveLimitError
+	if !errors.As(err, &limitErr) || limitErr.Limit != "total size" {
+		t.Errorf("expected the total size limit, got %v", err)
+	}
+
+	_, err = storage.Extract(ctx, bytes.NewReader(bomb), ArchiveZip, "memory", "", ExtractOptions{MaxFileSize: 1 << 20})
+	var tooLarge *FileTooLargeError
+	if !errors.As(err, &tooLarge) {
+		t.Errorf("expected FileTooLargeError, got %v", err)
+	}
+
+	many := tarArchive(t,
+		&tar.Header{Typeflag: tar.TypeReg, Name: "a.txt", Mode: 0644},
+		&tar.Header{Typeflag: tar.TypeReg, Name: "b.txt", Mode: 0644},
+		&tar.Header{Typeflag: tar.TypeReg, Name: "c.txt", Mode: 0644},
+	)
+	results, err := storage.Extract(ctx, bytes.NewReader(many), ArchiveTar, "memory", "", ExtractOptions{MaxEntries: 2})
+	if !errors.Is(err, ErrValidationFailed) {
+		t.Errorf("expected the entry limit, got %v", err)
+	}
+	if len(results) != 2 {
+		t.Errorf("expected the files before the limit to be reported, got %+v", results)
+	}
+
+	if _, err := storage.Extract(ctx, bytes.NewReader(many), "rar", "memory", "", ExtractOptions{}); err == nil {
+		t.Error("expected an unknown format to be rejected")
+	}
+}
@@ -0,0 +1,352 @@
+package gostorage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultHealthCheckInterval is how often a FailoverDisk pings its primary disk
+	DefaultHealthCheckInterval = 10 * time.Second
+
+	// DefaultFailureThreshold is the number of consecutive failures after which a FailoverDisk switches to its secondary
+	DefaultFailureThreshold = 3
+
+	// DefaultRecoveryThreshold is the number of consecutive successful pings after which a FailoverDisk fails back
+	DefaultRecoveryThreshold = 3
+)
+
+// FailoverDiskConfig holds configuration for a FailoverDisk
+type FailoverDiskConfig struct {
+	// Primary serves all operations while it is healthy
+	Primary Disk
+
+	// Secondary serves all operations while the primary is unhealthy
+	Secondary Disk
+
+	// HealthCheckInterval is how often the primary is pinged (default: 10s)
+	HealthCheckInterval time.Duration
+
+	// FailureThreshold is the number of consecutive failed pings or operations
+	// on the primary after which operations go to the secondary (default: 3)
+	FailureThreshold int
+
+	// RecoveryThreshold is the number of consecutive successful pings of the primary
+	// after which operations go back to it (default: 3)
+	RecoveryThreshold int
+
+	// Logger receives failover and failback events (default: discard)
+	Logger *slog.Logger
+}
+
+// FailoverDisk implements Disk interface by routing operations to a primary disk,
+// switching to a secondary disk while the primary is unhealthy.
+// Failures are counted from health checks and from operations on the primary,
+// a missing file or an invalid path does not count as a failure.
+//
+// Writes are not replicated between the disks. Files written to the secondary while failed over
+// are not visible after failing back until they are copied to the primary, e.g. with Storage.Sync,
+// and files deleted meanwhile reappear. Use a MirrorDisk underneath when both disks must agree.
+type FailoverDisk struct {
+	primary           Disk
+	secondary         Disk
+	failureThreshold  int
+	recoveryThreshold int
+	logger            *slog.Logger
+
+	mu         sync.Mutex
+	failedOver bool
+	failures   int
+	recoveries int
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewFailoverDisk creates a new FailoverDisk and starts its health checks
+func NewFailoverDisk(cfg *FailoverDiskConfig) (*FailoverDisk, error) {
+	if cfg == nil {
+		return nil, errors.New("config cannot be nil")
+	}
+
+	if cfg.Primary == nil || cfg.Secondary == nil {
+		return nil, errors.New("primary and secondary disks are required")
+	}
+
+	interval := cfg.HealthCheckInterval
+	if interval <= 0 {
+		interval = DefaultHealthCheckInterval
+	}
+
+	failureThreshold := cfg.FailureThreshold
+	if failureThreshold <= 0 {
+		failureThreshold = DefaultFailureThreshold
+	}
+
+	recoveryThreshold := cfg.RecoveryThreshold
+	if recoveryThreshold <= 0 {
+		recoveryThreshold = DefaultRecoveryThreshold
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	d := &FailoverDisk{
+		primary:           cfg.Primary,
+		secondary:         cfg.Secondary,
+		failureThreshold:  failureThreshold,
+		recoveryThreshold: recoveryThreshold,
+		logger:            loggerOrDiscard(cfg.Logger),
+		cancel:            cancel,
+		done:              make(chan struct{}),
+	}
+
+	go d.healthLoop(ctx, interval)
+
+	return d, nil
+}
+
+// Close stops the health checks
+func (d *FailoverDisk) Close() error {
+	d.cancel()
+	<-d.done
+	return nil
+}
+
+// FailedOver reports whether operations currently go to the secondary disk
+func (d *FailoverDisk) FailedOver() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.failedOver
+}
+
+// CheckHealth pings the primary disk once and fails over or back when a threshold is crossed
+func (d *FailoverDisk) CheckHealth(ctx context.Context) error {
+	err := d.primary.ping(ctx)
+	if ctx.Err() != nil {
+		// Shutting down is not a failure of the primary
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err != nil {
+		d.recoveries = 0
+		d.recordFailure(ctx, err)
+		return err
+	}
+
+	d.failures = 0
+	if d.failedOver {
+		d.recoveries++
+		if d.recoveries >= d.recoveryThreshold {
+			d.failedOver = false
+			d.recoveries = 0
+			d.logger.InfoContext(ctx, "primary disk recovered, failing back")
+		}
+	}
+
+	return nil
+}
+
+// healthLoop pings the primary until the disk is closed
+func (d *FailoverDisk) healthLoop(ctx context.Context, interval time.Duration) {
+	defer close(d.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			d.CheckHealth(ctx)
+		}
+	}
+}
+
+// recordFailure counts a failure of the primary, d.mu must be held
+func (d *FailoverDisk) recordFailure(ctx context.Context, err error) {
+	d.failures++
+	if !d.failedOver && d.failures >= d.failureThreshold {
+		d.failedOver = true
+		d.logger.WarnContext(ctx, "primary disk unhealthy, failing over to secondary",
+			slog.Int("failures", d.failures),
+			slog.Any("error", err),
+		)
+	}
+}
+
+// route runs an operation on the active disk and counts failures of the primary
+func route[T any](ctx context.Context, d *FailoverDisk, op func(Disk) (T, error)) (T, error) {
+	d.mu.Lock()
+	failedOver := d.failedOver
+	d.mu.Unlock()
+
+	if failedOver {
+		return op(d.secondary)
+	}
+
+	result, err := op(d.primary)
+
+	d.mu.Lock()
+	switch {
+	case err == nil:
+		d.failures = 0
+	case !isCallerError(err) && ctx.Err() == nil:
+		d.recordFailure(ctx, err)
+	}
+	d.mu.Unlock()
+
+	return result, err
+}
+
+// isCallerError reports whether an error is caused by the request rather than by the disk
+func isCallerError(err error) bool {
+	return errors.Is(err, ErrFileNotFound) ||
+		errors.Is(err, ErrInvalidPath) ||
+		errors.Is(err, ErrPermissionDenied) ||
+		errors.Is(err, ErrValidationFailed) ||
+		errors.Is(err, ErrOperationNotSupported) ||
+		errors.Is(err, context.Canceled) ||
+		errors.Is(err, context.DeadlineExceeded)
+}
+
+// routeErr runs an operation without a result on the active disk
+func routeErr(ctx context.Context, d *FailoverDisk, op func(Disk) error) error {
+	_, err := route(ctx, d, func(disk Disk) (struct{}, error) {
+		return struct{}{}, op(disk)
+	})
+	return err
+}
+
+// put writes content to the active disk
+func (d *FailoverDisk) put(ctx context.Context, path string, content []byte) error {
+	return routeErr(ctx, d, func(disk Disk) error {
+		return disk.put(ctx, path, content)
+	})
+}
+
+// get reads content from the active disk
+func (d *FailoverDisk) get(ctx context.Context, path string) ([]byte, error) {
+	return route(ctx, d, func(disk Disk) ([]byte, error) {
+		return disk.get(ctx, path)
+	})
+}
+
+// delete removes a file from the active disk
+func (d *FailoverDisk) delete(ctx context.Context, path string) error {
+	return routeErr(ctx, d, func(disk Disk) error {
+		return disk.delete(ctx, path)
+	})
+}
+
+// putStream writes content from a reader to the active disk
+func (d *FailoverDisk) putStream(ctx context.Context, path string, reader io.Reader, metadata *Metadata) error {
+	return routeErr(ctx, d, func(disk Disk) error {
+		return disk.putStream(ctx, path, reader, metadata)
+	})
+}
+
+// getStream returns a reader from the active disk
+func (d *FailoverDisk) getStream(ctx context.Context, path string) (io.ReadCloser, error) {
+	return route(ctx, d, func(disk Disk) (io.ReadCloser, error) {
+		return disk.getStream(ctx, path)
+	})
+}
+
+// exists checks if a file exists on the active disk
+func (d *FailoverDisk) exists(ctx context.Context, path string) (bool, error) {
+	return route(ctx, d, func(disk Disk) (bool, error) {
+		return disk.exists(ctx, path)
+	})
+}
+
+// size returns the size of a file on the active disk
+func (d *FailoverDisk) size(ctx context.Context, path string) (int64, error) {
+	return route(ctx, d, func(disk Disk) (int64, error) {
+		return disk.size(ctx, path)
+	})
+}
+
+// list returns the files on the active disk
+func (d *FailoverDisk) list(ctx context.Context, prefix string) ([]FileInfo, error) {
+	return route(ctx, d, func(disk Disk) ([]FileInfo, error) {
+		return disk.list(ctx, prefix)
+	})
+}
+
+// copy copies a file on the active disk
+func (d *FailoverDisk) copy(ctx context.Context, sourcePath, destPath string) error {
+	return routeErr(ctx, d, func(disk Disk) error {
+		return disk.copy(ctx, sourcePath, destPath)
+	})
+}
+
+// move moves a file on the active disk
+func (d *FailoverDisk) move(ctx context.Context, sourcePath, destPath string) error {
+	return routeErr(ctx, d, func(disk Disk) error {
+		return disk.move(ctx, sourcePath, destPath)
+	})
+}
+
+// putWithMetadata writes content and metadata to the active disk
+func (d *FailoverDisk) putWithMetadata(ctx context.Context, path string, content []byte, metadata *Metadata) error {
+	return routeErr(ctx, d, func(disk Disk) error {
+		return disk.putWithMetadata(ctx, path, content, metadata)
+	})
+}
+
+// getMetadata retrieves metadata from the active disk
+func (d *FailoverDisk) getMetadata(ctx context.Context, path string) (*Metadata, error) {
+	return route(ctx, d, func(disk Disk) (*Metadata, error) {
+		return disk.getMetadata(ctx, path)
+	})
+}
+
+// setMetadata updates metadata on the active disk
+func (d *FailoverDisk) setMetadata(ctx context.Context, path string, metadata *Metadata) error {
+	return routeErr(ctx, d, func(disk Disk) error {
+		return disk.setMetadata(ctx, path, metadata)
+	})
+}
+
+// ping checks the active disk
+func (d *FailoverDisk) ping(ctx context.Context) error {
+	return routeErr(ctx, d, func(disk Disk) error {
+		return disk.ping(ctx)
+	})
+}
+
+// stat returns file information from the active disk
+func (d *FailoverDisk) stat(ctx context.Context, path string) (*FileInfo, error) {
+	return route(ctx, d, func(disk Disk) (*FileInfo, error) {
+		return statDisk(ctx, disk, path)
+	})
+}
+
+// setVisibility changes the visibility of a file on the active disk
+func (d *FailoverDisk) setVisibility(ctx context.Context, path string, visibility Visibility) error {
+	return routeErr(ctx, d, func(disk Disk) error {
+		return setDiskVisibility(ctx, disk, path, visibility)
+	})
+}
+
+// getVisibility returns the visibility of a file on the active disk
+func (d *FailoverDisk) getVisibility(ctx context.Context, path string) (Visibility, error) {
+	return route(ctx, d, func(disk Disk) (Visibility, error) {
+		return diskVisibility(ctx, disk, path)
+	})
+}
+
+// url returns the public URL of a file on the active disk
+func (d *FailoverDisk) url(ctx context.Context, path string) (string, error) {
+	return route(ctx, d, func(disk Disk) (string, error) {
+		return diskURL(ctx, disk, path)
+	})
+}
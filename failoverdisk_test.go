@@ -0,0 +1,96 @@
+package gostorage
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestFailoverDisk(t *testing.T) {
+	ctx := context.Background()
+	primary, secondary := newFlakyDisk(), newFlakyDisk()
+
+	disk, err := NewFailoverDisk(&FailoverDiskConfig{
+		Primary:           primary,
+		Secondary:         secondary,
+		FailureThreshold:  2,
+		RecoveryThreshold: 2,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create FailoverDisk: %v", err)
+	}
+	defer disk.Close()
+
+	if err := disk.put(ctx, "a.txt", []byte("primary")); err != nil {
+		t.Fatalf("put failed: %v", err)
+	}
+	if exists, _ := primary.exists(ctx, "a.txt"); !exists {
+		t.Error("Expected write to go to the primary")
+	}
+
+	// Missing files do not count as failures
+	for range 3 {
+		disk.get(ctx, "missing.txt")
+	}
+	if disk.FailedOver() {
+		t.Fatal("Missing files should not cause a failover")
+	}
+
+	// Operation errors count towards the threshold
+	primary.down.Store(true)
+	if err := disk.put(ctx, "b.txt", []byte("x")); !errors.Is(err, errUnavailable) {
+		t.Errorf("Expected primary error, got %v", err)
+	}
+	if disk.FailedOver() {
+		t.Fatal("A single failure should not cause a failover")
+	}
+	disk.CheckHealth(ctx)
+	if !disk.FailedOver() {
+		t.Fatal("Expected failover after reaching the threshold")
+	}
+
+	if err := disk.put(ctx, "b.txt", []byte("secondary")); err != nil {
+		t.Fatalf("put after failover failed: %v", err)
+	}
+	if exists, _ := secondary.exists(ctx, "b.txt"); !exists {
+		t.Error("Expected write to go to the secondary")
+	}
+
+	// Fails back after enough successful health checks
+	primary.down.Store(false)
+	disk.CheckHealth(ctx)
+	if !disk.FailedOver() {
+		t.Error("A single successful ping should not cause a failback")
+	}
+	disk.CheckHealth(ctx)
+	if disk.FailedOver() {
+		t.Error("Expected failback after reaching the recovery threshold")
+	}
+}
+
+func TestStorage_Ping(t *testing.T) {
+	ctx := context.Background()
+
+	dir := t.TempDir()
+	local, err := NewLocalDisk(&LocalDiskConfig{Path: dir})
+	if err != nil {
+		t.Fatalf("Failed to create LocalDisk: %v", err)
+	}
+
+	storage := NewStorage()
+	storage.AddDisk("local", local)
+
+	if err := storage.Ping(ctx, "local"); err != nil {
+		t.Errorf("Ping failed: %v", err)
+	}
+
+	local.config.Path = dir + "/missing"
+	if err := storage.Ping(ctx, "local"); err == nil {
+		t.Error("Expected ping of a missing directory to fail")
+	}
+
+	var notFound *DiskNotFoundError
+	if err := storage.Ping(ctx, "unknown"); !errors.As(err, &notFound) {
+		t.Errorf("Expected DiskNotFoundError, got %v", err)
+	}
+}
@@ -0,0 +1,237 @@
+package gostorage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"path"
+	"path/filepath"
+)
+
+// FSDisk implements Disk interface on top of a read-only fs.FS such as embed.FS,
+// os.DirFS or fstest.MapFS. Writes return ErrOperationNotSupported.
+type FSDisk struct {
+	fsys fs.FS
+}
+
+// NewFSDisk creates a new FSDisk serving files from fsys
+func NewFSDisk(fsys fs.FS) (*FSDisk, error) {
+	if fsys == nil {
+		return nil, errors.New("fs.FS cannot be nil")
+	}
+
+	return &FSDisk{
+		fsys: fsys,
+	}, nil
+}
+
+// fsPath validates a path and converts it to the slash separated form io/fs expects
+func (d *FSDisk) fsPath(path string) (string, error) {
+	validPath, err := ValidatePath(path)
+	if err != nil {
+		return "", err
+	}
+
+	name := filepath.ToSlash(validPath)
+	if !fs.ValidPath(name) {
+		return "", ErrInvalidPath
+	}
+
+	return name, nil
+}
+
+// put is not supported on a read-only file system
+func (d *FSDisk) put(_ context.Context, path string, _ []byte) error {
+	return &PathError{Op: "put", Path: path, Err: ErrOperationNotSupported}
+}
+
+// get reads content from a file
+func (d *FSDisk) get(_ context.Context, path string) ([]byte, error) {
+	name, err := d.fsPath(path)
+	if err != nil {
+		return nil, &PathError{Op: "get", Path: path, Err: err}
+	}
+
+	content, err := fs.ReadFile(d.fsys, name)
+	if err != nil {
+		return nil, &PathError{Op: "get", Path: path, Err: diskError(err)}
+	}
+
+	return content, nil
+}
+
+// delete is not supported on a read-only file system
+func (d *FSDisk) delete(_ context.Context, path string) error {
+	return &PathError{Op: "delete", Path: path, Err: ErrOperationNotSupported}
+}
+
+// putStream is not supported on a read-only file system
+func (d *FSDisk) putStream(_ context.Context, path string, _ io.Reader, _ *Metadata) error {
+	return &PathError{Op: "putStream", Path: path, Err: ErrOperationNotSupported}
+}
+
+// getStream returns a reader for file content
+func (d *FSDisk) getStream(_ context.Context, path string) (io.ReadCloser, error) {
+	name, err := d.fsPath(path)
+	if err != nil {
+		return nil, &PathError{Op: "getStream", Path: path, Err: err}
+	}
+
+	file, err := d.fsys.Open(name)
+	if err != nil {
+		return nil, &PathError{Op: "getStream", Path: path, Err: diskError(err)}
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, &PathError{Op: "getStream", Path: path, Err: err}
+	}
+	if info.IsDir() {
+		file.Close()
+		return nil, &PathError{Op: "getStream", Path: path, Err: fmt.Errorf("%w: is a directory", ErrInvalidPath)}
+	}
+
+	return file, nil
+}
+
+// exists checks if a file exists
+func (d *FSDisk) exists(_ context.Context, path string) (bool, error) {
+	name, err := d.fsPath(path)
+	if err != nil {
+		return false, &PathError{Op: "exists", Path: path, Err: err}
+	}
+
+	if _, err := fs.Stat(d.fsys, name); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		return false, &PathError{Op: "exists", Path: path, Err: err}
+	}
+
+	return true, nil
+}
+
+// size returns the size of a file
+func (d *FSDisk) size(_ context.Context, path string) (int64, error) {
+	name, err := d.fsPath(path)
+	if err != nil {
+		return 0, &PathError{Op: "size", Path: path, Err: err}
+	}
+
+	info, err := fs.Stat(d.fsys, name)
+	if err != nil {
+		return 0, &PathError{Op: "size", Path: path, Err: diskError(err)}
+	}
+
+	return info.Size(), nil
+}
+
+// list returns the files and directories below a prefix directory
+func (d *FSDisk) list(_ context.Context, prefix string) ([]FileInfo, error) {
+	// Validate prefix
+	validPrefix, err := ValidatePrefix(prefix)
+	if err != nil {
+		return nil, &PathError{Op: "list", Path: prefix, Err: err}
+	}
+
+	root := filepath.ToSlash(validPrefix)
+	if root == "" {
+		root = "."
+	}
+
+	var files []FileInfo
+
+	err = fs.WalkDir(d.fsys, root, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			// A missing prefix simply has no entries
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+
+		// Skip the root itself
+		if name == "." {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		files = append(files, FileInfo{
+			Path:         name,
+			Size:         info.Size(),
+			LastModified: info.ModTime(),
+			IsDir:        entry.IsDir(),
+		})
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, &PathError{Op: "list", Path: prefix, Err: err}
+	}
+
+	return files, nil
+}
+
+// copy is not supported on a read-only file system
+func (d *FSDisk) copy(_ context.Context, sourcePath, _ string) error {
+	return &PathError{Op: "copy", Path: sourcePath, Err: ErrOperationNotSupported}
+}
+
+// move is not supported on a read-only file system
+func (d *FSDisk) move(_ context.Context, sourcePath, _ string) error {
+	return &PathError{Op: "move", Path: sourcePath, Err: ErrOperationNotSupported}
+}
+
+// putWithMetadata is not supported on a read-only file system
+func (d *FSDisk) putWithMetadata(_ context.Context, path string, _ []byte, _ *Metadata) error {
+	return &PathError{Op: "putWithMetadata", Path: path, Err: ErrOperationNotSupported}
+}
+
+// getMetadata derives metadata from the file info and the file extension
+func (d *FSDisk) getMetadata(_ context.Context, filePath string) (*Metadata, error) {
+	name, err := d.fsPath(filePath)
+	if err != nil {
+		return nil, &PathError{Op: "getMetadata", Path: filePath, Err: err}
+	}
+
+	info, err := fs.Stat(d.fsys, name)
+	if err != nil {
+		return nil, &PathError{Op: "getMetadata", Path: filePath, Err: diskError(err)}
+	}
+
+	return &Metadata{
+		ContentType:  mime.TypeByExtension(path.Ext(name)),
+		Size:         info.Size(),
+		LastModified: info.ModTime(),
+	}, nil
+}
+
+// setMetadata is not supported on a read-only file system
+func (d *FSDisk) setMetadata(_ context.Context, path string, _ *Metadata) error {
+	return &PathError{Op: "setMetadata", Path: path, Err: ErrOperationNotSupported}
+}
+
+// diskError translates io/fs errors into their gostorage equivalents
+func diskError(err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrFileNotFound
+	}
+	return err
+}
+
+// ping checks that the root of the file system can be read
+func (d *FSDisk) ping(_ context.Context) error {
+	if _, err := fs.Stat(d.fsys, "."); err != nil {
+		return &PathError{Op: "ping", Path: ".", Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package gostorage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"testing/fstest"
+)
+
+func TestFSDisk(t *testing.T) {
+	disk, err := NewFSDisk(fstest.MapFS{
+		"defaults/logo.svg":    {Data: []byte("<svg/>")},
+		"defaults/config.json": {Data: []byte(`{"theme":"light"}`)},
+		"README.txt":           {Data: []byte("assets")},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create FSDisk: %v", err)
+	}
+
+	storage := NewStorage()
+	storage.AddDisk("assets", disk)
+	storage.AddDisk("memory", NewMemoryDisk())
+
+	ctx := context.Background()
+
+	data, err := storage.Get(ctx, "assets", "/defaults/config.json")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if string(data) != `{"theme":"light"}` {
+		t.Errorf("Unexpected content: %s", data)
+	}
+
+	exists, err := storage.Exists(ctx, "assets", "defaults/missing.png")
+	if err != nil || exists {
+		t.Errorf("Expected missing file to not exist, got %v, %v", exists, err)
+	}
+
+	size, err := storage.Size(ctx, "assets", "README.txt")
+	if err != nil || size != 6 {
+		t.Errorf("Expected size 6, got %d, %v", size, err)
+	}
+
+	files, err := storage.List(ctx, "assets", "defaults")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	count := 0
+	for _, f := range files {
+		if !f.IsDir {
+			count++
+		}
+	}
+	if count != 2 {
+		t.Errorf("Expected 2 files in defaults, got %d", count)
+	}
+
+	// Writes are rejected
+	if err := storage.Put(ctx, "assets", "new.txt", []byte("x")); !errors.Is(err, ErrOperationNotSupported) {
+		t.Errorf("Expected ErrOperationNotSupported, got %v", err)
+	}
+
+	if _, err := storage.Get(ctx, "assets", "nope.txt"); !errors.Is(err, ErrFileNotFound) {
+		t.Errorf("Expected ErrFileNotFound, got %v", err)
+	}
+
+	// Cross-disk copies carry the derived content type
+	if err := storage.CopyBetweenDisks(ctx, "assets", "memory", "defaults/logo.svg", "logo.svg"); err != nil {
+		t.Fatalf("CopyBetweenDisks failed: %v", err)
+	}
+	meta, err := storage.GetMetadata(ctx, "memory", "logo.svg")
+	if err != nil {
+		t.Fatalf("GetMetadata failed: %v", err)
+	}
+	if meta == nil || meta.ContentType != "image/svg+xml" {
+		t.Errorf("Expected image/svg+xml, got %+v", meta)
+	}
+}
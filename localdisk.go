@@ -4,10 +4,17 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"io/fs"
+	"log/slog"
+	"math/rand/v2"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+
+	"github.com/fsnotify/fsnotify"
 )
 
 // LocalDiskConfig contains configuration for local filesystem storage
@@ -21,13 +28,31 @@ type LocalDiskConfig struct {
 	// Permissions for created directories (default: 0755)
 	DirPermissions os.FileMode
 
-	// Permissions for created files (default: 0644)
+	// Permissions for created files, also used for public files (default: 0644)
 	FilePermissions os.FileMode
+
+	// Permissions for private files (default: 0600)
+	PrivateFilePermissions os.FileMode
+
+	// PublicBaseURL is the URL the directory is served from, e.g. "https://static.example.com/files".
+	// It is required for Storage.URL.
+	PublicBaseURL string
+
+	// Logger receives warnings about errors that do not fail an operation (default: discard)
+	Logger *slog.Logger
+
+	// ContentTypeDetector fills in the content type of files written without one (default: DefaultContentTypeDetector)
+	ContentTypeDetector *ContentTypeDetector
+
+	// DisableContentTypeDetection stores files without a content type unless one is given
+	DisableContentTypeDetection bool
 }
 
 // LocalDisk implements Disk interface for local filesystem storage
 type LocalDisk struct {
-	config *LocalDiskConfig
+	config   *LocalDiskConfig
+	logger   *slog.Logger
+	detector *ContentTypeDetector
 }
 
 // NewLocalDisk creates a new LocalDisk with the given configuration
@@ -47,6 +72,15 @@ func NewLocalDisk(cfg *LocalDiskConfig) (*LocalDisk, error) {
 	if cfg.FilePermissions == 0 {
 		cfg.FilePermissions = 0644
 	}
+	if cfg.PrivateFilePermissions == 0 {
+		cfg.PrivateFilePermissions = 0600
+	}
+
+	if cfg.PublicBaseURL != "" {
+		if err := validateBaseURL(cfg.PublicBaseURL); err != nil {
+			return nil, err
+		}
+	}
 
 	// CreateIfNotExist defaults to true
 	createIfNotExist := cfg.CreateIfNotExist
@@ -73,16 +107,23 @@ func NewLocalDisk(cfg *LocalDiskConfig) (*LocalDisk, error) {
 	}
 
 	return &LocalDisk{
-		config: cfg,
+		config:   cfg,
+		logger:   loggerOrDiscard(cfg.Logger),
+		detector: contentTypeDetector(cfg.ContentTypeDetector, cfg.DisableContentTypeDetection),
 	}, nil
 }
 
-// put writes content to a file
+// put writes content to a file, recording its detected content type when the extension does not imply it
 func (d *LocalDisk) put(ctx context.Context, path string, content []byte) error {
+	return d.putWithMetadata(ctx, path, content, nil)
+}
+
+// writeFile writes content to a file and returns its validated path
+func (d *LocalDisk) writeFile(op string, path string, content []byte) (string, error) {
 	// Validate path
 	validPath, err := ValidatePath(path)
 	if err != nil {
-		return &PathError{Op: "put", Path: path, Err: err}
+		return "", &PathError{Op: op, Path: path, Err: err}
 	}
 
 	// Construct the full file path
@@ -91,15 +132,15 @@ func (d *LocalDisk) put(ctx context.Context, path string, content []byte) error
 	// Create all parent directories if they don't exist
 	dir := filepath.Dir(fullPath)
 	if err := os.MkdirAll(dir, d.config.DirPermissions); err != nil {
-		return &PathError{Op: "put", Path: path, Err: err}
+		return "", &PathError{Op: op, Path: path, Err: err}
 	}
 
 	// Write the file with appropriate permissions
 	if err := os.WriteFile(fullPath, content, d.config.FilePermissions); err != nil {
-		return &PathError{Op: "put", Path: path, Err: err}
+		return "", &PathError{Op: op, Path: path, Err: err}
 	}
 
-	return nil
+	return validPath, nil
 }
 
 // get reads content from a file
@@ -144,6 +185,11 @@ func (d *LocalDisk) delete(_ context.Context, path string) error {
 		return &PathError{Op: "delete", Path: path, Err: err}
 	}
 
+	// Delete its metadata, a leftover sidecar would keep the directory from being removed
+	if err := os.Remove(fullPath + ".metadata.json"); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return &PathError{Op: "delete", Path: path, Err: err}
+	}
+
 	return nil
 }
 
@@ -164,15 +210,37 @@ func (d *LocalDisk) putStream(ctx context.Context, path string, reader io.Reader
 		return &PathError{Op: "putStream", Path: path, Err: err}
 	}
 
-	// Create the file
-	file, err := os.Create(fullPath)
+	// Fill in the content type when it is not given, unless the extension implies it on read
+	if metadata != nil || !d.detector.implies(validPath) {
+		reader, metadata, err = detectStream(d.detector, validPath, reader, metadata)
+		if err != nil {
+			return &PathError{Op: "putStream", Path: path, Err: err}
+		}
+	}
+
+	// Write to a temporary file, a failed write must leave an existing file untouched
+	file, err := d.createTemp(fullPath)
 	if err != nil {
 		return &PathError{Op: "putStream", Path: path, Err: err}
 	}
-	defer file.Close()
+	tempPath := file.Name()
 
 	// Copy from reader to file
 	if _, err := io.Copy(file, reader); err != nil {
+		file.Close()
+		os.Remove(tempPath)
+		return &PathError{Op: "putStream", Path: path, Err: err}
+	}
+
+	// Close explicitly, a failed close can mean the data never reached the disk
+	if err := file.Close(); err != nil {
+		os.Remove(tempPath)
+		return &PathError{Op: "putStream", Path: path, Err: err}
+	}
+
+	// Replace the file only once the content is complete
+	if err := os.Rename(tempPath, fullPath); err != nil {
+		os.Remove(tempPath)
 		return &PathError{Op: "putStream", Path: path, Err: err}
 	}
 
@@ -186,6 +254,27 @@ func (d *LocalDisk) putStream(ctx context.Context, path string, reader io.Reader
 	return nil
 }
 
+// tempFileSuffix marks files that are still being written, they are not listed or reported
+const tempFileSuffix = ".gostorage-tmp"
+
+// createTemp creates a temporary file next to fullPath, with the permissions of the file it replaces
+// or FilePermissions for a new file
+func (d *LocalDisk) createTemp(fullPath string) (*os.File, error) {
+	mode := d.config.FilePermissions
+	if info, err := os.Stat(fullPath); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	dir, base := filepath.Split(fullPath)
+	for {
+		name := filepath.Join(dir, "."+base+"."+strconv.FormatUint(rand.Uint64(), 36)+tempFileSuffix)
+		file, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+		if !errors.Is(err, fs.ErrExist) {
+			return file, err
+		}
+	}
+}
+
 // getStream returns a reader for file content
 func (d *LocalDisk) getStream(_ context.Context, path string) (io.ReadCloser, error) {
 	// Validate path
@@ -256,7 +345,7 @@ func (d *LocalDisk) size(_ context.Context, path string) (int64, error) {
 }
 
 // list returns a list of files matching a prefix
-func (d *LocalDisk) list(_ context.Context, prefix string) ([]FileInfo, error) {
+func (d *LocalDisk) list(ctx context.Context, prefix string) ([]FileInfo, error) {
 	// Validate prefix
 	validPrefix, err := ValidatePrefix(prefix)
 	if err != nil {
@@ -271,7 +360,13 @@ func (d *LocalDisk) list(_ context.Context, prefix string) ([]FileInfo, error) {
 	// Walk the directory
 	err = filepath.Walk(searchPath, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
-			// Skip errors for inaccessible files
+			// Skip errors for inaccessible files, a missing prefix simply has no entries
+			if !errors.Is(err, os.ErrNotExist) {
+				d.logger.WarnContext(ctx, "skipping inaccessible path while listing",
+					slog.String("path", path),
+					slog.Any("error", err),
+				)
+			}
 			return nil
 		}
 
@@ -286,8 +381,8 @@ func (d *LocalDisk) list(_ context.Context, prefix string) ([]FileInfo, error) {
 			return nil
 		}
 
-		// Skip metadata files
-		if strings.HasSuffix(relPath, ".metadata.json") {
+		// Skip metadata files and files that are still being written
+		if strings.HasSuffix(relPath, ".metadata.json") || strings.HasSuffix(relPath, tempFileSuffix) {
 			return nil
 		}
 
@@ -327,14 +422,35 @@ func (d *LocalDisk) copy(ctx context.Context, sourcePath, destPath string) error
 		return err
 	}
 
-	// Copy metadata if exists
-	metadata, _ := d.getMetadata(ctx, validSource)
+	// Copy metadata if exists, a failure here only loses the metadata
+	metadata, err := d.readMetadata(validSource)
+	if err != nil {
+		d.logger.WarnContext(ctx, "copying without metadata",
+			slog.String("path", sourcePath),
+			slog.Any("error", err),
+		)
+	}
 
 	// Write to destination
 	if metadata != nil {
-		return d.putWithMetadata(ctx, validDest, content, metadata)
+		err = d.putWithMetadata(ctx, validDest, content, metadata)
+	} else {
+		err = d.put(ctx, validDest, content)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Keep the permissions, and with them the visibility, of the source
+	info, err := os.Stat(filepath.Join(d.config.Path, validSource))
+	if err != nil {
+		return &PathError{Op: "copy", Path: sourcePath, Err: err}
+	}
+	if err := os.Chmod(filepath.Join(d.config.Path, validDest), info.Mode().Perm()); err != nil {
+		return &PathError{Op: "copy", Path: destPath, Err: err}
 	}
-	return d.put(ctx, validDest, content)
+
+	return nil
 }
 
 // move moves a file from source to destination
@@ -350,22 +466,31 @@ func (d *LocalDisk) move(ctx context.Context, sourcePath, destPath string) error
 
 // putWithMetadata writes content and metadata to a file
 func (d *LocalDisk) putWithMetadata(ctx context.Context, path string, content []byte, metadata *Metadata) error {
-	// Write the file
-	if err := d.put(ctx, path, content); err != nil {
-		return err
+	// put and putWithMetadata share the write, only the reported operation differs
+	op := "putWithMetadata"
+	if metadata == nil {
+		op = "put"
 	}
 
-	// Validate path
-	validPath, err := ValidatePath(path)
+	// Write the file
+	validPath, err := d.writeFile(op, path, content)
 	if err != nil {
-		return &PathError{Op: "putWithMetadata", Path: path, Err: err}
+		return err
 	}
 
-	// Save metadata
+	// Save metadata, filling in the content type when it is not given.
+	// A plain write of a file whose extension implies its content type needs no sidecar.
+	if metadata == nil && d.detector.implies(validPath) {
+		return nil
+	}
+	metadata = detectMetadata(d.detector, validPath, content, metadata)
+	if metadata == nil {
+		return nil
+	}
 	return d.saveMetadata(validPath, metadata)
 }
 
-// getMetadata retrieves metadata for a file
+// getMetadata retrieves metadata for a file, the content type defaults to the one implied by the extension
 func (d *LocalDisk) getMetadata(_ context.Context, path string) (*Metadata, error) {
 	// Validate path
 	validPath, err := ValidatePath(path)
@@ -373,6 +498,24 @@ func (d *LocalDisk) getMetadata(_ context.Context, path string) (*Metadata, erro
 		return nil, &PathError{Op: "getMetadata", Path: path, Err: err}
 	}
 
+	metadata, err := d.readMetadata(validPath)
+	if err != nil {
+		return nil, &PathError{Op: "getMetadata", Path: path, Err: err}
+	}
+
+	// Plain writes only record content types the extension does not imply
+	if (metadata == nil || metadata.ContentType == "") && d.detector.implies(validPath) {
+		if metadata == nil {
+			metadata = &Metadata{}
+		}
+		metadata.ContentType = d.detector.byExtension(validPath)
+	}
+
+	return metadata, nil
+}
+
+// readMetadata reads the metadata sidecar of a file, nil when there is none
+func (d *LocalDisk) readMetadata(validPath string) (*Metadata, error) {
 	metadataPath := filepath.Join(d.config.Path, validPath+".metadata.json")
 
 	// Read metadata file
@@ -381,17 +524,61 @@ func (d *LocalDisk) getMetadata(_ context.Context, path string) (*Metadata, erro
 		if errors.Is(err, os.ErrNotExist) {
 			return nil, nil // No metadata is not an error
 		}
-		return nil, &PathError{Op: "getMetadata", Path: path, Err: err}
+		return nil, err
 	}
 
 	var metadata Metadata
 	if err := json.Unmarshal(data, &metadata); err != nil {
-		return nil, &PathError{Op: "getMetadata", Path: path, Err: err}
+		return nil, err
 	}
 
 	return &metadata, nil
 }
 
+// stat returns file information and metadata, directories are reported with IsDir set
+func (d *LocalDisk) stat(ctx context.Context, path string) (*FileInfo, error) {
+	// Validate path
+	validPath, err := ValidatePath(path)
+	if err != nil {
+		return nil, &PathError{Op: "stat", Path: path, Err: err}
+	}
+
+	fullPath := filepath.Join(d.config.Path, validPath)
+
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, &PathError{Op: "stat", Path: path, Err: ErrFileNotFound}
+		}
+		return nil, &PathError{Op: "stat", Path: path, Err: err}
+	}
+
+	fileInfo := &FileInfo{
+		Path:         filepath.ToSlash(validPath),
+		Size:         info.Size(),
+		LastModified: info.ModTime(),
+		IsDir:        info.IsDir(),
+	}
+	if info.IsDir() {
+		return fileInfo, nil
+	}
+
+	metadata, err := d.getMetadata(ctx, validPath)
+	if err != nil {
+		return nil, err
+	}
+	if metadata == nil {
+		metadata = &Metadata{}
+	}
+
+	// The file itself is authoritative for size and modification time
+	metadata.Size = info.Size()
+	metadata.LastModified = info.ModTime()
+	fileInfo.Metadata = metadata
+
+	return fileInfo, nil
+}
+
 // setMetadata updates metadata for a file
 func (d *LocalDisk) setMetadata(_ context.Context, path string, metadata *Metadata) error {
 	// Validate path
@@ -422,6 +609,17 @@ func (d *LocalDisk) saveMetadata(validPath string, metadata *Metadata) error {
 		return err
 	}
 
+	// Visibility is kept in the file permissions rather than in the sidecar
+	if metadata != nil && metadata.Visibility != "" {
+		if err := d.chmod(validPath, metadata.Visibility); err != nil {
+			return err
+		}
+
+		stripped := *metadata
+		stripped.Visibility = ""
+		metadata = &stripped
+	}
+
 	// Marshal metadata
 	data, err := json.MarshalIndent(metadata, "", "  ")
 	if err != nil {
@@ -431,3 +629,295 @@ func (d *LocalDisk) saveMetadata(validPath string, metadata *Metadata) error {
 	// Write metadata file
 	return os.WriteFile(metadataPath, data, d.config.FilePermissions)
 }
+
+// typeDetector returns the detector filling in content types, nil when detection is disabled
+func (d *LocalDisk) typeDetector() *ContentTypeDetector {
+	return d.detector
+}
+
+// watch reports changes using filesystem notifications
+func (d *LocalDisk) watch(ctx context.Context, prefix string) (<-chan ChangeEvent, error) {
+	// Validate prefix
+	validPrefix, err := ValidatePrefix(prefix)
+	if err != nil {
+		return nil, &PathError{Op: "watch", Path: prefix, Err: err}
+	}
+
+	root := filepath.Join(d.config.Path, validPrefix)
+
+	info, err := os.Stat(root)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, &PathError{Op: "watch", Path: prefix, Err: ErrFileNotFound}
+		}
+		return nil, &PathError{Op: "watch", Path: prefix, Err: err}
+	}
+	if !info.IsDir() {
+		return nil, &PathError{Op: "watch", Path: prefix, Err: fmt.Errorf("%w: not a directory", ErrInvalidPath)}
+	}
+
+	fsWatcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, &PathError{Op: "watch", Path: prefix, Err: err}
+	}
+
+	w := &localWatch{
+		disk:    d,
+		watcher: fsWatcher,
+		dirs:    make(map[string]bool),
+	}
+
+	// fsnotify is not recursive, so every directory below the root is watched individually
+	if _, err := w.addTree(root); err != nil {
+		fsWatcher.Close()
+		return nil, &PathError{Op: "watch", Path: prefix, Err: err}
+	}
+
+	changes := make(chan ChangeEvent)
+
+	go func() {
+		defer close(changes)
+		defer fsWatcher.Close()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case err, ok := <-fsWatcher.Errors:
+				if !ok {
+					return
+				}
+				d.logger.WarnContext(ctx, "filesystem watch error",
+					slog.String("prefix", prefix),
+					slog.Any("error", err),
+				)
+			case event, ok := <-fsWatcher.Events:
+				if !ok {
+					return
+				}
+				for _, change := range w.translate(ctx, event) {
+					select {
+					case changes <- change:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}
+	}()
+
+	return changes, nil
+}
+
+// localWatch tracks the watched directories of a single LocalDisk watch
+type localWatch struct {
+	disk    *LocalDisk
+	watcher *fsnotify.Watcher
+	dirs    map[string]bool
+}
+
+// addTree watches root and every directory below it.
+// It returns the files found along the way, which matters for directories
+// that were populated before their watch was added.
+func (w *localWatch) addTree(root string) ([]ChangeEvent, error) {
+	var found []ChangeEvent
+
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if entry.IsDir() {
+			if err := w.watcher.Add(path); err != nil {
+				return err
+			}
+			w.dirs[path] = true
+			return nil
+		}
+
+		if change, ok := w.change(ChangeCreated, path); ok {
+			found = append(found, change)
+		}
+		return nil
+	})
+
+	return found, err
+}
+
+// translate converts a filesystem notification into change events
+func (w *localWatch) translate(ctx context.Context, event fsnotify.Event) []ChangeEvent {
+	switch {
+	case event.Has(fsnotify.Create):
+		info, err := os.Stat(event.Name)
+		if err != nil {
+			// Already gone again
+			return nil
+		}
+
+		if info.IsDir() {
+			found, err := w.addTree(event.Name)
+			if err != nil {
+				w.disk.logger.WarnContext(ctx, "cannot watch new directory",
+					slog.String("path", event.Name),
+					slog.Any("error", err),
+				)
+			}
+			return found
+		}
+
+		if change, ok := w.change(ChangeCreated, event.Name); ok {
+			return []ChangeEvent{change}
+		}
+
+	case event.Has(fsnotify.Write):
+		if change, ok := w.change(ChangeModified, event.Name); ok {
+			return []ChangeEvent{change}
+		}
+
+	case event.Has(fsnotify.Remove), event.Has(fsnotify.Rename):
+		// Removed directories drop their watch automatically, their files are reported individually
+		if w.dirs[event.Name] {
+			delete(w.dirs, event.Name)
+			return nil
+		}
+
+		if change, ok := w.change(ChangeDeleted, event.Name); ok {
+			return []ChangeEvent{change}
+		}
+	}
+
+	return nil
+}
+
+// change builds a change event for a file, skipping metadata files, temporary files and directories
+func (w *localWatch) change(changeType ChangeType, fullPath string) (ChangeEvent, bool) {
+	relPath, err := filepath.Rel(w.disk.config.Path, fullPath)
+	if err != nil || strings.HasSuffix(relPath, ".metadata.json") || strings.HasSuffix(relPath, tempFileSuffix) {
+		return ChangeEvent{}, false
+	}
+
+	change := ChangeEvent{
+		Type: changeType,
+		Path: filepath.ToSlash(relPath),
+	}
+
+	if changeType != ChangeDeleted {
+		info, err := os.Stat(fullPath)
+		if err != nil || info.IsDir() {
+			return ChangeEvent{}, false
+		}
+		change.Size = info.Size()
+		change.LastModified = info.ModTime()
+	}
+
+	return change, true
+}
+
+// removeDirectory removes an empty directory, a directory that is not empty is kept
+func (d *LocalDisk) removeDirectory(_ context.Context, path string) error {
+	// Validate path
+	validPath, err := ValidatePath(path)
+	if err != nil {
+		return &PathError{Op: "removeDirectory", Path: path, Err: err}
+	}
+
+	fullPath := filepath.Join(d.config.Path, validPath)
+
+	entries, err := os.ReadDir(fullPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return &PathError{Op: "removeDirectory", Path: path, Err: err}
+	}
+	if len(entries) > 0 {
+		return nil
+	}
+
+	if err := os.Remove(fullPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return &PathError{Op: "removeDirectory", Path: path, Err: err}
+	}
+
+	return nil
+}
+
+// ping checks that the base directory exists and is a directory
+func (d *LocalDisk) ping(_ context.Context) error {
+	info, err := os.Stat(d.config.Path)
+	if err != nil {
+		return &PathError{Op: "ping", Path: d.config.Path, Err: err}
+	}
+
+	if !info.IsDir() {
+		return &PathError{Op: "ping", Path: d.config.Path, Err: fmt.Errorf("%w: not a directory", ErrInvalidPath)}
+	}
+
+	return nil
+}
+
+// setVisibility changes the permissions of a file to FilePermissions or PrivateFilePermissions
+func (d *LocalDisk) setVisibility(_ context.Context, path string, visibility Visibility) error {
+	// Validate path
+	validPath, err := ValidatePath(path)
+	if err != nil {
+		return &PathError{Op: "setVisibility", Path: path, Err: err}
+	}
+
+	if err := d.chmod(validPath, visibility); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return &PathError{Op: "setVisibility", Path: path, Err: ErrFileNotFound}
+		}
+		return &PathError{Op: "setVisibility", Path: path, Err: err}
+	}
+
+	return nil
+}
+
+// getVisibility reports a file as private when it has PrivateFilePermissions
+func (d *LocalDisk) getVisibility(_ context.Context, path string) (Visibility, error) {
+	// Validate path
+	validPath, err := ValidatePath(path)
+	if err != nil {
+		return "", &PathError{Op: "getVisibility", Path: path, Err: err}
+	}
+
+	info, err := os.Stat(filepath.Join(d.config.Path, validPath))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", &PathError{Op: "getVisibility", Path: path, Err: ErrFileNotFound}
+		}
+		return "", &PathError{Op: "getVisibility", Path: path, Err: err}
+	}
+
+	if info.Mode().Perm() == d.config.PrivateFilePermissions {
+		return VisibilityPrivate, nil
+	}
+
+	return VisibilityPublic, nil
+}
+
+// chmod applies the permissions of a visibility to a file
+func (d *LocalDisk) chmod(validPath string, visibility Visibility) error {
+	mode := d.config.FilePermissions
+	if visibility == VisibilityPrivate {
+		mode = d.config.PrivateFilePermissions
+	}
+
+	return os.Chmod(filepath.Join(d.config.Path, validPath), mode)
+}
+
+// url returns the URL of a file below PublicBaseURL
+func (d *LocalDisk) url(_ context.Context, path string) (string, error) {
+	// Validate path
+	validPath, err := ValidatePath(path)
+	if err != nil {
+		return "", &PathError{Op: "url", Path: path, Err: err}
+	}
+
+	u, err := publicURL(d.config.PublicBaseURL, validPath)
+	if err != nil {
+		return "", &PathError{Op: "url", Path: path, Err: err}
+	}
+
+	return u, nil
+}
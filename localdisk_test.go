@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestLocalDisk_BasicOperations(t *testing.T) {
@@ -264,6 +265,53 @@ func TestLocalDisk_Metadata(t *testing.T) {
 	}
 }
 
+func TestLocalDisk_HTTPMetadata(t *testing.T) {
+	ctx := context.Background()
+
+	disk, err := NewLocalDisk(&LocalDiskConfig{Path: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create LocalDisk: %v", err)
+	}
+
+	expires := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	meta := &Metadata{
+		ContentType:        "application/pdf",
+		CacheControl:       "public, max-age=86400",
+		ContentDisposition: `attachment; filename="report.pdf"`,
+		ContentEncoding:    "identity",
+		ContentLanguage:    "en",
+		Expires:            expires,
+	}
+
+	if err := disk.putWithMetadata(ctx, "report.pdf", []byte("%PDF"), meta); err != nil {
+		t.Fatalf("PutWithMetadata failed: %v", err)
+	}
+
+	// Copies keep the headers, within a disk and across disks
+	if err := disk.copy(ctx, "report.pdf", "copy.pdf"); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+
+	storage := NewStorage()
+	storage.AddDisk("local", disk)
+	storage.AddDisk("memory", NewMemoryDisk())
+	if err := storage.CopyBetweenDisks(ctx, "local", "memory", "report.pdf", "report.pdf"); err != nil {
+		t.Fatalf("CopyBetweenDisks failed: %v", err)
+	}
+
+	for _, target := range []struct{ disk, path string }{{"local", "report.pdf"}, {"local", "copy.pdf"}, {"memory", "report.pdf"}} {
+		got, err := storage.GetMetadata(ctx, target.disk, target.path)
+		if err != nil {
+			t.Fatalf("GetMetadata failed: %v", err)
+		}
+		if got.CacheControl != meta.CacheControl || got.ContentDisposition != meta.ContentDisposition ||
+			got.ContentEncoding != meta.ContentEncoding || got.ContentLanguage != meta.ContentLanguage ||
+			!got.Expires.Equal(expires) {
+			t.Errorf("%s:%s: HTTP headers not preserved, got %+v", target.disk, target.path, got)
+		}
+	}
+}
+
 func TestLocalDisk_PathValidation(t *testing.T) {
 	tmpDir := t.TempDir()
 
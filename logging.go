@@ -0,0 +1,85 @@
+package gostorage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/aws/smithy-go/logging"
+)
+
+// DefaultSlowThreshold is the duration after which a storage operation is logged as slow
+const DefaultSlowThreshold = time.Second
+
+// discardLogger is used whenever no logger has been configured
+var discardLogger = slog.New(slog.DiscardHandler)
+
+// loggerOrDiscard returns the given logger, or a logger that discards everything if nil
+func loggerOrDiscard(logger *slog.Logger) *slog.Logger {
+	if logger == nil {
+		return discardLogger
+	}
+	return logger
+}
+
+// logOperation records the outcome of a storage operation.
+// Failures are logged at error level, slow calls at warn level and everything else at debug level.
+// Missing files are an ordinary outcome of lookups and are logged at debug level too.
+func (s *Storage) logOperation(ctx context.Context, op string, disk string, path string, start time.Time, err error) {
+	logger := loggerOrDiscard(s.Logger)
+	elapsed := time.Since(start)
+
+	attrs := []slog.Attr{
+		slog.String("op", op),
+		slog.String("disk", disk),
+		slog.String("path", path),
+		slog.Duration("duration", elapsed),
+	}
+
+	threshold := s.SlowThreshold
+	if threshold == 0 {
+		threshold = DefaultSlowThreshold
+	}
+
+	switch {
+	case errors.Is(err, ErrFileNotFound):
+		attrs = append(attrs, slog.Any("error", err))
+		logger.LogAttrs(ctx, slog.LevelDebug, "storage operation failed", attrs...)
+	case err != nil:
+		attrs = append(attrs, slog.Any("error", err))
+		logger.LogAttrs(ctx, slog.LevelError, "storage operation failed", attrs...)
+	case threshold > 0 && elapsed >= threshold:
+		logger.LogAttrs(ctx, slog.LevelWarn, "slow storage operation", attrs...)
+	default:
+		logger.LogAttrs(ctx, slog.LevelDebug, "storage operation", attrs...)
+	}
+}
+
+// sdkLogger adapts an slog.Logger to the logger interface used by the AWS SDK,
+// so that SDK retries and warnings end up in the same log stream
+type sdkLogger struct {
+	logger *slog.Logger
+	ctx    context.Context
+}
+
+// Logf implements logging.Logger
+func (l sdkLogger) Logf(classification logging.Classification, format string, v ...interface{}) {
+	ctx := l.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	level := slog.LevelDebug
+	if classification == logging.Warn {
+		level = slog.LevelWarn
+	}
+
+	l.logger.Log(ctx, level, fmt.Sprintf(format, v...), slog.String("source", "aws-sdk"))
+}
+
+// WithContext implements logging.ContextLogger
+func (l sdkLogger) WithContext(ctx context.Context) logging.Logger {
+	return sdkLogger{logger: l.logger, ctx: ctx}
+}
@@ -0,0 +1,66 @@
+package gostorage
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestStorage_Logging(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	disk, err := NewLocalDisk(&LocalDiskConfig{
+		Path:   tmpDir,
+		Logger: logger,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create LocalDisk: %v", err)
+	}
+
+	storage := NewStorage()
+	storage.Logger = logger
+	storage.AddDisk("local", disk)
+
+	ctx := context.Background()
+
+	// Successful operations are logged at debug level
+	if err := storage.Put(ctx, "local", "log.txt", []byte("content")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if !strings.Contains(buf.String(), "level=DEBUG msg=\"storage operation\" op=put disk=local path=log.txt") {
+		t.Errorf("Expected debug log for put, got: %s", buf.String())
+	}
+
+	// Missing files are logged at debug level
+	buf.Reset()
+	if _, err := storage.Get(ctx, "local", "missing.txt"); err == nil {
+		t.Fatal("Get should fail for missing file")
+	}
+	if !strings.Contains(buf.String(), "level=DEBUG msg=\"storage operation failed\" op=get") {
+		t.Errorf("Expected debug log for get, got: %s", buf.String())
+	}
+
+	// Other failures are logged at error level
+	buf.Reset()
+	if err := storage.Put(ctx, "local", "../escape.txt", []byte("x")); err == nil {
+		t.Fatal("Put should fail for traversal")
+	}
+	if !strings.Contains(buf.String(), "level=ERROR msg=\"storage operation failed\" op=put") {
+		t.Errorf("Expected error log for put, got: %s", buf.String())
+	}
+
+	// Every operation is slow with a tiny threshold
+	buf.Reset()
+	storage.SlowThreshold = 1
+	if _, err := storage.Exists(ctx, "local", "log.txt"); err != nil {
+		t.Fatalf("Exists failed: %v", err)
+	}
+	if !strings.Contains(buf.String(), "level=WARN msg=\"slow storage operation\" op=exists") {
+		t.Errorf("Expected slow call log for exists, got: %s", buf.String())
+	}
+}
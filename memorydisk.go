@@ -0,0 +1,527 @@
+package gostorage
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryDisk implements Disk interface with in-memory storage.
+// It is useful for tests and for small caches, content is lost when the process exits.
+type MemoryDisk struct {
+	mu          sync.RWMutex
+	files       map[string]*memoryFile
+	subscribers []*memorySubscriber
+}
+
+// memoryFile is a single file stored in a MemoryDisk
+type memoryFile struct {
+	content      []byte
+	metadata     *Metadata
+	lastModified time.Time
+	etag         string
+	visibility   Visibility
+}
+
+// memorySubscriber is a watcher registered on a MemoryDisk.
+// Changes are queued and delivered by the watcher's own goroutine, so writers never wait for it.
+type memorySubscriber struct {
+	ctx     context.Context
+	prefix  string
+	changes chan ChangeEvent
+
+	// wake signals the delivering goroutine that changes were queued
+	wake chan struct{}
+
+	// mu guards pending
+	mu      sync.Mutex
+	pending []ChangeEvent
+}
+
+// NewMemoryDisk creates a new empty MemoryDisk
+func NewMemoryDisk() *MemoryDisk {
+	return &MemoryDisk{
+		files: make(map[string]*memoryFile),
+	}
+}
+
+// put writes content to memory
+func (d *MemoryDisk) put(_ context.Context, path string, content []byte) error {
+	// Validate path
+	validPath, err := ValidatePath(path)
+	if err != nil {
+		return &PathError{Op: "put", Path: path, Err: err}
+	}
+
+	d.store(validPath, bytes.Clone(content), nil, "")
+	return nil
+}
+
+// get reads content from memory
+func (d *MemoryDisk) get(_ context.Context, path string) ([]byte, error) {
+	// Validate path
+	validPath, err := ValidatePath(path)
+	if err != nil {
+		return nil, &PathError{Op: "get", Path: path, Err: err}
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	file, ok := d.files[validPath]
+	if !ok {
+		return nil, &PathError{Op: "get", Path: path, Err: ErrFileNotFound}
+	}
+
+	return bytes.Clone(file.content), nil
+}
+
+// delete removes a file from memory
+func (d *MemoryDisk) delete(_ context.Context, path string) error {
+	// Validate path
+	validPath, err := ValidatePath(path)
+	if err != nil {
+		return &PathError{Op: "delete", Path: path, Err: err}
+	}
+
+	d.mu.Lock()
+	file, ok := d.files[validPath]
+	if !ok {
+		d.mu.Unlock()
+		return &PathError{Op: "delete", Path: path, Err: ErrFileNotFound}
+	}
+	delete(d.files, validPath)
+	subscribers := d.subscribersFor(validPath)
+	d.mu.Unlock()
+
+	d.notify(subscribers, ChangeEvent{
+		Type:         ChangeDeleted,
+		Path:         validPath,
+		Size:         int64(len(file.content)),
+		LastModified: file.lastModified,
+	})
+	return nil
+}
+
+// putStream writes content from a reader to memory
+func (d *MemoryDisk) putStream(_ context.Context, path string, reader io.Reader, metadata *Metadata) error {
+	// Validate path
+	validPath, err := ValidatePath(path)
+	if err != nil {
+		return &PathError{Op: "putStream", Path: path, Err: err}
+	}
+
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return &PathError{Op: "putStream", Path: path, Err: err}
+	}
+
+	d.store(validPath, content, metadata, "")
+	return nil
+}
+
+// getStream returns a reader for file content
+func (d *MemoryDisk) getStream(_ context.Context, path string) (io.ReadCloser, error) {
+	// Validate path
+	validPath, err := ValidatePath(path)
+	if err != nil {
+		return nil, &PathError{Op: "getStream", Path: path, Err: err}
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	file, ok := d.files[validPath]
+	if !ok {
+		return nil, &PathError{Op: "getStream", Path: path, Err: ErrFileNotFound}
+	}
+
+	// Stored content is never modified in place, so it can be read without copying
+	return io.NopCloser(bytes.NewReader(file.content)), nil
+}
+
+// exists checks if a file exists
+func (d *MemoryDisk) exists(_ context.Context, path string) (bool, error) {
+	// Validate path
+	validPath, err := ValidatePath(path)
+	if err != nil {
+		return false, &PathError{Op: "exists", Path: path, Err: err}
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	_, ok := d.files[validPath]
+	return ok, nil
+}
+
+// size returns the size of a file
+func (d *MemoryDisk) size(_ context.Context, path string) (int64, error) {
+	// Validate path
+	validPath, err := ValidatePath(path)
+	if err != nil {
+		return 0, &PathError{Op: "size", Path: path, Err: err}
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	file, ok := d.files[validPath]
+	if !ok {
+		return 0, &PathError{Op: "size", Path: path, Err: ErrFileNotFound}
+	}
+
+	return int64(len(file.content)), nil
+}
+
+// list returns a list of files whose path starts with prefix
+func (d *MemoryDisk) list(_ context.Context, prefix string) ([]FileInfo, error) {
+	// Validate prefix
+	validPrefix, err := ValidatePrefix(prefix)
+	if err != nil {
+		return nil, &PathError{Op: "list", Path: prefix, Err: err}
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	var files []FileInfo
+	for path, file := range d.files {
+		if !strings.HasPrefix(path, validPrefix) {
+			continue
+		}
+		files = append(files, FileInfo{
+			Path:         path,
+			Size:         int64(len(file.content)),
+			LastModified: file.lastModified,
+		})
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Path < files[j].Path
+	})
+
+	return files, nil
+}
+
+// copy copies a file from source to destination
+func (d *MemoryDisk) copy(ctx context.Context, sourcePath, destPath string) error {
+	// Validate paths
+	validSource, err := ValidatePath(sourcePath)
+	if err != nil {
+		return &PathError{Op: "copy", Path: sourcePath, Err: err}
+	}
+
+	validDest, err := ValidatePath(destPath)
+	if err != nil {
+		return &PathError{Op: "copy", Path: destPath, Err: err}
+	}
+
+	d.mu.RLock()
+	file, ok := d.files[validSource]
+	var visibility Visibility
+	if ok {
+		visibility = file.visibility
+	}
+	d.mu.RUnlock()
+	if !ok {
+		return &PathError{Op: "copy", Path: sourcePath, Err: ErrFileNotFound}
+	}
+
+	// The visibility may have changed since the file was written
+	d.store(validDest, bytes.Clone(file.content), file.metadata, visibility)
+	return nil
+}
+
+// move moves a file from source to destination
+func (d *MemoryDisk) move(ctx context.Context, sourcePath, destPath string) error {
+	// Copy the file
+	if err := d.copy(ctx, sourcePath, destPath); err != nil {
+		return err
+	}
+
+	// Delete the source
+	return d.delete(ctx, sourcePath)
+}
+
+// putWithMetadata writes content and metadata to memory
+func (d *MemoryDisk) putWithMetadata(_ context.Context, path string, content []byte, metadata *Metadata) error {
+	// Validate path
+	validPath, err := ValidatePath(path)
+	if err != nil {
+		return &PathError{Op: "putWithMetadata", Path: path, Err: err}
+	}
+
+	d.store(validPath, bytes.Clone(content), metadata, "")
+	return nil
+}
+
+// getMetadata retrieves metadata for a file
+func (d *MemoryDisk) getMetadata(_ context.Context, path string) (*Metadata, error) {
+	// Validate path
+	validPath, err := ValidatePath(path)
+	if err != nil {
+		return nil, &PathError{Op: "getMetadata", Path: path, Err: err}
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	file, ok := d.files[validPath]
+	if !ok {
+		return nil, &PathError{Op: "getMetadata", Path: path, Err: ErrFileNotFound}
+	}
+
+	if file.metadata == nil {
+		return nil, nil // No metadata is not an error
+	}
+
+	metadata := cloneMetadata(file.metadata)
+	metadata.Size = int64(len(file.content))
+	metadata.LastModified = file.lastModified
+	metadata.ETag = file.etag
+	return metadata, nil
+}
+
+// stat returns file information and metadata, the ETag is the MD5 of the content
+func (d *MemoryDisk) stat(_ context.Context, path string) (*FileInfo, error) {
+	// Validate path
+	validPath, err := ValidatePath(path)
+	if err != nil {
+		return nil, &PathError{Op: "stat", Path: path, Err: err}
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	file, ok := d.files[validPath]
+	if !ok {
+		return nil, &PathError{Op: "stat", Path: path, Err: ErrFileNotFound}
+	}
+
+	metadata := cloneMetadata(file.metadata)
+	if metadata == nil {
+		metadata = &Metadata{}
+	}
+	metadata.Size = int64(len(file.content))
+	metadata.LastModified = file.lastModified
+	metadata.ETag = file.etag
+
+	return &FileInfo{
+		Path:         validPath,
+		Size:         metadata.Size,
+		LastModified: metadata.LastModified,
+		Metadata:     metadata,
+	}, nil
+}
+
+// setMetadata updates metadata for a file
+func (d *MemoryDisk) setMetadata(_ context.Context, path string, metadata *Metadata) error {
+	// Validate path
+	validPath, err := ValidatePath(path)
+	if err != nil {
+		return &PathError{Op: "setMetadata", Path: path, Err: err}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	file, ok := d.files[validPath]
+	if !ok {
+		return &PathError{Op: "setMetadata", Path: path, Err: ErrFileNotFound}
+	}
+
+	file.metadata = cloneMetadata(metadata)
+	if metadata != nil && metadata.Visibility != "" {
+		file.visibility = metadata.Visibility
+	}
+	return nil
+}
+
+// watch pushes changes to the returned channel as they happen
+func (d *MemoryDisk) watch(ctx context.Context, prefix string) (<-chan ChangeEvent, error) {
+	// Validate prefix
+	validPrefix, err := ValidatePrefix(prefix)
+	if err != nil {
+		return nil, &PathError{Op: "watch", Path: prefix, Err: err}
+	}
+
+	subscriber := &memorySubscriber{
+		ctx:     ctx,
+		prefix:  validPrefix,
+		changes: make(chan ChangeEvent),
+		wake:    make(chan struct{}, 1),
+	}
+
+	d.mu.Lock()
+	d.subscribers = append(d.subscribers, subscriber)
+	d.mu.Unlock()
+
+	go func() {
+		defer close(subscriber.changes)
+		subscriber.deliver()
+
+		d.mu.Lock()
+		for i, s := range d.subscribers {
+			if s == subscriber {
+				d.subscribers = append(d.subscribers[:i], d.subscribers[i+1:]...)
+				break
+			}
+		}
+		d.mu.Unlock()
+	}()
+
+	return subscriber.changes, nil
+}
+
+// deliver sends queued changes to the watcher in order until its context is cancelled
+func (s *memorySubscriber) deliver() {
+	for {
+		s.mu.Lock()
+		pending := s.pending
+		s.pending = nil
+		s.mu.Unlock()
+
+		for _, change := range pending {
+			select {
+			case s.changes <- change:
+			case <-s.ctx.Done():
+				return
+			}
+		}
+
+		select {
+		case <-s.wake:
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// store saves a file and notifies watchers.
+// An empty visibility is taken from the metadata, files are public without one.
+func (d *MemoryDisk) store(validPath string, content []byte, metadata *Metadata, visibility Visibility) {
+	sum := md5.Sum(content)
+	file := &memoryFile{
+		content:      content,
+		metadata:     cloneMetadata(metadata),
+		lastModified: time.Now(),
+		etag:         hex.EncodeToString(sum[:]),
+		visibility:   visibility,
+	}
+	if file.visibility == "" && metadata != nil {
+		file.visibility = metadata.Visibility
+	}
+	if file.visibility == "" {
+		file.visibility = VisibilityPublic
+	}
+
+	d.mu.Lock()
+	_, existed := d.files[validPath]
+	d.files[validPath] = file
+	subscribers := d.subscribersFor(validPath)
+	d.mu.Unlock()
+
+	changeType := ChangeCreated
+	if existed {
+		changeType = ChangeModified
+	}
+
+	d.notify(subscribers, ChangeEvent{
+		Type:         changeType,
+		Path:         validPath,
+		Size:         int64(len(content)),
+		LastModified: file.lastModified,
+	})
+}
+
+// subscribersFor returns the watchers interested in a path, the caller must hold the lock
+func (d *MemoryDisk) subscribersFor(validPath string) []*memorySubscriber {
+	var subscribers []*memorySubscriber
+	for _, s := range d.subscribers {
+		if strings.HasPrefix(validPath, s.prefix) {
+			subscribers = append(subscribers, s)
+		}
+	}
+	return subscribers
+}
+
+// notify queues a change for watchers without waiting for them to receive it
+func (d *MemoryDisk) notify(subscribers []*memorySubscriber, change ChangeEvent) {
+	for _, s := range subscribers {
+		s.mu.Lock()
+		s.pending = append(s.pending, change)
+		s.mu.Unlock()
+
+		select {
+		case s.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// cloneMetadata returns a deep copy of metadata so callers cannot mutate stored values
+func cloneMetadata(metadata *Metadata) *Metadata {
+	if metadata == nil {
+		return nil
+	}
+
+	clone := *metadata
+	if metadata.CustomHeaders != nil {
+		clone.CustomHeaders = make(map[string]string, len(metadata.CustomHeaders))
+		for k, v := range metadata.CustomHeaders {
+			clone.CustomHeaders[k] = v
+		}
+	}
+	return &clone
+}
+
+// ping always succeeds for memory storage
+func (d *MemoryDisk) ping(_ context.Context) error {
+	return nil
+}
+
+// setVisibility records whether a file is public, files are public by default
+func (d *MemoryDisk) setVisibility(_ context.Context, path string, visibility Visibility) error {
+	// Validate path
+	validPath, err := ValidatePath(path)
+	if err != nil {
+		return &PathError{Op: "setVisibility", Path: path, Err: err}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	file, ok := d.files[validPath]
+	if !ok {
+		return &PathError{Op: "setVisibility", Path: path, Err: ErrFileNotFound}
+	}
+
+	file.visibility = visibility
+	return nil
+}
+
+// getVisibility returns the recorded visibility of a file
+func (d *MemoryDisk) getVisibility(_ context.Context, path string) (Visibility, error) {
+	// Validate path
+	validPath, err := ValidatePath(path)
+	if err != nil {
+		return "", &PathError{Op: "getVisibility", Path: path, Err: err}
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	file, ok := d.files[validPath]
+	if !ok {
+		return "", &PathError{Op: "getVisibility", Path: path, Err: ErrFileNotFound}
+	}
+
+	return file.visibility, nil
+}
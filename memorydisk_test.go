@@ -0,0 +1,104 @@
+package gostorage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestMemoryDisk_BasicOperations(t *testing.T) {
+	disk := NewMemoryDisk()
+	ctx := context.Background()
+
+	// Test Put
+	content := []byte("Hello, Memory!")
+	if err := disk.put(ctx, "dir/test.txt", content); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	// Mutating the caller's slice must not change the stored content
+	content[0] = 'J'
+
+	// Test Get
+	data, err := disk.get(ctx, "dir/test.txt")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if string(data) != "Hello, Memory!" {
+		t.Errorf("Expected %s, got %s", "Hello, Memory!", data)
+	}
+
+	// Test Size
+	size, err := disk.size(ctx, "dir/test.txt")
+	if err != nil {
+		t.Fatalf("Size failed: %v", err)
+	}
+	if size != int64(len(content)) {
+		t.Errorf("Expected size %d, got %d", len(content), size)
+	}
+
+	// Test List
+	files, err := disk.list(ctx, "dir")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(files) != 1 || files[0].Path != "dir/test.txt" {
+		t.Errorf("Unexpected listing: %+v", files)
+	}
+
+	// Test Delete
+	if err := disk.delete(ctx, "dir/test.txt"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	_, err = disk.get(ctx, "dir/test.txt")
+	if !errors.Is(err, ErrFileNotFound) {
+		t.Errorf("Expected ErrFileNotFound, got %v", err)
+	}
+}
+
+func TestMemoryDisk_StreamAndMetadata(t *testing.T) {
+	disk := NewMemoryDisk()
+	ctx := context.Background()
+
+	meta := &Metadata{
+		ContentType:   "text/plain",
+		CustomHeaders: map[string]string{"author": "Test"},
+	}
+
+	if err := disk.putStream(ctx, "stream.txt", bytes.NewReader([]byte("streamed")), meta); err != nil {
+		t.Fatalf("PutStream failed: %v", err)
+	}
+
+	reader, err := disk.getStream(ctx, "stream.txt")
+	if err != nil {
+		t.Fatalf("GetStream failed: %v", err)
+	}
+	data, _ := io.ReadAll(reader)
+	reader.Close()
+	if string(data) != "streamed" {
+		t.Errorf("Expected streamed, got %s", data)
+	}
+
+	// Copy keeps metadata
+	if err := disk.copy(ctx, "stream.txt", "copy.txt"); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+
+	retrieved, err := disk.getMetadata(ctx, "copy.txt")
+	if err != nil {
+		t.Fatalf("GetMetadata failed: %v", err)
+	}
+	if retrieved == nil || retrieved.ContentType != "text/plain" || retrieved.CustomHeaders["author"] != "Test" {
+		t.Errorf("Unexpected metadata: %+v", retrieved)
+	}
+	if retrieved.Size != 8 {
+		t.Errorf("Expected metadata size 8, got %d", retrieved.Size)
+	}
+
+	if err := disk.setMetadata(ctx, "missing.txt", meta); !errors.Is(err, ErrFileNotFound) {
+		t.Errorf("Expected ErrFileNotFound, got %v", err)
+	}
+}
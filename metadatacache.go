@@ -0,0 +1,118 @@
+package gostorage
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// DefaultMetadataCacheSize is the default number of entries kept by a metadata cache
+const DefaultMetadataCacheSize = 10000
+
+// metadataCache is a TTL and size bounded LRU cache of object metadata.
+// A nil cache is valid and caches nothing.
+type metadataCache struct {
+	ttl        time.Duration
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+// metadataCacheEntry is a single cached metadata value
+type metadataCacheEntry struct {
+	key      string
+	metadata *Metadata
+	expires  time.Time
+}
+
+// newMetadataCache creates a metadata cache, or returns nil if ttl disables caching
+func newMetadataCache(ttl time.Duration, maxEntries int) *metadataCache {
+	if ttl <= 0 {
+		return nil
+	}
+
+	if maxEntries <= 0 {
+		maxEntries = DefaultMetadataCacheSize
+	}
+
+	return &metadataCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		lru:        list.New(),
+	}
+}
+
+// get returns a copy of the cached metadata for key if it has not expired
+func (c *metadataCache) get(key string) (*Metadata, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := elem.Value.(*metadataCacheEntry)
+	if time.Now().After(entry.expires) {
+		c.lru.Remove(elem)
+		delete(c.entries, key)
+		return nil, false
+	}
+
+	c.lru.MoveToFront(elem)
+	return cloneMetadata(entry.metadata), true
+}
+
+// set caches metadata for key, evicting the least recently used entry when full
+func (c *metadataCache) set(key string, metadata *Metadata) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := &metadataCacheEntry{
+		key:      key,
+		metadata: cloneMetadata(metadata),
+		expires:  time.Now().Add(c.ttl),
+	}
+
+	if elem, ok := c.entries[key]; ok {
+		elem.Value = entry
+		c.lru.MoveToFront(elem)
+		return
+	}
+
+	c.entries[key] = c.lru.PushFront(entry)
+
+	if c.lru.Len() > c.maxEntries {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*metadataCacheEntry).key)
+	}
+}
+
+// invalidate removes cached metadata for the given keys
+func (c *metadataCache) invalidate(keys ...string) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, key := range keys {
+		if elem, ok := c.entries[key]; ok {
+			c.lru.Remove(elem)
+			delete(c.entries, key)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package gostorage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMetadataCache(t *testing.T) {
+	cache := newMetadataCache(time.Hour, 2)
+
+	cache.set("a", &Metadata{Size: 1, CustomHeaders: map[string]string{"k": "v"}})
+	cache.set("b", &Metadata{Size: 2})
+
+	metadata, ok := cache.get("a")
+	if !ok || metadata.Size != 1 {
+		t.Fatalf("Expected cached metadata, got %+v, %v", metadata, ok)
+	}
+
+	// Callers get a copy
+	metadata.CustomHeaders["k"] = "changed"
+	if metadata, _ := cache.get("a"); metadata.CustomHeaders["k"] != "v" {
+		t.Error("Cached metadata should not be modified through a returned copy")
+	}
+
+	// b is the least recently used entry
+	cache.set("c", &Metadata{Size: 3})
+	if _, ok := cache.get("b"); ok {
+		t.Error("Expected b to be evicted")
+	}
+
+	cache.invalidate("a", "missing")
+	if _, ok := cache.get("a"); ok {
+		t.Error("Expected a to be invalidated")
+	}
+	if _, ok := cache.get("c"); !ok {
+		t.Error("Expected c to be cached")
+	}
+}
+
+func TestMetadataCache_Expiry(t *testing.T) {
+	cache := newMetadataCache(time.Millisecond, 0)
+	cache.set("a", &Metadata{Size: 1})
+
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := cache.get("a"); ok {
+		t.Error("Expected entry to expire")
+	}
+}
+
+func TestMetadataCache_Disabled(t *testing.T) {
+	cache := newMetadataCache(0, 0)
+	if cache != nil {
+		t.Fatal("Expected a zero TTL to disable the cache")
+	}
+
+	// A nil cache is usable and caches nothing
+	cache.set("a", &Metadata{})
+	cache.invalidate("a")
+	if _, ok := cache.get("a"); ok {
+		t.Error("Disabled cache should not return entries")
+	}
+}
@@ -0,0 +1,548 @@
+package gostorage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// DefaultRepairInterval is how often a MirrorDisk retries writes that failed on some replicas
+const DefaultRepairInterval = 30 * time.Second
+
+// WritePolicy decides how many replicas of a MirrorDisk must accept a write
+type WritePolicy int
+
+const (
+	// WriteAll requires every replica to succeed
+	WriteAll WritePolicy = iota
+
+	// WriteQuorum requires MirrorDiskConfig.Quorum replicas to succeed
+	WriteQuorum
+)
+
+// MirrorDiskConfig holds configuration for a MirrorDisk
+type MirrorDiskConfig struct {
+	// Replicas receive every write, reads prefer them in order
+	Replicas []Disk
+
+	// WritePolicy decides when a write succeeds (default: WriteAll)
+	WritePolicy WritePolicy
+
+	// Quorum is the number of replicas that must succeed with WriteQuorum (default: majority)
+	Quorum int
+
+	// RepairInterval is how often failed replicas are repaired (default: 30s)
+	RepairInterval time.Duration
+
+	// Logger receives repair failures (default: discard)
+	Logger *slog.Logger
+}
+
+// MirrorDisk implements Disk interface by replicating writes and deletes to several disks.
+// Reads go to the first healthy replica. Replicas that missed a write are marked unhealthy
+// and repaired in the background from a replica that succeeded.
+type MirrorDisk struct {
+	replicas []Disk
+	required int
+	logger   *slog.Logger
+
+	mu        sync.Mutex
+	pending   []map[string]repairTask
+	unhealthy []bool
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// repairAction is what a replica has to do to catch up on a path
+type repairAction int
+
+const (
+	repairPut repairAction = iota
+	repairDelete
+)
+
+// repairTask is a write a replica missed
+type repairTask struct {
+	action repairAction
+	source int
+}
+
+// NewMirrorDisk creates a new MirrorDisk and starts its background repair
+func NewMirrorDisk(cfg *MirrorDiskConfig) (*MirrorDisk, error) {
+	if cfg == nil {
+		return nil, errors.New("config cannot be nil")
+	}
+
+	if len(cfg.Replicas) == 0 {
+		return nil, errors.New("at least one replica is required")
+	}
+
+	for i, replica := range cfg.Replicas {
+		if replica == nil {
+			return nil, fmt.Errorf("replica %d cannot be nil", i)
+		}
+	}
+
+	required := len(cfg.Replicas)
+	if cfg.WritePolicy == WriteQuorum {
+		required = cfg.Quorum
+		if required == 0 {
+			required = len(cfg.Replicas)/2 + 1
+		}
+		if required < 1 || required > len(cfg.Replicas) {
+			return nil, fmt.Errorf("quorum must be between 1 and %d", len(cfg.Replicas))
+		}
+	}
+
+	interval := cfg.RepairInterval
+	if interval <= 0 {
+		interval = DefaultRepairInterval
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	d := &MirrorDisk{
+		replicas:  cfg.Replicas,
+		required:  required,
+		logger:    loggerOrDiscard(cfg.Logger),
+		pending:   make([]map[string]repairTask, len(cfg.Replicas)),
+		unhealthy: make([]bool, len(cfg.Replicas)),
+		cancel:    cancel,
+		done:      make(chan struct{}),
+	}
+	for i := range d.pending {
+		d.pending[i] = make(map[string]repairTask)
+	}
+
+	go d.repairLoop(ctx, interval)
+
+	return d, nil
+}
+
+// Close stops the background repair
+func (d *MirrorDisk) Close() error {
+	d.cancel()
+	<-d.done
+	return nil
+}
+
+// Repair retries every pending write on the replicas that missed it
+func (d *MirrorDisk) Repair(ctx context.Context) error {
+	var errs []error
+	for i := range d.replicas {
+		if err := d.repairReplica(ctx, i); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// repairLoop repairs failed replicas until the disk is closed
+func (d *MirrorDisk) repairLoop(ctx context.Context, interval time.Duration) {
+	defer close(d.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := d.Repair(ctx); err != nil && ctx.Err() == nil {
+				d.logger.WarnContext(ctx, "mirror repair failed", slog.Any("error", err))
+			}
+		}
+	}
+}
+
+// repairReplica replays the writes a replica missed
+func (d *MirrorDisk) repairReplica(ctx context.Context, i int) error {
+	d.mu.Lock()
+	tasks := make(map[string]repairTask, len(d.pending[i]))
+	for path, task := range d.pending[i] {
+		tasks[path] = task
+	}
+	d.mu.Unlock()
+
+	var errs []error
+	for path, task := range tasks {
+		if err := d.replay(ctx, i, path, task); err != nil {
+			errs = append(errs, fmt.Errorf("replica %d: %w", i, err))
+			continue
+		}
+
+		d.mu.Lock()
+		// A newer write may have replaced the task while it was replayed
+		if d.pending[i][path] == task {
+			delete(d.pending[i], path)
+		}
+		d.mu.Unlock()
+	}
+
+	d.mu.Lock()
+	if len(d.pending[i]) == 0 {
+		d.unhealthy[i] = false
+	}
+	d.mu.Unlock()
+
+	return errors.Join(errs...)
+}
+
+// replay applies a missed write to a replica
+func (d *MirrorDisk) replay(ctx context.Context, i int, path string, task repairTask) error {
+	replica := d.replicas[i]
+
+	if task.action == repairPut {
+		source := d.replicas[task.source]
+
+		// Metadata can hold headers that wrappers such as CompressedDisk need to read the content,
+		// a replica written without it would be unreadable through them
+		metadata, err := source.getMetadata(ctx, path)
+		if err == nil {
+			var reader io.ReadCloser
+			reader, err = source.getStream(ctx, path)
+			if err == nil {
+				defer reader.Close()
+
+				if visibility, err := diskVisibility(ctx, source, path); err == nil {
+					if metadata == nil {
+						metadata = &Metadata{}
+					}
+					metadata.Visibility = visibility
+				}
+				return replica.putStream(ctx, path, reader, metadata)
+			}
+		}
+		if !errors.Is(err, ErrFileNotFound) {
+			return err
+		}
+		// Deleted from the source since, delete it here as well
+	}
+
+	if err := replica.delete(ctx, path); err != nil && !errors.Is(err, ErrFileNotFound) {
+		return err
+	}
+	return nil
+}
+
+// fanOut runs a write on every replica concurrently. Replicas that fail are queued for repair of
+// the given paths when at least one replica succeeded, the write fails below the required count.
+func (d *MirrorDisk) fanOut(op string, path string, repairs map[string]repairAction, write func(int, Disk) error) error {
+	errs := make([]error, len(d.replicas))
+
+	var wg sync.WaitGroup
+	for i, replica := range d.replicas {
+		wg.Go(func() {
+			errs[i] = write(i, replica)
+		})
+	}
+	wg.Wait()
+
+	source := -1
+	succeeded := 0
+	var failures []error
+	for i, err := range errs {
+		if err != nil {
+			failures = append(failures, err)
+			continue
+		}
+		if source < 0 {
+			source = i
+		}
+		succeeded++
+	}
+
+	d.mu.Lock()
+	for i, err := range errs {
+		if err == nil {
+			// A successful write supersedes an earlier missed one
+			for p := range repairs {
+				delete(d.pending[i], p)
+			}
+			continue
+		}
+
+		if source < 0 {
+			continue
+		}
+		for p, action := range repairs {
+			d.pending[i][p] = repairTask{action: action, source: source}
+		}
+		d.unhealthy[i] = true
+	}
+	d.mu.Unlock()
+
+	if succeeded < d.required {
+		return &PathError{Op: op, Path: path, Err: fmt.Errorf("%d of %d replicas succeeded: %w", succeeded, len(d.replicas), errors.Join(failures...))}
+	}
+
+	return nil
+}
+
+// readFrom runs a read on the first healthy replica that is up to date for path,
+// falling back to the other replicas when it fails for reasons other than a missing file
+func readFrom[T any](d *MirrorDisk, path string, read func(Disk) (T, error)) (T, error) {
+	d.mu.Lock()
+	var preferred, fallback []int
+	for i := range d.replicas {
+		if _, stale := d.pending[i][path]; stale || d.unhealthy[i] {
+			fallback = append(fallback, i)
+		} else {
+			preferred = append(preferred, i)
+		}
+	}
+	d.mu.Unlock()
+
+	var zero T
+	var err error
+	for _, i := range append(preferred, fallback...) {
+		var result T
+		result, err = read(d.replicas[i])
+		if err == nil || errors.Is(err, ErrFileNotFound) {
+			return result, err
+		}
+
+		d.mu.Lock()
+		d.unhealthy[i] = true
+		d.mu.Unlock()
+	}
+
+	return zero, err
+}
+
+// put writes content to every replica
+func (d *MirrorDisk) put(ctx context.Context, path string, content []byte) error {
+	return d.fanOut("put", path, map[string]repairAction{path: repairPut}, func(_ int, replica Disk) error {
+		return replica.put(ctx, path, content)
+	})
+}
+
+// get reads content from the first healthy replica
+func (d *MirrorDisk) get(ctx context.Context, path string) ([]byte, error) {
+	return readFrom(d, path, func(replica Disk) ([]byte, error) {
+		return replica.get(ctx, path)
+	})
+}
+
+// delete removes a file from every replica
+func (d *MirrorDisk) delete(ctx context.Context, path string) error {
+	var mu sync.Mutex
+	missing := 0
+
+	err := d.fanOut("delete", path, map[string]repairAction{path: repairDelete}, func(_ int, replica Disk) error {
+		err := replica.delete(ctx, path)
+		if errors.Is(err, ErrFileNotFound) {
+			// Already gone from this replica
+			mu.Lock()
+			missing++
+			mu.Unlock()
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if missing == len(d.replicas) {
+		return &PathError{Op: "delete", Path: path, Err: ErrFileNotFound}
+	}
+
+	return nil
+}
+
+// putStream streams content to every replica at once
+func (d *MirrorDisk) putStream(ctx context.Context, path string, reader io.Reader, metadata *Metadata) error {
+	readers := make([]*io.PipeReader, len(d.replicas))
+	writers := make([]*io.PipeWriter, len(d.replicas))
+	for i := range d.replicas {
+		readers[i], writers[i] = io.Pipe()
+	}
+
+	// Copy the source into every pipe, a replica that stopped reading is dropped
+	copyErr := make(chan error, 1)
+	go func() {
+		buf := make([]byte, 32*1024)
+		active := make([]bool, len(writers))
+		for i := range active {
+			active[i] = true
+		}
+
+		var err error
+		for {
+			n, readErr := reader.Read(buf)
+			if n > 0 {
+				for i, w := range writers {
+					if active[i] {
+						if _, werr := w.Write(buf[:n]); werr != nil {
+							active[i] = false
+						}
+					}
+				}
+			}
+			if readErr != nil {
+				if readErr != io.EOF {
+					err = readErr
+				}
+				break
+			}
+		}
+
+		for _, w := range writers {
+			w.CloseWithError(err)
+		}
+		copyErr <- err
+	}()
+
+	err := d.fanOut("putStream", path, map[string]repairAction{path: repairPut}, func(i int, replica Disk) error {
+		err := replica.putStream(ctx, path, readers[i], metadata)
+		if err != nil {
+			readers[i].CloseWithError(err)
+		} else {
+			readers[i].Close()
+		}
+		return err
+	})
+
+	if srcErr := <-copyErr; srcErr != nil {
+		return &PathError{Op: "putStream", Path: path, Err: srcErr}
+	}
+
+	return err
+}
+
+// getStream returns a reader from the first healthy replica
+func (d *MirrorDisk) getStream(ctx context.Context, path string) (io.ReadCloser, error) {
+	return readFrom(d, path, func(replica Disk) (io.ReadCloser, error) {
+		return replica.getStream(ctx, path)
+	})
+}
+
+// exists checks if a file exists on the first healthy replica
+func (d *MirrorDisk) exists(ctx context.Context, path string) (bool, error) {
+	return readFrom(d, path, func(replica Disk) (bool, error) {
+		return replica.exists(ctx, path)
+	})
+}
+
+// size returns the size of a file on the first healthy replica
+func (d *MirrorDisk) size(ctx context.Context, path string) (int64, error) {
+	return readFrom(d, path, func(replica Disk) (int64, error) {
+		return replica.size(ctx, path)
+	})
+}
+
+// list returns the listing of the first healthy replica
+func (d *MirrorDisk) list(ctx context.Context, prefix string) ([]FileInfo, error) {
+	return readFrom(d, "", func(replica Disk) ([]FileInfo, error) {
+		return replica.list(ctx, prefix)
+	})
+}
+
+// copy copies a file on every replica
+func (d *MirrorDisk) copy(ctx context.Context, sourcePath, destPath string) error {
+	return d.fanOut("copy", sourcePath, map[string]repairAction{destPath: repairPut}, func(_ int, replica Disk) error {
+		return replica.copy(ctx, sourcePath, destPath)
+	})
+}
+
+// move moves a file on every replica
+func (d *MirrorDisk) move(ctx context.Context, sourcePath, destPath string) error {
+	repairs := map[string]repairAction{sourcePath: repairDelete, destPath: repairPut}
+	return d.fanOut("move", sourcePath, repairs, func(_ int, replica Disk) error {
+		return replica.move(ctx, sourcePath, destPath)
+	})
+}
+
+// putWithMetadata writes content and metadata to every replica
+func (d *MirrorDisk) putWithMetadata(ctx context.Context, path string, content []byte, metadata *Metadata) error {
+	return d.fanOut("putWithMetadata", path, map[string]repairAction{path: repairPut}, func(_ int, replica Disk) error {
+		return replica.putWithMetadata(ctx, path, content, metadata)
+	})
+}
+
+// getMetadata retrieves metadata from the first healthy replica
+func (d *MirrorDisk) getMetadata(ctx context.Context, path string) (*Metadata, error) {
+	return readFrom(d, path, func(replica Disk) (*Metadata, error) {
+		return replica.getMetadata(ctx, path)
+	})
+}
+
+// setMetadata updates metadata on every replica
+func (d *MirrorDisk) setMetadata(ctx context.Context, path string, metadata *Metadata) error {
+	return d.fanOut("setMetadata", path, map[string]repairAction{path: repairPut}, func(_ int, replica Disk) error {
+		return replica.setMetadata(ctx, path, metadata)
+	})
+}
+
+// ping checks every replica and fails when fewer than the required number respond.
+// Replicas that respond and have nothing left to repair are marked healthy again.
+func (d *MirrorDisk) ping(ctx context.Context) error {
+	errs := make([]error, len(d.replicas))
+
+	var wg sync.WaitGroup
+	for i, replica := range d.replicas {
+		wg.Go(func() {
+			errs[i] = replica.ping(ctx)
+		})
+	}
+	wg.Wait()
+
+	responding := 0
+	var failures []error
+
+	d.mu.Lock()
+	for i, err := range errs {
+		if err != nil {
+			d.unhealthy[i] = true
+			failures = append(failures, fmt.Errorf("replica %d: %w", i, err))
+			continue
+		}
+
+		responding++
+		if len(d.pending[i]) == 0 {
+			d.unhealthy[i] = false
+		}
+	}
+	d.mu.Unlock()
+
+	if responding < d.required {
+		return fmt.Errorf("%d of %d replicas responding: %w", responding, len(d.replicas), errors.Join(failures...))
+	}
+
+	return nil
+}
+
+// stat returns file information from the first healthy replica
+func (d *MirrorDisk) stat(ctx context.Context, path string) (*FileInfo, error) {
+	return readFrom(d, path, func(replica Disk) (*FileInfo, error) {
+		return statDisk(ctx, replica, path)
+	})
+}
+
+// setVisibility changes the visibility on every replica, missed replicas are repaired with a full copy
+func (d *MirrorDisk) setVisibility(ctx context.Context, path string, visibility Visibility) error {
+	return d.fanOut("setVisibility", path, map[string]repairAction{path: repairPut}, func(_ int, replica Disk) error {
+		return setDiskVisibility(ctx, replica, path, visibility)
+	})
+}
+
+// getVisibility returns the visibility from the first healthy replica
+func (d *MirrorDisk) getVisibility(ctx context.Context, path string) (Visibility, error) {
+	return readFrom(d, path, func(replica Disk) (Visibility, error) {
+		return diskVisibility(ctx, replica, path)
+	})
+}
+
+// url returns the public URL from the first healthy replica
+func (d *MirrorDisk) url(ctx context.Context, path string) (string, error) {
+	return readFrom(d, path, func(replica Disk) (string, error) {
+		return diskURL(ctx, replica, path)
+	})
+}
@@ -0,0 +1,205 @@
+package gostorage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sync/atomic"
+	"testing"
+)
+
+var errUnavailable = errors.New("unavailable")
+
+// flakyDisk is a MemoryDisk whose writes and reads fail while down is set
+// and whose metadata cannot be read while metadataDown is set
+type flakyDisk struct {
+	*MemoryDisk
+	down         atomic.Bool
+	metadataDown atomic.Bool
+}
+
+func newFlakyDisk() *flakyDisk {
+	return &flakyDisk{MemoryDisk: NewMemoryDisk()}
+}
+
+func (d *flakyDisk) put(ctx context.Context, path string, content []byte) error {
+	if d.down.Load() {
+		return &PathError{Op: "put", Path: path, Err: errUnavailable}
+	}
+	return d.MemoryDisk.put(ctx, path, content)
+}
+
+func (d *flakyDisk) putStream(ctx context.Context, path string, reader io.Reader, metadata *Metadata) error {
+	if d.down.Load() {
+		return &PathError{Op: "putStream", Path: path, Err: errUnavailable}
+	}
+	return d.MemoryDisk.putStream(ctx, path, reader, metadata)
+}
+
+func (d *flakyDisk) delete(ctx context.Context, path string) error {
+	if d.down.Load() {
+		return &PathError{Op: "delete", Path: path, Err: errUnavailable}
+	}
+	return d.MemoryDisk.delete(ctx, path)
+}
+
+func (d *flakyDisk) get(ctx context.Context, path string) ([]byte, error) {
+	if d.down.Load() {
+		return nil, &PathError{Op: "get", Path: path, Err: errUnavailable}
+	}
+	return d.MemoryDisk.get(ctx, path)
+}
+
+func (d *flakyDisk) getMetadata(ctx context.Context, path string) (*Metadata, error) {
+	if d.metadataDown.Load() {
+		return nil, &PathError{Op: "getMetadata", Path: path, Err: errUnavailable}
+	}
+	return d.MemoryDisk.getMetadata(ctx, path)
+}
+
+func (d *flakyDisk) ping(ctx context.Context) error {
+	if d.down.Load() {
+		return &PathError{Op: "ping", Path: "", Err: errUnavailable}
+	}
+	return d.MemoryDisk.ping(ctx)
+}
+
+func TestMirrorDisk_WriteAll(t *testing.T) {
+	ctx := context.Background()
+	a, b := newFlakyDisk(), newFlakyDisk()
+
+	disk, err := NewMirrorDisk(&MirrorDiskConfig{Replicas: []Disk{a, b}})
+	if err != nil {
+		t.Fatalf("Failed to create MirrorDisk: %v", err)
+	}
+	defer disk.Close()
+
+	content := bytes.Repeat([]byte("mirror"), 20000)
+	if err := disk.putStream(ctx, "big.bin", bytes.NewReader(content), nil); err != nil {
+		t.Fatalf("putStream failed: %v", err)
+	}
+	for i, replica := range []*flakyDisk{a, b} {
+		data, err := replica.MemoryDisk.get(ctx, "big.bin")
+		if err != nil || !bytes.Equal(data, content) {
+			t.Errorf("Replica %d has wrong content: %v", i, err)
+		}
+	}
+
+	b.down.Store(true)
+	if err := disk.put(ctx, "file.txt", []byte("data")); !errors.Is(err, errUnavailable) {
+		t.Errorf("Expected write to fail when a replica is down, got %v", err)
+	}
+
+	if err := disk.delete(ctx, "missing.txt"); err == nil {
+		t.Error("Expected delete of a missing file to fail")
+	}
+}
+
+func TestMirrorDisk_QuorumAndRepair(t *testing.T) {
+	ctx := context.Background()
+	a, b, c := newFlakyDisk(), newFlakyDisk(), newFlakyDisk()
+
+	disk, err := NewMirrorDisk(&MirrorDiskConfig{
+		Replicas:    []Disk{a, b, c},
+		WritePolicy: WriteQuorum,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create MirrorDisk: %v", err)
+	}
+	defer disk.Close()
+
+	if err := disk.put(ctx, "old.txt", []byte("old")); err != nil {
+		t.Fatalf("put failed: %v", err)
+	}
+
+	a.down.Store(true)
+	if err := disk.put(ctx, "file.txt", []byte("data")); err != nil {
+		t.Fatalf("Expected quorum write to succeed, got %v", err)
+	}
+	if err := disk.delete(ctx, "old.txt"); err != nil {
+		t.Fatalf("Expected quorum delete to succeed, got %v", err)
+	}
+
+	// Reads skip the replica that missed the write
+	data, err := disk.get(ctx, "file.txt")
+	if err != nil || string(data) != "data" {
+		t.Errorf("Expected read from a healthy replica, got %q, %v", data, err)
+	}
+
+	// Repair fails while the replica is down and succeeds once it is back
+	if err := disk.Repair(ctx); err == nil {
+		t.Error("Expected repair to fail while the replica is down")
+	}
+	a.down.Store(false)
+	if err := disk.Repair(ctx); err != nil {
+		t.Fatalf("Repair failed: %v", err)
+	}
+
+	data, err = a.get(ctx, "file.txt")
+	if err != nil || string(data) != "data" {
+		t.Errorf("Expected repaired replica to have the file, got %q, %v", data, err)
+	}
+	if exists, _ := a.exists(ctx, "old.txt"); exists {
+		t.Error("Expected repaired replica to have the delete applied")
+	}
+
+	// Below quorum the write fails
+	b.down.Store(true)
+	c.down.Store(true)
+	if err := disk.put(ctx, "file.txt", []byte("new")); err == nil {
+		t.Error("Expected write below quorum to fail")
+	}
+}
+
+func TestMirrorDisk_RepairKeepsMetadata(t *testing.T) {
+	ctx := context.Background()
+	a, b, c := newFlakyDisk(), newFlakyDisk(), newFlakyDisk()
+
+	disk, err := NewMirrorDisk(&MirrorDiskConfig{
+		Replicas:    []Disk{a, b, c},
+		WritePolicy: WriteQuorum,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create MirrorDisk: %v", err)
+	}
+	defer disk.Close()
+
+	a.down.Store(true)
+	metadata := &Metadata{CustomHeaders: map[string]string{CodecHeader: "zstd"}}
+	if err := disk.putStream(ctx, "logs/app.log", bytes.NewReader([]byte("compressed")), metadata); err != nil {
+		t.Fatalf("Expected quorum write to succeed, got %v", err)
+	}
+	a.down.Store(false)
+
+	// Without the metadata the replica could not be read through a CompressedDisk, so repair waits
+	b.metadataDown.Store(true)
+	c.metadataDown.Store(true)
+	if err := disk.Repair(ctx); !errors.Is(err, errUnavailable) {
+		t.Errorf("Expected repair to fail while metadata is unavailable, got %v", err)
+	}
+	if exists, _ := a.exists(ctx, "logs/app.log"); exists {
+		t.Error("Expected replica not to be written without metadata")
+	}
+
+	b.metadataDown.Store(false)
+	c.metadataDown.Store(false)
+	if err := disk.Repair(ctx); err != nil {
+		t.Fatalf("Repair failed: %v", err)
+	}
+	repaired, err := a.getMetadata(ctx, "logs/app.log")
+	if err != nil || repaired.CustomHeaders[CodecHeader] != "zstd" {
+		t.Errorf("Expected repaired replica to keep the codec header, got %+v, %v", repaired, err)
+	}
+}
+
+func TestNewMirrorDisk_InvalidQuorum(t *testing.T) {
+	_, err := NewMirrorDisk(&MirrorDiskConfig{
+		Replicas:    []Disk{NewMemoryDisk(), NewMemoryDisk()},
+		WritePolicy: WriteQuorum,
+		Quorum:      3,
+	})
+	if err == nil {
+		t.Error("Expected error for a quorum larger than the number of replicas")
+	}
+}
@@ -0,0 +1,323 @@
+package gostorage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Operation is a kind of disk operation that a Policy can allow or deny
+type Operation string
+
+const (
+	// OpGet covers reading content and metadata: get, getStream, exists, size and getMetadata
+	OpGet Operation = "get"
+
+	// OpPut covers writing content and metadata: put, putStream, putWithMetadata and setMetadata
+	OpPut Operation = "put"
+
+	// OpDelete covers deleting files
+	OpDelete Operation = "delete"
+
+	// OpList covers listing files
+	OpList Operation = "list"
+)
+
+// PolicyRule allows or denies operations below a path prefix
+type PolicyRule struct {
+	// Prefix is the directory the rule applies to, empty matches every path
+	Prefix string
+
+	// Operations the rule applies to, empty matches every operation
+	Operations []Operation
+
+	// Allow permits matching operations, otherwise they are denied
+	Allow bool
+}
+
+// Policy decides which operations are permitted.
+// Rules are checked in order and the first matching rule wins.
+type Policy struct {
+	Rules []PolicyRule
+
+	// DefaultDeny denies operations that match no rule, otherwise they are allowed
+	DefaultDeny bool
+}
+
+// Allowed reports whether the policy permits op on path
+func (p *Policy) Allowed(op Operation, path string) bool {
+	path = strings.Trim(filepath.ToSlash(path), "/")
+
+	for _, rule := range p.Rules {
+		if len(rule.Operations) > 0 && !slices.Contains(rule.Operations, op) {
+			continue
+		}
+
+		prefix := strings.Trim(filepath.ToSlash(rule.Prefix), "/")
+		if prefix != "" && path != prefix && !strings.HasPrefix(path, prefix+"/") {
+			continue
+		}
+
+		return rule.Allow
+	}
+
+	return !p.DefaultDeny
+}
+
+// PolicyDisk wraps a Disk and rejects operations its Policy does not allow with ErrPermissionDenied.
+// Copying needs OpGet on the source and OpPut on the destination, moving additionally needs OpDelete on the source.
+type PolicyDisk struct {
+	disk   Disk
+	policy *Policy
+}
+
+// NewPolicyDisk creates a new PolicyDisk enforcing policy on disk
+func NewPolicyDisk(disk Disk, policy *Policy) (*PolicyDisk, error) {
+	if disk == nil {
+		return nil, errors.New("disk cannot be nil")
+	}
+
+	if policy == nil {
+		return nil, errors.New("policy cannot be nil")
+	}
+
+	return &PolicyDisk{
+		disk:   disk,
+		policy: policy,
+	}, nil
+}
+
+// NewReadOnlyDisk creates a PolicyDisk that denies every write and delete on disk
+func NewReadOnlyDisk(disk Disk) (*PolicyDisk, error) {
+	return NewPolicyDisk(disk, &Policy{
+		Rules: []PolicyRule{
+			{Operations: []Operation{OpPut, OpDelete}, Allow: false},
+		},
+	})
+}
+
+// check returns ErrPermissionDenied wrapped in a PathError when op is not allowed on path
+func (d *PolicyDisk) check(op Operation, opName string, path string) error {
+	// Validate path so that "a/../archive/x" is checked as "archive/x"
+	validPath, err := ValidatePath(path)
+	if err != nil {
+		return &PathError{Op: opName, Path: path, Err: err}
+	}
+
+	if !d.policy.Allowed(op, validPath) {
+		return &PathError{Op: opName, Path: path, Err: ErrPermissionDenied}
+	}
+
+	return nil
+}
+
+// put writes content if allowed
+func (d *PolicyDisk) put(ctx context.Context, path string, content []byte) error {
+	if err := d.check(OpPut, "put", path); err != nil {
+		return err
+	}
+	return d.disk.put(ctx, path, content)
+}
+
+// get reads content if allowed
+func (d *PolicyDisk) get(ctx context.Context, path string) ([]byte, error) {
+	if err := d.check(OpGet, "get", path); err != nil {
+		return nil, err
+	}
+	return d.disk.get(ctx, path)
+}
+
+// delete removes a file if allowed
+func (d *PolicyDisk) delete(ctx context.Context, path string) error {
+	if err := d.check(OpDelete, "delete", path); err != nil {
+		return err
+	}
+	return d.disk.delete(ctx, path)
+}
+
+// putStream writes content from a reader if allowed
+func (d *PolicyDisk) putStream(ctx context.Context, path string, reader io.Reader, metadata *Metadata) error {
+	if err := d.check(OpPut, "putStream", path); err != nil {
+		return err
+	}
+	return d.disk.putStream(ctx, path, reader, metadata)
+}
+
+// getStream returns a reader for file content if allowed
+func (d *PolicyDisk) getStream(ctx context.Context, path string) (io.ReadCloser, error) {
+	if err := d.check(OpGet, "getStream", path); err != nil {
+		return nil, err
+	}
+	return d.disk.getStream(ctx, path)
+}
+
+// exists checks if a file exists if allowed
+func (d *PolicyDisk) exists(ctx context.Context, path string) (bool, error) {
+	if err := d.check(OpGet, "exists", path); err != nil {
+		return false, err
+	}
+	return d.disk.exists(ctx, path)
+}
+
+// size returns the size of a file if allowed
+func (d *PolicyDisk) size(ctx context.Context, path string) (int64, error) {
+	if err := d.check(OpGet, "size", path); err != nil {
+		return 0, err
+	}
+	return d.disk.size(ctx, path)
+}
+
+// list returns the files below prefix that may be listed
+func (d *PolicyDisk) list(ctx context.Context, prefix string) ([]FileInfo, error) {
+	// Validate prefix
+	validPrefix, err := ValidatePrefix(prefix)
+	if err != nil {
+		return nil, &PathError{Op: "list", Path: prefix, Err: err}
+	}
+
+	if !d.policy.Allowed(OpList, validPrefix) {
+		return nil, &PathError{Op: "list", Path: prefix, Err: ErrPermissionDenied}
+	}
+
+	files, err := d.disk.list(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	// A listing of a parent must not reveal denied sub-directories
+	allowed := make([]FileInfo, 0, len(files))
+	for _, file := range files {
+		if d.policy.Allowed(OpList, file.Path) {
+			allowed = append(allowed, file)
+		}
+	}
+
+	return allowed, nil
+}
+
+// copy copies a file if reading the source and writing the destination are allowed
+func (d *PolicyDisk) copy(ctx context.Context, sourcePath, destPath string) error {
+	if err := d.check(OpGet, "copy", sourcePath); err != nil {
+		return err
+	}
+	if err := d.check(OpPut, "copy", destPath); err != nil {
+		return err
+	}
+	return d.disk.copy(ctx, sourcePath, destPath)
+}
+
+// move moves a file if reading and deleting the source and writing the destination are allowed
+func (d *PolicyDisk) move(ctx context.Context, sourcePath, destPath string) error {
+	if err := d.check(OpGet, "move", sourcePath); err != nil {
+		return err
+	}
+	if err := d.check(OpDelete, "move", sourcePath); err != nil {
+		return err
+	}
+	if err := d.check(OpPut, "move", destPath); err != nil {
+		return err
+	}
+	return d.disk.move(ctx, sourcePath, destPath)
+}
+
+// putWithMetadata writes content and metadata if allowed
+func (d *PolicyDisk) putWithMetadata(ctx context.Context, path string, content []byte, metadata *Metadata) error {
+	if err := d.check(OpPut, "putWithMetadata", path); err != nil {
+		return err
+	}
+	return d.disk.putWithMetadata(ctx, path, content, metadata)
+}
+
+// getMetadata retrieves metadata for a file if allowed
+func (d *PolicyDisk) getMetadata(ctx context.Context, path string) (*Metadata, error) {
+	if err := d.check(OpGet, "getMetadata", path); err != nil {
+		return nil, err
+	}
+	return d.disk.getMetadata(ctx, path)
+}
+
+// setMetadata updates metadata for a file if allowed
+func (d *PolicyDisk) setMetadata(ctx context.Context, path string, metadata *Metadata) error {
+	if err := d.check(OpPut, "setMetadata", path); err != nil {
+		return err
+	}
+	return d.disk.setMetadata(ctx, path, metadata)
+}
+
+// ping checks the underlying disk, health checks are always allowed
+func (d *PolicyDisk) ping(ctx context.Context) error {
+	return d.disk.ping(ctx)
+}
+
+// stat returns file information if reading is allowed
+func (d *PolicyDisk) stat(ctx context.Context, path string) (*FileInfo, error) {
+	if err := d.check(OpGet, "stat", path); err != nil {
+		return nil, err
+	}
+	return statDisk(ctx, d.disk, path)
+}
+
+// setVisibility changes the visibility of a file if writing is allowed
+func (d *PolicyDisk) setVisibility(ctx context.Context, path string, visibility Visibility) error {
+	if err := d.check(OpPut, "setVisibility", path); err != nil {
+		return err
+	}
+	return setDiskVisibility(ctx, d.disk, path, visibility)
+}
+
+// getVisibility returns the visibility of a file if reading is allowed
+func (d *PolicyDisk) getVisibility(ctx context.Context, path string) (Visibility, error) {
+	if err := d.check(OpGet, "getVisibility", path); err != nil {
+		return "", err
+	}
+	return diskVisibility(ctx, d.disk, path)
+}
+
+// url returns the public URL of a file if reading is allowed
+func (d *PolicyDisk) url(ctx context.Context, path string) (string, error) {
+	if err := d.check(OpGet, "url", path); err != nil {
+		return "", err
+	}
+	return diskURL(ctx, d.disk, path)
+}
+
+// deleteMany deletes the allowed files, in batches if the underlying disk supports it
+func (d *PolicyDisk) deleteMany(ctx context.Context, paths []string, concurrency int) []error {
+	errs := make([]error, len(paths))
+
+	allowed := make([]string, 0, len(paths))
+	indexes := make([]int, 0, len(paths))
+	for i, path := range paths {
+		if err := d.check(OpDelete, "delete", path); err != nil {
+			errs[i] = err
+			continue
+		}
+		allowed = append(allowed, path)
+		indexes = append(indexes, i)
+	}
+
+	for j, err := range deleteFiles(ctx, d.disk, allowed, concurrency) {
+		errs[indexes[j]] = err
+	}
+
+	return errs
+}
+
+// typeDetector returns the detector of the underlying disk
+func (d *PolicyDisk) typeDetector() *ContentTypeDetector {
+	return diskDetector(d.disk)
+}
+
+// removeDirectory removes an empty directory, directories the policy protects from deletes are kept
+func (d *PolicyDisk) removeDirectory(ctx context.Context, path string) error {
+	if err := d.check(OpDelete, "removeDirectory", path); err != nil {
+		if errors.Is(err, ErrPermissionDenied) {
+			return nil
+		}
+		return err
+	}
+	return removeDiskDirectory(ctx, d.disk, path)
+}
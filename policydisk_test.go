@@ -0,0 +1,130 @@
+package gostorage
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestReadOnlyDisk(t *testing.T) {
+	ctx := context.Background()
+
+	backend := NewMemoryDisk()
+	if err := backend.put(ctx, "report.txt", []byte("data")); err != nil {
+		t.Fatalf("put failed: %v", err)
+	}
+
+	readOnly, err := NewReadOnlyDisk(backend)
+	if err != nil {
+		t.Fatalf("Failed to create read-only disk: %v", err)
+	}
+
+	storage := NewStorage()
+	storage.AddDisk("prod", readOnly)
+
+	data, err := storage.Get(ctx, "prod", "report.txt")
+	if err != nil || string(data) != "data" {
+		t.Errorf("Expected reads to be allowed, got %q, %v", data, err)
+	}
+	if _, err := storage.List(ctx, "prod", ""); err != nil {
+		t.Errorf("Expected listing to be allowed, got %v", err)
+	}
+
+	checks := map[string]error{
+		"Put":         storage.Put(ctx, "prod", "new.txt", []byte("x")),
+		"Delete":      storage.Delete(ctx, "prod", "report.txt"),
+		"Copy":        storage.Copy(ctx, "prod", "report.txt", "copy.txt"),
+		"Move":        storage.Move(ctx, "prod", "report.txt", "moved.txt"),
+		"SetMetadata": storage.SetMetadata(ctx, "prod", "report.txt", &Metadata{ContentType: "text/plain"}),
+	}
+	for op, err := range checks {
+		if !errors.Is(err, ErrPermissionDenied) {
+			t.Errorf("Expected %s to be denied, got %v", op, err)
+		}
+	}
+
+	if exists, _ := backend.exists(ctx, "report.txt"); !exists {
+		t.Error("File should not have been deleted")
+	}
+}
+
+func TestPolicyDisk_Rules(t *testing.T) {
+	ctx := context.Background()
+
+	disk, err := NewPolicyDisk(NewMemoryDisk(), &Policy{
+		Rules: []PolicyRule{
+			{Prefix: "archive/", Operations: []Operation{OpDelete}, Allow: false},
+			{Prefix: "uploads", Operations: []Operation{OpPut}, Allow: true},
+			{Prefix: "uploads"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create policy disk: %v", err)
+	}
+
+	storage := NewStorage()
+	storage.AddDisk("files", disk)
+
+	if err := storage.Put(ctx, "files", "archive/2023.txt", []byte("old")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := storage.Delete(ctx, "files", "archive/2023.txt"); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("Expected delete under archive/ to be denied, got %v", err)
+	}
+
+	// Traversal is resolved before the policy is checked
+	if err := storage.Delete(ctx, "files", "other/../archive/2023.txt"); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("Expected delete via traversal to be denied, got %v", err)
+	}
+
+	if err := storage.Put(ctx, "files", "uploads/a.txt", []byte("a")); err != nil {
+		t.Errorf("Expected put under uploads/ to be allowed, got %v", err)
+	}
+	if _, err := storage.Get(ctx, "files", "uploads/a.txt"); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("Expected get under uploads/ to be denied, got %v", err)
+	}
+	if err := storage.Move(ctx, "files", "archive/2023.txt", "uploads/2023.txt"); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("Expected move out of archive/ to be denied, got %v", err)
+	}
+
+	// Denied entries are hidden from listings of a parent
+	files, err := storage.List(ctx, "files", "")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	for _, f := range files {
+		if f.Path == "uploads/a.txt" {
+			t.Error("Listing should not include files under uploads/")
+		}
+	}
+	if _, err := storage.List(ctx, "files", "uploads"); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("Expected listing uploads/ to be denied, got %v", err)
+	}
+}
+
+func TestPolicy_Allowed(t *testing.T) {
+	policy := &Policy{
+		Rules: []PolicyRule{
+			{Prefix: "public", Operations: []Operation{OpGet, OpList}, Allow: true},
+		},
+		DefaultDeny: true,
+	}
+
+	tests := []struct {
+		op       Operation
+		path     string
+		expected bool
+	}{
+		{OpGet, "public/logo.png", true},
+		{OpList, "public", true},
+		{OpPut, "public/logo.png", false},
+		{OpGet, "publicity/plan.txt", false},
+		{OpGet, "private/key.pem", false},
+	}
+
+	for _, tt := range tests {
+		if got := policy.Allowed(tt.op, tt.path); got != tt.expected {
+			t.Errorf("Allowed(%s, %q) = %v, expected %v", tt.op, tt.path, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,355 @@
+package gostorage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"path/filepath"
+	"strings"
+)
+
+// PrefixedDisk wraps a Disk and roots it at a sub-path.
+// Every path is validated before the prefix is applied, so callers cannot escape the prefix.
+type PrefixedDisk struct {
+	disk   Disk
+	prefix string
+}
+
+// NewPrefixedDisk creates a new PrefixedDisk rooted at prefix on disk
+func NewPrefixedDisk(disk Disk, prefix string) (*PrefixedDisk, error) {
+	if disk == nil {
+		return nil, errors.New("disk cannot be nil")
+	}
+
+	validPrefix, err := ValidatePrefix(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	validPrefix = strings.Trim(filepath.ToSlash(validPrefix), "/")
+	if validPrefix == "" || validPrefix == "." {
+		return nil, errors.New("prefix is required")
+	}
+
+	return &PrefixedDisk{
+		disk:   disk,
+		prefix: validPrefix,
+	}, nil
+}
+
+// Scope returns a Storage in which disk is rooted at prefix.
+// Other disks, the logger and the event handlers registered so far are shared with s.
+// Paths in errors and events are relative to the prefix.
+func (s *Storage) Scope(disk string, prefix string) (*Storage, error) {
+	d := s.getDisk(disk)
+	if d == nil {
+		return nil, ErrDiskNotFound(disk)
+	}
+
+	scoped, err := NewPrefixedDisk(d, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	s.handlersMu.RLock()
+	handlers := make(map[EventType][]EventHandler, len(s.handlers))
+	for eventType, h := range s.handlers {
+		handlers[eventType] = append([]EventHandler(nil), h...)
+	}
+	s.handlersMu.RUnlock()
+
+	disks := maps.Clone(s.Disks)
+	disks[disk] = scoped
+
+	return &Storage{
+		Disks:         disks,
+		Logger:        s.Logger,
+		SlowThreshold: s.SlowThreshold,
+		handlers:      handlers,
+	}, nil
+}
+
+// fullPath validates a path and applies the prefix
+func (d *PrefixedDisk) fullPath(path string) (string, error) {
+	validPath, err := ValidatePath(path)
+	if err != nil {
+		return "", err
+	}
+
+	validPath = filepath.ToSlash(validPath)
+	if validPath == "." || validPath == "" {
+		return "", fmt.Errorf("%w: path refers to the scope root", ErrInvalidPath)
+	}
+
+	return d.prefix + "/" + validPath, nil
+}
+
+// scopeError hides the prefix from errors returned by the underlying disk
+func (d *PrefixedDisk) scopeError(err error, path string) error {
+	var pathErr *PathError
+	if errors.As(err, &pathErr) {
+		return &PathError{Op: pathErr.Op, Path: path, Err: pathErr.Err}
+	}
+	return err
+}
+
+// put writes content below the prefix
+func (d *PrefixedDisk) put(ctx context.Context, path string, content []byte) error {
+	full, err := d.fullPath(path)
+	if err != nil {
+		return &PathError{Op: "put", Path: path, Err: err}
+	}
+
+	return d.scopeError(d.disk.put(ctx, full, content), path)
+}
+
+// get reads content below the prefix
+func (d *PrefixedDisk) get(ctx context.Context, path string) ([]byte, error) {
+	full, err := d.fullPath(path)
+	if err != nil {
+		return nil, &PathError{Op: "get", Path: path, Err: err}
+	}
+
+	content, err := d.disk.get(ctx, full)
+	return content, d.scopeError(err, path)
+}
+
+// delete removes a file below the prefix
+func (d *PrefixedDisk) delete(ctx context.Context, path string) error {
+	full, err := d.fullPath(path)
+	if err != nil {
+		return &PathError{Op: "delete", Path: path, Err: err}
+	}
+
+	return d.scopeError(d.disk.delete(ctx, full), path)
+}
+
+// putStream writes content from a reader below the prefix
+func (d *PrefixedDisk) putStream(ctx context.Context, path string, reader io.Reader, metadata *Metadata) error {
+	full, err := d.fullPath(path)
+	if err != nil {
+		return &PathError{Op: "putStream", Path: path, Err: err}
+	}
+
+	return d.scopeError(d.disk.putStream(ctx, full, reader, metadata), path)
+}
+
+// getStream returns a reader for a file below the prefix
+func (d *PrefixedDisk) getStream(ctx context.Context, path string) (io.ReadCloser, error) {
+	full, err := d.fullPath(path)
+	if err != nil {
+		return nil, &PathError{Op: "getStream", Path: path, Err: err}
+	}
+
+	reader, err := d.disk.getStream(ctx, full)
+	return reader, d.scopeError(err, path)
+}
+
+// exists checks if a file exists below the prefix
+func (d *PrefixedDisk) exists(ctx context.Context, path string) (bool, error) {
+	full, err := d.fullPath(path)
+	if err != nil {
+		return false, &PathError{Op: "exists", Path: path, Err: err}
+	}
+
+	exists, err := d.disk.exists(ctx, full)
+	return exists, d.scopeError(err, path)
+}
+
+// size returns the size of a file below the prefix
+func (d *PrefixedDisk) size(ctx context.Context, path string) (int64, error) {
+	full, err := d.fullPath(path)
+	if err != nil {
+		return 0, &PathError{Op: "size", Path: path, Err: err}
+	}
+
+	size, err := d.disk.size(ctx, full)
+	return size, d.scopeError(err, path)
+}
+
+// list returns the files below the prefix with paths relative to it
+func (d *PrefixedDisk) list(ctx context.Context, prefix string) ([]FileInfo, error) {
+	// Validate prefix
+	validPrefix, err := ValidatePrefix(prefix)
+	if err != nil {
+		return nil, &PathError{Op: "list", Path: prefix, Err: err}
+	}
+
+	full := d.prefix
+	if validPrefix = filepath.ToSlash(validPrefix); validPrefix != "" && validPrefix != "." {
+		full = d.prefix + "/" + validPrefix
+	}
+
+	files, err := d.disk.list(ctx, full)
+	if err != nil {
+		return nil, d.scopeError(err, prefix)
+	}
+
+	scoped := make([]FileInfo, 0, len(files))
+	for _, file := range files {
+		// Skip the prefix itself and siblings that merely share its name, e.g. "tenant-10" for "tenant-1"
+		rel, ok := strings.CutPrefix(file.Path, d.prefix+"/")
+		if !ok || rel == "" {
+			continue
+		}
+
+		file.Path = rel
+		scoped = append(scoped, file)
+	}
+
+	return scoped, nil
+}
+
+// copy copies a file within the prefix
+func (d *PrefixedDisk) copy(ctx context.Context, sourcePath, destPath string) error {
+	fullSource, err := d.fullPath(sourcePath)
+	if err != nil {
+		return &PathError{Op: "copy", Path: sourcePath, Err: err}
+	}
+
+	fullDest, err := d.fullPath(destPath)
+	if err != nil {
+		return &PathError{Op: "copy", Path: destPath, Err: err}
+	}
+
+	return d.scopeError(d.disk.copy(ctx, fullSource, fullDest), sourcePath)
+}
+
+// move moves a file within the prefix
+func (d *PrefixedDisk) move(ctx context.Context, sourcePath, destPath string) error {
+	fullSource, err := d.fullPath(sourcePath)
+	if err != nil {
+		return &PathError{Op: "move", Path: sourcePath, Err: err}
+	}
+
+	fullDest, err := d.fullPath(destPath)
+	if err != nil {
+		return &PathError{Op: "move", Path: destPath, Err: err}
+	}
+
+	return d.scopeError(d.disk.move(ctx, fullSource, fullDest), sourcePath)
+}
+
+// putWithMetadata writes content and metadata below the prefix
+func (d *PrefixedDisk) putWithMetadata(ctx context.Context, path string, content []byte, metadata *Metadata) error {
+	full, err := d.fullPath(path)
+	if err != nil {
+		return &PathError{Op: "putWithMetadata", Path: path, Err: err}
+	}
+
+	return d.scopeError(d.disk.putWithMetadata(ctx, full, content, metadata), path)
+}
+
+// getMetadata retrieves metadata for a file below the prefix
+func (d *PrefixedDisk) getMetadata(ctx context.Context, path string) (*Metadata, error) {
+	full, err := d.fullPath(path)
+	if err != nil {
+		return nil, &PathError{Op: "getMetadata", Path: path, Err: err}
+	}
+
+	metadata, err := d.disk.getMetadata(ctx, full)
+	return metadata, d.scopeError(err, path)
+}
+
+// setMetadata updates metadata for a file below the prefix
+func (d *PrefixedDisk) setMetadata(ctx context.Context, path string, metadata *Metadata) error {
+	full, err := d.fullPath(path)
+	if err != nil {
+		return &PathError{Op: "setMetadata", Path: path, Err: err}
+	}
+
+	return d.scopeError(d.disk.setMetadata(ctx, full, metadata), path)
+}
+
+// ping checks the underlying disk
+func (d *PrefixedDisk) ping(ctx context.Context) error {
+	return d.disk.ping(ctx)
+}
+
+// stat returns file information for a file below the prefix
+func (d *PrefixedDisk) stat(ctx context.Context, path string) (*FileInfo, error) {
+	full, err := d.fullPath(path)
+	if err != nil {
+		return nil, &PathError{Op: "stat", Path: path, Err: err}
+	}
+
+	info, err := statDisk(ctx, d.disk, full)
+	if err != nil {
+		return nil, d.scopeError(err, path)
+	}
+
+	info.Path = strings.TrimPrefix(info.Path, d.prefix+"/")
+	return info, nil
+}
+
+// setVisibility changes the visibility of a file below the prefix
+func (d *PrefixedDisk) setVisibility(ctx context.Context, path string, visibility Visibility) error {
+	full, err := d.fullPath(path)
+	if err != nil {
+		return &PathError{Op: "setVisibility", Path: path, Err: err}
+	}
+
+	return d.scopeError(setDiskVisibility(ctx, d.disk, full, visibility), path)
+}
+
+// getVisibility returns the visibility of a file below the prefix
+func (d *PrefixedDisk) getVisibility(ctx context.Context, path string) (Visibility, error) {
+	full, err := d.fullPath(path)
+	if err != nil {
+		return "", &PathError{Op: "getVisibility", Path: path, Err: err}
+	}
+
+	visibility, err := diskVisibility(ctx, d.disk, full)
+	return visibility, d.scopeError(err, path)
+}
+
+// url returns the public URL of a file below the prefix
+func (d *PrefixedDisk) url(ctx context.Context, path string) (string, error) {
+	full, err := d.fullPath(path)
+	if err != nil {
+		return "", &PathError{Op: "url", Path: path, Err: err}
+	}
+
+	u, err := diskURL(ctx, d.disk, full)
+	return u, d.scopeError(err, path)
+}
+
+// deleteMany deletes files below the prefix, in batches if the underlying disk supports it
+func (d *PrefixedDisk) deleteMany(ctx context.Context, paths []string, concurrency int) []error {
+	errs := make([]error, len(paths))
+
+	full := make([]string, 0, len(paths))
+	indexes := make([]int, 0, len(paths))
+	for i, path := range paths {
+		p, err := d.fullPath(path)
+		if err != nil {
+			errs[i] = &PathError{Op: "delete", Path: path, Err: err}
+			continue
+		}
+		full = append(full, p)
+		indexes = append(indexes, i)
+	}
+
+	for j, err := range deleteFiles(ctx, d.disk, full, concurrency) {
+		errs[indexes[j]] = d.scopeError(err, paths[indexes[j]])
+	}
+
+	return errs
+}
+
+// typeDetector returns the detector of the underlying disk
+func (d *PrefixedDisk) typeDetector() *ContentTypeDetector {
+	return diskDetector(d.disk)
+}
+
+// removeDirectory removes an empty directory below the prefix
+func (d *PrefixedDisk) removeDirectory(ctx context.Context, path string) error {
+	full, err := d.fullPath(path)
+	if err != nil {
+		return &PathError{Op: "removeDirectory", Path: path, Err: err}
+	}
+
+	return d.scopeError(removeDiskDirectory(ctx, d.disk, full), path)
+}
@@ -0,0 +1,77 @@
+package gostorage
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPrefixedDisk(t *testing.T) {
+	ctx := context.Background()
+
+	local, err := NewLocalDisk(&LocalDiskConfig{Path: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create LocalDisk: %v", err)
+	}
+
+	for name, backend := range map[string]Disk{"local": local, "memory": NewMemoryDisk()} {
+		t.Run(name, func(t *testing.T) {
+			storage := NewStorage()
+			storage.AddDisk("files", backend)
+
+			tenant1, err := storage.Scope("files", "tenants/1")
+			if err != nil {
+				t.Fatalf("Scope failed: %v", err)
+			}
+			tenant10, err := storage.Scope("files", "tenants/10")
+			if err != nil {
+				t.Fatalf("Scope failed: %v", err)
+			}
+
+			if err := tenant1.Put(ctx, "files", "docs/a.txt", []byte("one")); err != nil {
+				t.Fatalf("Put failed: %v", err)
+			}
+			if err := tenant10.Put(ctx, "files", "docs/b.txt", []byte("ten")); err != nil {
+				t.Fatalf("Put failed: %v", err)
+			}
+
+			// Written below the prefix on the underlying disk
+			data, err := storage.Get(ctx, "files", "tenants/1/docs/a.txt")
+			if err != nil || string(data) != "one" {
+				t.Errorf("Expected file below prefix, got %q, %v", data, err)
+			}
+
+			// Listings only show the tenant's own files
+			files, err := tenant1.List(ctx, "files", "")
+			if err != nil {
+				t.Fatalf("List failed: %v", err)
+			}
+			for _, f := range files {
+				if strings.Contains(f.Path, "b.txt") || strings.HasPrefix(f.Path, "tenants") {
+					t.Errorf("Unexpected entry in tenant listing: %s", f.Path)
+				}
+			}
+
+			// Escapes are rejected
+			for _, path := range []string{"../10/docs/b.txt", "docs/../../10/docs/b.txt", ".", ""} {
+				if _, err := tenant1.Get(ctx, "files", path); err == nil {
+					t.Errorf("Get %q should fail", path)
+				}
+			}
+
+			// Errors do not reveal the prefix
+			_, err = tenant1.Get(ctx, "files", "missing.txt")
+			if !errors.Is(err, ErrFileNotFound) {
+				t.Errorf("Expected ErrFileNotFound, got %v", err)
+			}
+			if strings.Contains(err.Error(), "tenants") {
+				t.Errorf("Error reveals the prefix: %v", err)
+			}
+		})
+	}
+
+	if _, err := NewPrefixedDisk(NewMemoryDisk(), "../outside"); err == nil {
+		t.Error("Should reject prefix with directory traversal")
+	}
+}
@@ -4,14 +4,21 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
 )
 
 // S3Config contains configuration for S3/MinIO storage
@@ -40,12 +47,42 @@ type S3Config struct {
 
 	// SessionToken is optional for temporary credentials
 	SessionToken string
+
+	// Logger receives SDK retries and warnings (default: discard)
+	Logger *slog.Logger
+
+	// WatchInterval is how often the bucket is listed when watching for changes (default: 10s)
+	WatchInterval time.Duration
+
+	// MetadataCacheTTL caches HeadObject results used by exists, size, getMetadata and stat.
+	// Writes through this disk invalidate the cache, changes made by other clients are
+	// visible after the TTL at the latest (default: 0, disabled)
+	MetadataCacheTTL time.Duration
+
+	// MetadataCacheSize is the maximum number of cached entries (default: 10000)
+	MetadataCacheSize int
+
+	// ContentTypeDetector fills in the content type of objects written without one,
+	// S3 would otherwise store them as binary/octet-stream (default: DefaultContentTypeDetector)
+	ContentTypeDetector *ContentTypeDetector
+
+	// DisableContentTypeDetection stores objects without a content type unless one is given
+	DisableContentTypeDetection bool
+
+	// PublicBaseURL is the URL objects are served from, e.g. a CDN in front of the bucket.
+	// Keys are appended including Prefix (default: the bucket URL, virtual-hosted or
+	// path-style following UsePathStyle and Endpoint)
+	PublicBaseURL string
 }
 
 // S3Disk implements Disk interface for AWS S3
 type S3Disk struct {
-	client *s3.Client
-	config *S3Config
+	client    *s3.Client
+	config    *S3Config
+	logger    *slog.Logger
+	headCache *metadataCache
+	detector  *ContentTypeDetector
+	baseURL   string
 }
 
 // NewS3Disk creates a new S3Disk with the given configuration
@@ -62,7 +99,16 @@ func NewS3Disk(cfg *S3Config) (*S3Disk, error) {
 		cfg.Region = "us-east-1" // Default region
 	}
 
-	// Load AWS config
+	baseURL := cfg.PublicBaseURL
+	if baseURL == "" {
+		baseURL = bucketURL(cfg)
+	} else if err := validateBaseURL(baseURL); err != nil {
+		return nil, err
+	}
+
+	logger := loggerOrDiscard(cfg.Logger)
+
+	// Load AWS config, routing SDK retry logs through our logger
 	awsConfig, err := config.LoadDefaultConfig(context.Background(),
 		config.WithRegion(cfg.Region),
 		config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(
@@ -70,6 +116,8 @@ func NewS3Disk(cfg *S3Config) (*S3Disk, error) {
 			cfg.SecretKey,
 			cfg.SessionToken,
 		)),
+		config.WithLogger(sdkLogger{logger: logger}),
+		config.WithClientLogMode(aws.LogRetries),
 	)
 	if err != nil {
 		return nil, err
@@ -85,8 +133,12 @@ func NewS3Disk(cfg *S3Config) (*S3Disk, error) {
 	})
 
 	return &S3Disk{
-		client: s3Client,
-		config: cfg,
+		client:    s3Client,
+		config:    cfg,
+		logger:    logger,
+		headCache: newMetadataCache(cfg.MetadataCacheTTL, cfg.MetadataCacheSize),
+		detector:  contentTypeDetector(cfg.ContentTypeDetector, cfg.DisableContentTypeDetection),
+		baseURL:   baseURL,
 	}, nil
 }
 
@@ -107,13 +159,18 @@ func (d *S3Disk) put(ctx context.Context, path string, content []byte) error {
 	}
 
 	key := d.buildKey(validPath)
+	defer d.headCache.invalidate(key)
 
-	_, err = d.client.PutObject(ctx, &s3.PutObjectInput{
+	input := &s3.PutObjectInput{
 		Bucket: aws.String(d.config.Bucket),
 		Key:    aws.String(key),
 		Body:   bytes.NewReader(content),
-	})
+	}
+
+	// Set the detected content type
+	applyPutMetadata(input, detectMetadata(d.detector, validPath, content, nil))
 
+	_, err = d.client.PutObject(ctx, input)
 	if err != nil {
 		return &PathError{Op: "put", Path: path, Err: err}
 	}
@@ -163,6 +220,7 @@ func (d *S3Disk) delete(ctx context.Context, path string) error {
 	}
 
 	key := d.buildKey(validPath)
+	defer d.headCache.invalidate(key)
 
 	_, err = d.client.DeleteObject(ctx, &s3.DeleteObjectInput{
 		Bucket: aws.String(d.config.Bucket),
@@ -185,6 +243,13 @@ func (d *S3Disk) putStream(ctx context.Context, path string, reader io.Reader, m
 	}
 
 	key := d.buildKey(validPath)
+	defer d.headCache.invalidate(key)
+
+	// Fill in the content type when it is not given
+	reader, metadata, err = detectStream(d.detector, validPath, reader, metadata)
+	if err != nil {
+		return &PathError{Op: "putStream", Path: path, Err: err}
+	}
 
 	input := &s3.PutObjectInput{
 		Bucket: aws.String(d.config.Bucket),
@@ -193,14 +258,7 @@ func (d *S3Disk) putStream(ctx context.Context, path string, reader io.Reader, m
 	}
 
 	// Add metadata if provided
-	if metadata != nil {
-		if metadata.ContentType != "" {
-			input.ContentType = aws.String(metadata.ContentType)
-		}
-		if len(metadata.CustomHeaders) > 0 {
-			input.Metadata = metadata.CustomHeaders
-		}
-	}
+	applyPutMetadata(input, metadata)
 
 	_, err = d.client.PutObject(ctx, input)
 	if err != nil {
@@ -244,17 +302,8 @@ func (d *S3Disk) exists(ctx context.Context, path string) (bool, error) {
 		return false, &PathError{Op: "exists", Path: path, Err: err}
 	}
 
-	key := d.buildKey(validPath)
-
-	_, err = d.client.HeadObject(ctx, &s3.HeadObjectInput{
-		Bucket: aws.String(d.config.Bucket),
-		Key:    aws.String(key),
-	})
-
-	if err != nil {
-		var nsk *types.NoSuchKey
-		var notFound *types.NotFound
-		if errors.As(err, &nsk) || errors.As(err, &notFound) {
+	if _, err := d.head(ctx, d.buildKey(validPath)); err != nil {
+		if errors.Is(err, ErrFileNotFound) {
 			return false, nil
 		}
 		return false, &PathError{Op: "exists", Path: path, Err: err}
@@ -271,23 +320,12 @@ func (d *S3Disk) size(ctx context.Context, path string) (int64, error) {
 		return 0, &PathError{Op: "size", Path: path, Err: err}
 	}
 
-	key := d.buildKey(validPath)
-
-	result, err := d.client.HeadObject(ctx, &s3.HeadObjectInput{
-		Bucket: aws.String(d.config.Bucket),
-		Key:    aws.String(key),
-	})
-
+	metadata, err := d.head(ctx, d.buildKey(validPath))
 	if err != nil {
-		var nsk *types.NoSuchKey
-		var notFound *types.NotFound
-		if errors.As(err, &nsk) || errors.As(err, &notFound) {
-			return 0, &PathError{Op: "size", Path: path, Err: ErrFileNotFound}
-		}
 		return 0, &PathError{Op: "size", Path: path, Err: err}
 	}
 
-	return aws.ToInt64(result.ContentLength), nil
+	return metadata.Size, nil
 }
 
 // list returns a list of objects matching a prefix
@@ -347,11 +385,13 @@ func (d *S3Disk) copy(ctx context.Context, sourcePath, destPath string) error {
 
 	sourceKey := d.buildKey(validSource)
 	destKey := d.buildKey(validDest)
+	defer d.headCache.invalidate(destKey)
 
 	_, err = d.client.CopyObject(ctx, &s3.CopyObjectInput{
 		Bucket:     aws.String(d.config.Bucket),
-		CopySource: aws.String(d.config.Bucket + "/" + sourceKey),
+		CopySource: aws.String(d.copySource(sourceKey)),
 		Key:        aws.String(destKey),
+		ACL:        d.copyACL(ctx, validSource),
 	})
 
 	if err != nil {
@@ -381,6 +421,7 @@ func (d *S3Disk) putWithMetadata(ctx context.Context, path string, content []byt
 	}
 
 	key := d.buildKey(validPath)
+	defer d.headCache.invalidate(key)
 
 	input := &s3.PutObjectInput{
 		Bucket: aws.String(d.config.Bucket),
@@ -388,15 +429,8 @@ func (d *S3Disk) putWithMetadata(ctx context.Context, path string, content []byt
 		Body:   bytes.NewReader(content),
 	}
 
-	// Add metadata
-	if metadata != nil {
-		if metadata.ContentType != "" {
-			input.ContentType = aws.String(metadata.ContentType)
-		}
-		if len(metadata.CustomHeaders) > 0 {
-			input.Metadata = metadata.CustomHeaders
-		}
-	}
+	// Add metadata, filling in the content type when it is not given
+	applyPutMetadata(input, detectMetadata(d.detector, validPath, content, metadata))
 
 	_, err = d.client.PutObject(ctx, input)
 	if err != nil {
@@ -414,7 +448,41 @@ func (d *S3Disk) getMetadata(ctx context.Context, path string) (*Metadata, error
 		return nil, &PathError{Op: "getMetadata", Path: path, Err: err}
 	}
 
-	key := d.buildKey(validPath)
+	metadata, err := d.head(ctx, d.buildKey(validPath))
+	if err != nil {
+		return nil, &PathError{Op: "getMetadata", Path: path, Err: err}
+	}
+
+	return metadata, nil
+}
+
+// stat returns file information and metadata with a single HeadObject request
+func (d *S3Disk) stat(ctx context.Context, path string) (*FileInfo, error) {
+	// Validate path
+	validPath, err := ValidatePath(path)
+	if err != nil {
+		return nil, &PathError{Op: "stat", Path: path, Err: err}
+	}
+
+	metadata, err := d.head(ctx, d.buildKey(validPath))
+	if err != nil {
+		return nil, &PathError{Op: "stat", Path: path, Err: err}
+	}
+
+	return &FileInfo{
+		Path:         filepath.ToSlash(validPath),
+		Size:         metadata.Size,
+		LastModified: metadata.LastModified,
+		Metadata:     metadata,
+	}, nil
+}
+
+// head returns the metadata of an object, from the metadata cache if enabled.
+// A missing object is reported as ErrFileNotFound.
+func (d *S3Disk) head(ctx context.Context, key string) (*Metadata, error) {
+	if metadata, ok := d.headCache.get(key); ok {
+		return metadata, nil
+	}
 
 	result, err := d.client.HeadObject(ctx, &s3.HeadObjectInput{
 		Bucket: aws.String(d.config.Bucket),
@@ -425,9 +493,9 @@ func (d *S3Disk) getMetadata(ctx context.Context, path string) (*Metadata, error
 		var nsk *types.NoSuchKey
 		var notFound *types.NotFound
 		if errors.As(err, &nsk) || errors.As(err, &notFound) {
-			return nil, &PathError{Op: "getMetadata", Path: path, Err: ErrFileNotFound}
+			return nil, ErrFileNotFound
 		}
-		return nil, &PathError{Op: "getMetadata", Path: path, Err: err}
+		return nil, err
 	}
 
 	metadata := &Metadata{
@@ -435,8 +503,26 @@ func (d *S3Disk) getMetadata(ctx context.Context, path string) (*Metadata, error
 		Size:          aws.ToInt64(result.ContentLength),
 		LastModified:  aws.ToTime(result.LastModified),
 		CustomHeaders: result.Metadata,
+		ETag:          strings.Trim(aws.ToString(result.ETag), `"`),
+		StorageClass:  string(result.StorageClass),
+
+		CacheControl:       aws.ToString(result.CacheControl),
+		ContentDisposition: aws.ToString(result.ContentDisposition),
+		ContentEncoding:    aws.ToString(result.ContentEncoding),
+		ContentLanguage:    aws.ToString(result.ContentLanguage),
+	}
+
+	// Invalid Expires values are allowed by HTTP and mean "already expired", they are dropped here
+	if expires, err := http.ParseTime(aws.ToString(result.ExpiresString)); err == nil {
+		metadata.Expires = expires
+	}
+
+	// S3 omits the storage class header for the default class
+	if metadata.StorageClass == "" {
+		metadata.StorageClass = string(types.StorageClassStandard)
 	}
 
+	d.headCache.set(key, metadata)
 	return metadata, nil
 }
 
@@ -449,23 +535,18 @@ func (d *S3Disk) setMetadata(ctx context.Context, path string, metadata *Metadat
 	}
 
 	key := d.buildKey(validPath)
+	defer d.headCache.invalidate(key)
 
 	// S3 requires copying the object to update metadata
 	input := &s3.CopyObjectInput{
 		Bucket:            aws.String(d.config.Bucket),
-		CopySource:        aws.String(d.config.Bucket + "/" + key),
+		CopySource:        aws.String(d.copySource(key)),
 		Key:               aws.String(key),
 		MetadataDirective: types.MetadataDirectiveReplace,
+		ACL:               d.copyACL(ctx, validPath),
 	}
 
-	if metadata != nil {
-		if metadata.ContentType != "" {
-			input.ContentType = aws.String(metadata.ContentType)
-		}
-		if len(metadata.CustomHeaders) > 0 {
-			input.Metadata = metadata.CustomHeaders
-		}
-	}
+	applyCopyMetadata(input, metadata)
 
 	_, err = d.client.CopyObject(ctx, input)
 	if err != nil {
@@ -474,3 +555,280 @@ func (d *S3Disk) setMetadata(ctx context.Context, path string, metadata *Metadat
 
 	return nil
 }
+
+// watch polls the bucket for changes, S3 has no push notifications that reach the client
+func (d *S3Disk) watch(ctx context.Context, prefix string) (<-chan ChangeEvent, error) {
+	interval := d.config.WatchInterval
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+
+	return pollChanges(ctx, d, prefix, interval, d.logger)
+}
+
+// ping checks that the bucket exists and is reachable with the configured credentials
+func (d *S3Disk) ping(ctx context.Context) error {
+	_, err := d.client.HeadBucket(ctx, &s3.HeadBucketInput{
+		Bucket: aws.String(d.config.Bucket),
+	})
+	if err != nil {
+		return &PathError{Op: "ping", Path: d.config.Bucket, Err: err}
+	}
+
+	return nil
+}
+
+// applyPutMetadata sets the content type, standard HTTP headers and custom headers of an upload
+func applyPutMetadata(input *s3.PutObjectInput, metadata *Metadata) {
+	if metadata == nil {
+		return
+	}
+
+	input.ContentType = optionalString(metadata.ContentType)
+	input.CacheControl = optionalString(metadata.CacheControl)
+	input.ContentDisposition = optionalString(metadata.ContentDisposition)
+	input.ContentEncoding = optionalString(metadata.ContentEncoding)
+	input.ContentLanguage = optionalString(metadata.ContentLanguage)
+	if !metadata.Expires.IsZero() {
+		input.Expires = aws.Time(metadata.Expires)
+	}
+	if metadata.Visibility != "" {
+		input.ACL = cannedACL(metadata.Visibility)
+	}
+	if len(metadata.CustomHeaders) > 0 {
+		input.Metadata = metadata.CustomHeaders
+	}
+}
+
+// applyCopyMetadata sets the content type, standard HTTP headers and custom headers of a copy
+func applyCopyMetadata(input *s3.CopyObjectInput, metadata *Metadata) {
+	if metadata == nil {
+		return
+	}
+
+	input.ContentType = optionalString(metadata.ContentType)
+	input.CacheControl = optionalString(metadata.CacheControl)
+	input.ContentDisposition = optionalString(metadata.ContentDisposition)
+	input.ContentEncoding = optionalString(metadata.ContentEncoding)
+	input.ContentLanguage = optionalString(metadata.ContentLanguage)
+	if !metadata.Expires.IsZero() {
+		input.Expires = aws.Time(metadata.Expires)
+	}
+	if metadata.Visibility != "" {
+		input.ACL = cannedACL(metadata.Visibility)
+	}
+	if len(metadata.CustomHeaders) > 0 {
+		input.Metadata = metadata.CustomHeaders
+	}
+}
+
+// optionalString returns nil for an empty string, so that the header is not sent
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return aws.String(s)
+}
+
+// allUsersURI is the grantee S3 uses for anonymous access
+const allUsersURI = "http://acs.amazonaws.com/groups/global/AllUsers"
+
+// cannedACL returns the canned ACL of a visibility
+func cannedACL(visibility Visibility) types.ObjectCannedACL {
+	if visibility == VisibilityPublic {
+		return types.ObjectCannedACLPublicRead
+	}
+	return types.ObjectCannedACLPrivate
+}
+
+// copyACL returns the canned ACL that keeps a copy of a public object public, S3 does not copy ACLs.
+// Private objects get none, so copies keep working on buckets with ACLs disabled.
+func (d *S3Disk) copyACL(ctx context.Context, validSource string) types.ObjectCannedACL {
+	visibility, err := d.getVisibility(ctx, validSource)
+	if err != nil {
+		d.logger.WarnContext(ctx, "failed to read visibility of copy source",
+			slog.String("path", validSource),
+			slog.Any("error", err),
+		)
+		return ""
+	}
+
+	if visibility == VisibilityPublic {
+		return types.ObjectCannedACLPublicRead
+	}
+	return ""
+}
+
+// setVisibility applies the public-read or private canned ACL to an object.
+// Buckets with ACLs disabled reject this, grant public access with a bucket policy instead.
+func (d *S3Disk) setVisibility(ctx context.Context, path string, visibility Visibility) error {
+	// Validate path
+	validPath, err := ValidatePath(path)
+	if err != nil {
+		return &PathError{Op: "setVisibility", Path: path, Err: err}
+	}
+
+	_, err = d.client.PutObjectAcl(ctx, &s3.PutObjectAclInput{
+		Bucket: aws.String(d.config.Bucket),
+		Key:    aws.String(d.buildKey(validPath)),
+		ACL:    cannedACL(visibility),
+	})
+	if err != nil {
+		return &PathError{Op: "setVisibility", Path: path, Err: notFoundError(err)}
+	}
+
+	return nil
+}
+
+// getVisibility reports an object as public when its ACL lets anyone read it
+func (d *S3Disk) getVisibility(ctx context.Context, path string) (Visibility, error) {
+	// Validate path
+	validPath, err := ValidatePath(path)
+	if err != nil {
+		return "", &PathError{Op: "getVisibility", Path: path, Err: err}
+	}
+
+	result, err := d.client.GetObjectAcl(ctx, &s3.GetObjectAclInput{
+		Bucket: aws.String(d.config.Bucket),
+		Key:    aws.String(d.buildKey(validPath)),
+	})
+	if err != nil {
+		return "", &PathError{Op: "getVisibility", Path: path, Err: notFoundError(err)}
+	}
+
+	for _, grant := range result.Grants {
+		if grant.Grantee == nil || aws.ToString(grant.Grantee.URI) != allUsersURI {
+			continue
+		}
+		if grant.Permission == types.PermissionRead || grant.Permission == types.PermissionFullControl {
+			return VisibilityPublic, nil
+		}
+	}
+
+	return VisibilityPrivate, nil
+}
+
+// url returns the URL of an object below PublicBaseURL or the bucket URL
+func (d *S3Disk) url(_ context.Context, path string) (string, error) {
+	// Validate path
+	validPath, err := ValidatePath(path)
+	if err != nil {
+		return "", &PathError{Op: "url", Path: path, Err: err}
+	}
+
+	u, err := publicURL(d.baseURL, d.buildKey(validPath))
+	if err != nil {
+		return "", &PathError{Op: "url", Path: path, Err: err}
+	}
+
+	return u, nil
+}
+
+// notFoundError maps the NoSuchKey error of operations without a modeled not found error to ErrFileNotFound
+func notFoundError(err error) error {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && apiErr.ErrorCode() == "NoSuchKey" {
+		return ErrFileNotFound
+	}
+	return err
+}
+
+// bucketURL returns the URL of the bucket, e.g. "https://my-bucket.s3.eu-west-1.amazonaws.com"
+// or "http://localhost:9000/my-bucket" for a path-style MinIO endpoint.
+// It returns "" when the endpoint is not an absolute URL.
+func bucketURL(cfg *S3Config) string {
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = "https://s3." + cfg.Region + ".amazonaws.com"
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return ""
+	}
+
+	base := strings.TrimSuffix(u.Path, "/")
+	if cfg.UsePathStyle {
+		return u.Scheme + "://" + u.Host + base + "/" + url.PathEscape(cfg.Bucket)
+	}
+
+	return u.Scheme + "://" + cfg.Bucket + "." + u.Host + base
+}
+
+// maxDeleteObjects is the maximum number of keys in a DeleteObjects request
+const maxDeleteObjects = 1000
+
+// copySource returns the URL-encoded source of a CopyObject request, keys with spaces fail unencoded
+func (d *S3Disk) copySource(key string) string {
+	return url.PathEscape(d.config.Bucket) + "/" + escapeKey(key)
+}
+
+// deleteMany deletes objects with DeleteObjects, up to 1000 keys per request and
+// concurrency requests at once
+func (d *S3Disk) deleteMany(ctx context.Context, paths []string, concurrency int) []error {
+	errs := make([]error, len(paths))
+
+	// Map keys back to their paths, the response only names the keys that failed
+	indexes := make(map[string][]int, len(paths))
+	var objects []types.ObjectIdentifier
+	for i, path := range paths {
+		validPath, err := ValidatePath(path)
+		if err != nil {
+			errs[i] = &PathError{Op: "delete", Path: path, Err: err}
+			continue
+		}
+
+		key := d.buildKey(validPath)
+		if _, ok := indexes[key]; !ok {
+			objects = append(objects, types.ObjectIdentifier{Key: aws.String(key)})
+		}
+		indexes[key] = append(indexes[key], i)
+	}
+
+	batches := (len(objects) + maxDeleteObjects - 1) / maxDeleteObjects
+	batchErrs := runConcurrently(ctx, batches, concurrency, func(b int) error {
+		batch := objects[b*maxDeleteObjects : min((b+1)*maxDeleteObjects, len(objects))]
+
+		result, err := d.client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(d.config.Bucket),
+			Delete: &types.Delete{
+				Objects: batch,
+				Quiet:   aws.Bool(true),
+			},
+		})
+
+		for _, object := range batch {
+			d.headCache.invalidate(aws.ToString(object.Key))
+		}
+		if err != nil {
+			return err
+		}
+
+		// Every key belongs to a single batch, so batches never write the same index
+		for _, failed := range result.Errors {
+			objectErr := fmt.Errorf("%s: %s", aws.ToString(failed.Code), aws.ToString(failed.Message))
+			for _, i := range indexes[aws.ToString(failed.Key)] {
+				errs[i] = &PathError{Op: "delete", Path: paths[i], Err: objectErr}
+			}
+		}
+		return nil
+	})
+
+	for b, err := range batchErrs {
+		if err == nil {
+			continue
+		}
+		for _, object := range objects[b*maxDeleteObjects : min((b+1)*maxDeleteObjects, len(objects))] {
+			for _, i := range indexes[aws.ToString(object.Key)] {
+				errs[i] = &PathError{Op: "delete", Path: paths[i], Err: err}
+			}
+		}
+	}
+
+	return errs
+}
+
+// typeDetector returns the detector filling in content types, nil when detection is disabled
+func (d *S3Disk) typeDetector() *ContentTypeDetector {
+	return d.detector
+}
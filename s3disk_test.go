@@ -2,8 +2,17 @@ package gostorage
 
 import (
 	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
 )
 
 // TestS3Disk tests require environment variables to be set:
@@ -29,7 +38,7 @@ func getS3TestConfig(t *testing.T) *S3Config {
 		AccessKey:    accessKey,
 		SecretKey:    secretKey,
 		Bucket:       bucket,
-		Prefix:       "test/",      // Use a test prefix
+		Prefix:       "test/",        // Use a test prefix
 		UsePathStyle: endpoint != "", // Use path style if endpoint is set (for MinIO)
 	}
 }
@@ -125,3 +134,171 @@ func TestS3Disk_ConfigValidation(t *testing.T) {
 		t.Error("Should reject empty bucket")
 	}
 }
+
+func TestS3Disk_MetadataCache(t *testing.T) {
+	cfg := getS3TestConfig(t)
+	cfg.MetadataCacheTTL = time.Minute
+
+	disk, err := NewS3Disk(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create S3Disk: %v", err)
+	}
+
+	ctx := context.Background()
+
+	if err := disk.put(ctx, "test-cache.txt", []byte("one")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	defer disk.delete(ctx, "test-cache.txt")
+
+	info, err := disk.stat(ctx, "test-cache.txt")
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if info.Size != 3 || info.Metadata == nil {
+		t.Errorf("Unexpected stat result: %+v", info)
+	}
+
+	// Our own writes invalidate the cached metadata
+	if err := disk.put(ctx, "test-cache.txt", []byte("three")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	size, err := disk.size(ctx, "test-cache.txt")
+	if err != nil || size != 5 {
+		t.Errorf("Expected size 5 after overwrite, got %d, %v", size, err)
+	}
+}
+
+func TestApplyPutMetadata(t *testing.T) {
+	expires := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	input := &s3.PutObjectInput{}
+
+	applyPutMetadata(input, &Metadata{
+		ContentType:        "text/html",
+		CacheControl:       "no-cache",
+		ContentDisposition: "inline",
+		Expires:            expires,
+		CustomHeaders:      map[string]string{"author": "me"},
+	})
+
+	if aws.ToString(input.ContentType) != "text/html" || aws.ToString(input.CacheControl) != "no-cache" ||
+		aws.ToString(input.ContentDisposition) != "inline" || !aws.ToTime(input.Expires).Equal(expires) {
+		t.Errorf("Headers not applied: %+v", input)
+	}
+
+	// Empty fields are not sent
+	if input.ContentEncoding != nil || input.ContentLanguage != nil {
+		t.Error("Empty headers should not be set")
+	}
+	if input.Metadata["author"] != "me" {
+		t.Error("Custom headers not applied")
+	}
+}
+
+func TestS3Disk_URL(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  S3Config
+		want string
+	}{
+		{
+			name: "virtual-hosted AWS",
+			cfg:  S3Config{Bucket: "assets", Region: "eu-west-1"},
+			want: "https://assets.s3.eu-west-1.amazonaws.com/docs/Q1%20report%2B.pdf",
+		},
+		{
+			name: "path-style MinIO",
+			cfg:  S3Config{Bucket: "assets", Endpoint: "http://localhost:9000", UsePathStyle: true},
+			want: "http://localhost:9000/assets/docs/Q1%20report%2B.pdf",
+		},
+		{
+			name: "virtual-hosted custom endpoint",
+			cfg:  S3Config{Bucket: "assets", Endpoint: "https://storage.example.com"},
+			want: "https://assets.storage.example.com/docs/Q1%20report%2B.pdf",
+		},
+		{
+			name: "CDN with prefix",
+			cfg:  S3Config{Bucket: "assets", Prefix: "tenant-1", PublicBaseURL: "https://cdn.example.com/"},
+			want: "https://cdn.example.com/tenant-1/docs/Q1%20report%2B.pdf",
+		},
+	}
+
+	ctx := context.Background()
+	for _, tt := range tests {
+		disk, err := NewS3Disk(&tt.cfg)
+		if err != nil {
+			t.Fatalf("%s: Failed to create S3Disk: %v", tt.name, err)
+		}
+
+		got, err := disk.url(ctx, "docs/Q1 report+.pdf")
+		if err != nil {
+			t.Fatalf("%s: URL failed: %v", tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.want, got)
+		}
+	}
+
+	if _, err := NewS3Disk(&S3Config{Bucket: "assets", PublicBaseURL: "cdn.example.com"}); err == nil {
+		t.Error("expected a relative PublicBaseURL to be rejected")
+	}
+}
+
+func TestS3Disk_CopyKeepsPublicACL(t *testing.T) {
+	var mu sync.Mutex
+	copyACLs := make(map[string]string)
+
+	// A fake S3 endpoint answering ACL reads and recording the ACL sent with each copy
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Query().Has("acl"):
+			grants := ""
+			if strings.HasSuffix(r.URL.Path, "/public.txt") {
+				grants = `<Grant><Grantee xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:type="Group">` +
+					`<URI>` + allUsersURI + `</URI></Grantee><Permission>READ</Permission></Grant>`
+			}
+			fmt.Fprintf(w, `<AccessControlPolicy><Owner><ID>owner</ID></Owner><AccessControlList>%s</AccessControlList></AccessControlPolicy>`, grants)
+		case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") != "":
+			mu.Lock()
+			copyACLs[r.URL.Path] = r.Header.Get("X-Amz-Acl")
+			mu.Unlock()
+			fmt.Fprint(w, `<CopyObjectResult><ETag>"etag"</ETag></CopyObjectResult>`)
+		default:
+			http.Error(w, "unexpected request", http.StatusNotImplemented)
+		}
+	}))
+	defer server.Close()
+
+	disk, err := NewS3Disk(&S3Config{
+		Endpoint:     server.URL,
+		AccessKey:    "key",
+		SecretKey:    "secret",
+		Bucket:       "bucket",
+		UsePathStyle: true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create S3Disk: %v", err)
+	}
+
+	ctx := context.Background()
+	if err := disk.copy(ctx, "public.txt", "public-copy.txt"); err != nil {
+		t.Fatalf("copy failed: %v", err)
+	}
+	if err := disk.copy(ctx, "private.txt", "private-copy.txt"); err != nil {
+		t.Fatalf("copy failed: %v", err)
+	}
+	if err := disk.setMetadata(ctx, "public.txt", &Metadata{ContentType: "text/plain"}); err != nil {
+		t.Fatalf("setMetadata failed: %v", err)
+	}
+
+	want := map[string]string{
+		"/bucket/public-copy.txt":  "public-read",
+		"/bucket/private-copy.txt": "",
+		"/bucket/public.txt":       "public-read",
+	}
+	for path, acl := range want {
+		if got, ok := copyACLs[path]; !ok || got != acl {
+			t.Errorf("Expected copy to %s with ACL %q, got %q (sent: %v)", path, acl, got, ok)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package gostorage
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// listMetadataConcurrency bounds the concurrent metadata requests made by WithMetadata
+const listMetadataConcurrency = 8
+
+// stater is implemented by disks that can return file information and metadata in a single request
+type stater interface {
+	stat(ctx context.Context, path string) (*FileInfo, error)
+}
+
+// Stat returns the size, modification time and metadata of a file, including the ETag and
+// storage class where the disk provides them. Local directories are reported with IsDir set.
+// Disks that support it answer with a single request, e.g. one HeadObject on S3.
+func (s *Storage) Stat(ctx context.Context, disk string, path string) (*FileInfo, error) {
+	d := s.getDisk(disk)
+	if d == nil {
+		return nil, ErrDiskNotFound(disk)
+	}
+
+	start := time.Now()
+	info, err := statDisk(ctx, d, path)
+	s.logOperation(ctx, "stat", disk, path, start, err)
+	return info, err
+}
+
+// statDisk uses the disk's stat if available and falls back to size and getMetadata
+func statDisk(ctx context.Context, d Disk, path string) (*FileInfo, error) {
+	if st, ok := d.(stater); ok {
+		return st.stat(ctx, path)
+	}
+
+	// Validate path
+	validPath, err := ValidatePath(path)
+	if err != nil {
+		return nil, &PathError{Op: "stat", Path: path, Err: err}
+	}
+
+	size, err := d.size(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata, err := d.getMetadata(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	if metadata == nil {
+		metadata = &Metadata{}
+	}
+	metadata.Size = size
+
+	return &FileInfo{
+		Path:         filepath.ToSlash(validPath),
+		Size:         size,
+		LastModified: metadata.LastModified,
+		Metadata:     metadata,
+	}, nil
+}
+
+// fillMetadata sets the Metadata of every listed file, directories are left alone
+func fillMetadata(ctx context.Context, d Disk, files []FileInfo) error {
+	sem := make(chan struct{}, listMetadataConcurrency)
+	errs := make([]error, len(files))
+
+	var wg sync.WaitGroup
+	for i := range files {
+		if files[i].IsDir {
+			continue
+		}
+
+		sem <- struct{}{}
+		wg.Go(func() {
+			defer func() { <-sem }()
+
+			info, err := statDisk(ctx, d, files[i].Path)
+			switch {
+			case errors.Is(err, ErrFileNotFound):
+				// Deleted since it was listed, keep what the listing reported
+				files[i].Metadata = &Metadata{Size: files[i].Size, LastModified: files[i].LastModified}
+			case err != nil:
+				errs[i] = err
+			default:
+				files[i].Metadata = info.Metadata
+			}
+		})
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// logicalSizes replaces the listed sizes with the sizes reported by size, for disks that store
+// content in another form than callers read it. Files deleted since they were listed are left out.
+func logicalSizes(ctx context.Context, files []FileInfo, size func(ctx context.Context, path string) (int64, error)) ([]FileInfo, error) {
+	errs := runConcurrently(ctx, len(files), listMetadataConcurrency, func(i int) error {
+		if files[i].IsDir {
+			return nil
+		}
+
+		logical, err := size(ctx, files[i].Path)
+		if err != nil {
+			return err
+		}
+		files[i].Size = logical
+		return nil
+	})
+
+	converted := make([]FileInfo, 0, len(files))
+	for i, err := range errs {
+		switch {
+		case errors.Is(err, ErrFileNotFound):
+			// Deleted since it was listed
+		case err != nil:
+			return nil, err
+		default:
+			converted = append(converted, files[i])
+		}
+	}
+
+	return converted, nil
+}
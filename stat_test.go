@@ -0,0 +1,99 @@
+package gostorage
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestStorage_Stat(t *testing.T) {
+	ctx := context.Background()
+
+	storage := NewStorage()
+	storage.AddDisk("memory", NewMemoryDisk())
+
+	metadata := &Metadata{ContentType: "text/plain", CustomHeaders: map[string]string{"author": "me"}}
+	if err := storage.PutWithMetadata(ctx, "memory", "a.txt", []byte("hello"), metadata); err != nil {
+		t.Fatalf("PutWithMetadata failed: %v", err)
+	}
+
+	info, err := storage.Stat(ctx, "memory", "a.txt")
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if info.Size != 5 || info.LastModified.IsZero() {
+		t.Errorf("Unexpected file info: %+v", info)
+	}
+	if info.Metadata == nil || info.Metadata.ContentType != "text/plain" || info.Metadata.CustomHeaders["author"] != "me" {
+		t.Errorf("Unexpected metadata: %+v", info.Metadata)
+	}
+
+	if _, err := storage.Stat(ctx, "memory", "missing.txt"); !errors.Is(err, ErrFileNotFound) {
+		t.Errorf("Expected ErrFileNotFound, got %v", err)
+	}
+}
+
+func TestStorage_StatLocal(t *testing.T) {
+	ctx := context.Background()
+
+	local, err := NewLocalDisk(&LocalDiskConfig{Path: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create LocalDisk: %v", err)
+	}
+
+	storage := NewStorage()
+	storage.AddDisk("local", local)
+
+	if err := storage.PutWithMetadata(ctx, "local", "docs/a.txt", []byte("hello"), &Metadata{ContentType: "text/plain"}); err != nil {
+		t.Fatalf("PutWithMetadata failed: %v", err)
+	}
+
+	info, err := storage.Stat(ctx, "local", "docs/a.txt")
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if info.IsDir || info.Size != 5 || info.Metadata.ContentType != "text/plain" || info.Metadata.LastModified.IsZero() {
+		t.Errorf("Unexpected file info: %+v, metadata %+v", info, info.Metadata)
+	}
+
+	dir, err := storage.Stat(ctx, "local", "docs")
+	if err != nil || !dir.IsDir {
+		t.Errorf("Expected directory, got %+v, %v", dir, err)
+	}
+}
+
+func TestStorage_ListWithMetadata(t *testing.T) {
+	ctx := context.Background()
+
+	storage := NewStorage()
+	storage.AddDisk("memory", NewMemoryDisk())
+
+	for _, name := range []string{"a.txt", "b.txt"} {
+		if err := storage.PutWithMetadata(ctx, "memory", "docs/"+name, []byte(name), &Metadata{ContentType: "text/plain"}); err != nil {
+			t.Fatalf("PutWithMetadata failed: %v", err)
+		}
+	}
+
+	files, err := storage.List(ctx, "memory", "docs/")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	for _, f := range files {
+		if f.Metadata != nil {
+			t.Errorf("Expected no metadata without WithMetadata for %s", f.Path)
+		}
+	}
+
+	files, err = storage.List(ctx, "memory", "docs/", WithMetadata())
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("Expected 2 files, got %d", len(files))
+	}
+	for _, f := range files {
+		if f.Metadata == nil || f.Metadata.ContentType != "text/plain" || f.Metadata.ETag == "" {
+			t.Errorf("Expected metadata for %s, got %+v", f.Path, f.Metadata)
+		}
+	}
+}
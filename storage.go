@@ -3,10 +3,23 @@ package gostorage
 import (
 	"context"
 	"io"
+	"log/slog"
+	"sync"
+	"time"
 )
 
 type Storage struct {
 	Disks map[string]Disk
+
+	// Logger receives operation, slow call and error logs (default: discard)
+	Logger *slog.Logger
+
+	// SlowThreshold is the duration after which an operation is logged as slow
+	// (default: DefaultSlowThreshold, negative disables slow call logging)
+	SlowThreshold time.Duration
+
+	handlers   map[EventType][]EventHandler
+	handlersMu sync.RWMutex
 }
 
 func NewStorage() *Storage {
@@ -27,7 +40,15 @@ func (s *Storage) Put(ctx context.Context, disk string, path string, content []b
 		return ErrDiskNotFound(disk)
 	}
 
-	return d.put(ctx, path, content)
+	start := time.Now()
+	err := d.put(ctx, path, content)
+	s.logOperation(ctx, "put", disk, path, start, err)
+	if err != nil {
+		return err
+	}
+
+	s.emit(ctx, Event{Type: EventPut, Disk: disk, Path: path, Size: int64(len(content)), Operation: "Put"})
+	return nil
 }
 
 func (s *Storage) Get(ctx context.Context, disk string, path string) ([]byte, error) {
@@ -36,7 +57,10 @@ func (s *Storage) Get(ctx context.Context, disk string, path string) ([]byte, er
 		return nil, ErrDiskNotFound(disk)
 	}
 
-	return d.get(ctx, path)
+	start := time.Now()
+	content, err := d.get(ctx, path)
+	s.logOperation(ctx, "get", disk, path, start, err)
+	return content, err
 }
 
 func (s *Storage) Delete(ctx context.Context, disk string, path string) error {
@@ -45,7 +69,15 @@ func (s *Storage) Delete(ctx context.Context, disk string, path string) error {
 		return ErrDiskNotFound(disk)
 	}
 
-	return d.delete(ctx, path)
+	start := time.Now()
+	err := d.delete(ctx, path)
+	s.logOperation(ctx, "delete", disk, path, start, err)
+	if err != nil {
+		return err
+	}
+
+	s.emit(ctx, Event{Type: EventDelete, Disk: disk, Path: path, Size: -1, Operation: "Delete"})
+	return nil
 }
 
 // Streaming operations
@@ -56,7 +88,17 @@ func (s *Storage) PutStream(ctx context.Context, disk string, path string, reade
 		return ErrDiskNotFound(disk)
 	}
 
-	return d.putStream(ctx, path, reader, metadata)
+	counter := &countingReader{reader: reader}
+
+	start := time.Now()
+	err := d.putStream(ctx, path, counter, metadata)
+	s.logOperation(ctx, "putStream", disk, path, start, err)
+	if err != nil {
+		return err
+	}
+
+	s.emit(ctx, Event{Type: EventPut, Disk: disk, Path: path, Size: counter.n, Metadata: metadata, Operation: "PutStream"})
+	return nil
 }
 
 func (s *Storage) GetStream(ctx context.Context, disk string, path string) (io.ReadCloser, error) {
@@ -65,7 +107,10 @@ func (s *Storage) GetStream(ctx context.Context, disk string, path string) (io.R
 		return nil, ErrDiskNotFound(disk)
 	}
 
-	return d.getStream(ctx, path)
+	start := time.Now()
+	reader, err := d.getStream(ctx, path)
+	s.logOperation(ctx, "getStream", disk, path, start, err)
+	return reader, err
 }
 
 // File operations
@@ -76,7 +121,10 @@ func (s *Storage) Exists(ctx context.Context, disk string, path string) (bool, e
 		return false, ErrDiskNotFound(disk)
 	}
 
-	return d.exists(ctx, path)
+	start := time.Now()
+	exists, err := d.exists(ctx, path)
+	s.logOperation(ctx, "exists", disk, path, start, err)
+	return exists, err
 }
 
 func (s *Storage) Size(ctx context.Context, disk string, path string) (int64, error) {
@@ -85,16 +133,50 @@ func (s *Storage) Size(ctx context.Context, disk string, path string) (int64, er
 		return 0, ErrDiskNotFound(disk)
 	}
 
-	return d.size(ctx, path)
+	start := time.Now()
+	size, err := d.size(ctx, path)
+	s.logOperation(ctx, "size", disk, path, start, err)
+	return size, err
+}
+
+// ListOption configures a listing
+type ListOption func(*listOptions)
+
+// listOptions holds the options of a listing
+type listOptions struct {
+	metadata bool
 }
 
-func (s *Storage) List(ctx context.Context, disk string, prefix string) ([]FileInfo, error) {
+// WithMetadata fills FileInfo.Metadata of every listed file.
+// This may cost one extra request per file, e.g. a HeadObject on S3.
+func WithMetadata() ListOption {
+	return func(o *listOptions) {
+		o.metadata = true
+	}
+}
+
+func (s *Storage) List(ctx context.Context, disk string, prefix string, opts ...ListOption) ([]FileInfo, error) {
 	d := s.getDisk(disk)
 	if d == nil {
 		return nil, ErrDiskNotFound(disk)
 	}
 
-	return d.list(ctx, prefix)
+	var options listOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	start := time.Now()
+	files, err := d.list(ctx, prefix)
+	if err == nil && options.metadata {
+		err = fillMetadata(ctx, d, files)
+	}
+	s.logOperation(ctx, "list", disk, prefix, start, err)
+	if err != nil {
+		return nil, err
+	}
+
+	return files, nil
 }
 
 func (s *Storage) Copy(ctx context.Context, disk string, sourcePath, destPath string) error {
@@ -103,7 +185,23 @@ func (s *Storage) Copy(ctx context.Context, disk string, sourcePath, destPath st
 		return ErrDiskNotFound(disk)
 	}
 
-	return d.copy(ctx, sourcePath, destPath)
+	start := time.Now()
+	err := d.copy(ctx, sourcePath, destPath)
+	s.logOperation(ctx, "copy", disk, sourcePath, start, err)
+	if err != nil {
+		return err
+	}
+
+	s.emit(ctx, Event{
+		Type:       EventCopy,
+		Disk:       disk,
+		Path:       destPath,
+		SourceDisk: disk,
+		SourcePath: sourcePath,
+		Size:       -1,
+		Operation:  "Copy",
+	})
+	return nil
 }
 
 func (s *Storage) Move(ctx context.Context, disk string, sourcePath, destPath string) error {
@@ -112,7 +210,23 @@ func (s *Storage) Move(ctx context.Context, disk string, sourcePath, destPath st
 		return ErrDiskNotFound(disk)
 	}
 
-	return d.move(ctx, sourcePath, destPath)
+	start := time.Now()
+	err := d.move(ctx, sourcePath, destPath)
+	s.logOperation(ctx, "move", disk, sourcePath, start, err)
+	if err != nil {
+		return err
+	}
+
+	s.emit(ctx, Event{
+		Type:       EventMove,
+		Disk:       disk,
+		Path:       destPath,
+		SourceDisk: disk,
+		SourcePath: sourcePath,
+		Size:       -1,
+		Operation:  "Move",
+	})
+	return nil
 }
 
 // Cross-disk operations
@@ -128,30 +242,102 @@ func (s *Storage) CopyBetweenDisks(ctx context.Context, sourceDisk, destDisk, so
 		return ErrDiskNotFound(destDisk)
 	}
 
+	start := time.Now()
+	size, metadata, err := s.copyBetweenDisks(ctx, src, dst, sourceDisk, sourcePath, destPath)
+	s.logOperation(ctx, "copyBetweenDisks", sourceDisk+"->"+destDisk, sourcePath, start, err)
+	if err != nil {
+		return err
+	}
+
+	s.emit(ctx, Event{
+		Type:       EventCopy,
+		Disk:       destDisk,
+		Path:       destPath,
+		SourceDisk: sourceDisk,
+		SourcePath: sourcePath,
+		Size:       size,
+		Metadata:   metadata,
+		Operation:  "CopyBetweenDisks",
+	})
+	return nil
+}
+
+// copyBetweenDisks copies a file and returns the number of bytes and the metadata that were copied
+func (s *Storage) copyBetweenDisks(ctx context.Context, src, dst Disk, sourceDisk, sourcePath, destPath string) (int64, *Metadata, error) {
 	// Read from source
 	content, err := src.get(ctx, sourcePath)
 	if err != nil {
-		return err
+		return 0, nil, err
 	}
 
-	// Get metadata if available
-	metadata, _ := src.getMetadata(ctx, sourcePath)
+	// Get metadata if available, a failure here only loses the metadata
+	metadata, err := src.getMetadata(ctx, sourcePath)
+	if err != nil {
+		loggerOrDiscard(s.Logger).WarnContext(ctx, "copying without metadata",
+			slog.String("disk", sourceDisk),
+			slog.String("path", sourcePath),
+			slog.Any("error", err),
+		)
+	}
 
 	// Write to destination
 	if metadata != nil {
-		return dst.putWithMetadata(ctx, destPath, content, metadata)
+		err = dst.putWithMetadata(ctx, destPath, content, metadata)
+	} else {
+		err = dst.put(ctx, destPath, content)
 	}
-	return dst.put(ctx, destPath, content)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	// Keep the visibility, not every disk reports it in the metadata
+	if err := copyVisibility(ctx, src, sourcePath, dst, destPath); err != nil {
+		loggerOrDiscard(s.Logger).WarnContext(ctx, "copying without visibility",
+			slog.String("disk", sourceDisk),
+			slog.String("path", sourcePath),
+			slog.Any("error", err),
+		)
+	}
+
+	return int64(len(content)), metadata, nil
 }
 
 func (s *Storage) MoveBetweenDisks(ctx context.Context, sourceDisk, destDisk, sourcePath, destPath string) error {
+	src := s.getDisk(sourceDisk)
+	if src == nil {
+		return ErrDiskNotFound(sourceDisk)
+	}
+
+	dst := s.getDisk(destDisk)
+	if dst == nil {
+		return ErrDiskNotFound(destDisk)
+	}
+
+	start := time.Now()
+
 	// Copy between disks
-	if err := s.CopyBetweenDisks(ctx, sourceDisk, destDisk, sourcePath, destPath); err != nil {
+	size, metadata, err := s.copyBetweenDisks(ctx, src, dst, sourceDisk, sourcePath, destPath)
+	if err == nil {
+		// Delete from source
+		err = src.delete(ctx, sourcePath)
+	}
+
+	s.logOperation(ctx, "moveBetweenDisks", sourceDisk+"->"+destDisk, sourcePath, start, err)
+	if err != nil {
 		return err
 	}
 
-	// Delete from source
-	return s.Delete(ctx, sourceDisk, sourcePath)
+	s.emit(ctx, Event{
+		Type:       EventMove,
+		Disk:       destDisk,
+		Path:       destPath,
+		SourceDisk: sourceDisk,
+		SourcePath: sourcePath,
+		Size:       size,
+		Metadata:   metadata,
+		Operation:  "MoveBetweenDisks",
+	})
+	return nil
 }
 
 // Metadata operations
@@ -162,7 +348,15 @@ func (s *Storage) PutWithMetadata(ctx context.Context, disk string, path string,
 		return ErrDiskNotFound(disk)
 	}
 
-	return d.putWithMetadata(ctx, path, content, metadata)
+	start := time.Now()
+	err := d.putWithMetadata(ctx, path, content, metadata)
+	s.logOperation(ctx, "putWithMetadata", disk, path, start, err)
+	if err != nil {
+		return err
+	}
+
+	s.emit(ctx, Event{Type: EventPut, Disk: disk, Path: path, Size: int64(len(content)), Metadata: metadata, Operation: "PutWithMetadata"})
+	return nil
 }
 
 func (s *Storage) GetMetadata(ctx context.Context, disk string, path string) (*Metadata, error) {
@@ -171,7 +365,10 @@ func (s *Storage) GetMetadata(ctx context.Context, disk string, path string) (*M
 		return nil, ErrDiskNotFound(disk)
 	}
 
-	return d.getMetadata(ctx, path)
+	start := time.Now()
+	metadata, err := d.getMetadata(ctx, path)
+	s.logOperation(ctx, "getMetadata", disk, path, start, err)
+	return metadata, err
 }
 
 func (s *Storage) SetMetadata(ctx context.Context, disk string, path string, metadata *Metadata) error {
@@ -180,7 +377,23 @@ func (s *Storage) SetMetadata(ctx context.Context, disk string, path string, met
 		return ErrDiskNotFound(disk)
 	}
 
-	return d.setMetadata(ctx, path, metadata)
+	start := time.Now()
+	err := d.setMetadata(ctx, path, metadata)
+	s.logOperation(ctx, "setMetadata", disk, path, start, err)
+	return err
+}
+
+// Ping checks that a disk is reachable, e.g. that an S3 bucket exists and the credentials work
+func (s *Storage) Ping(ctx context.Context, disk string) error {
+	d := s.getDisk(disk)
+	if d == nil {
+		return ErrDiskNotFound(disk)
+	}
+
+	start := time.Now()
+	err := d.ping(ctx)
+	s.logOperation(ctx, "ping", disk, "", start, err)
+	return err
 }
 
 // Helper methods
@@ -0,0 +1,324 @@
+package gostorage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+	"time"
+)
+
+// CompareMode decides when Sync considers a destination file changed
+type CompareMode string
+
+const (
+	// CompareModTime copies files whose size differs or whose source is newer than the destination
+	CompareModTime CompareMode = "modtime"
+
+	// CompareSize copies files whose size differs
+	CompareSize CompareMode = "size"
+
+	// CompareChecksum copies files whose size or SHA-256 differs.
+	// Files of equal size are read from both disks to compute the checksum.
+	CompareChecksum CompareMode = "checksum"
+)
+
+// SyncOptions configures Sync
+type SyncOptions struct {
+	// Compare decides which files have changed (default: CompareModTime)
+	Compare CompareMode
+
+	// Delete removes destination files that do not exist on the source
+	Delete bool
+
+	// DryRun reports what would be copied and deleted without changing anything
+	DryRun bool
+
+	// Concurrency is how many files are compared, copied or deleted at once (default: DefaultConcurrency)
+	Concurrency int
+}
+
+// SyncReport describes what Sync did, or would do in a dry run.
+// Paths are relative to the source and destination prefixes.
+type SyncReport struct {
+	// Created holds the files copied because the destination did not have them
+	Created []string
+
+	// Updated holds the files copied because they changed
+	Updated []string
+
+	// Deleted holds the destination files removed because the source does not have them
+	Deleted []string
+
+	// Unchanged is the number of files that were already in sync
+	Unchanged int
+
+	// Bytes is the number of bytes copied
+	Bytes int64
+
+	// Failed holds the files that could not be compared, copied or deleted, with their full paths
+	Failed []ObjectResult
+
+	// DryRun is true when nothing was changed
+	DryRun bool
+}
+
+// syncAction is what Sync does with a single file
+type syncAction int
+
+const (
+	syncUnchanged syncAction = iota
+	syncCreate
+	syncUpdate
+)
+
+// Sync makes the files below dstPrefix on dstDisk match the files below srcPrefix on srcDisk,
+// copying only new and changed files. An empty prefix covers the whole disk.
+// The report is returned even when some files failed, the error is non-nil when listing fails
+// or any file could not be compared, copied or deleted.
+func (s *Storage) Sync(ctx context.Context, srcDisk, srcPrefix, dstDisk, dstPrefix string, opts SyncOptions) (*SyncReport, error) {
+	src := s.getDisk(srcDisk)
+	if src == nil {
+		return nil, ErrDiskNotFound(srcDisk)
+	}
+
+	dst := s.getDisk(dstDisk)
+	if dst == nil {
+		return nil, ErrDiskNotFound(dstDisk)
+	}
+
+	start := time.Now()
+	report, err := s.sync(ctx, src, srcDisk, srcPrefix, dst, dstDisk, dstPrefix, opts)
+	s.logOperation(ctx, "sync", srcDisk+"->"+dstDisk, srcPrefix, start, err)
+	return report, err
+}
+
+// sync compares both listings, then copies and deletes concurrently
+func (s *Storage) sync(ctx context.Context, src Disk, srcDisk, srcPrefix string, dst Disk, dstDisk, dstPrefix string, opts SyncOptions) (*SyncReport, error) {
+	compare := opts.Compare
+	if compare == "" {
+		compare = CompareModTime
+	}
+	if compare != CompareModTime && compare != CompareSize && compare != CompareChecksum {
+		return nil, fmt.Errorf("sync: unknown compare mode %q", compare)
+	}
+
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+
+	srcDir, err := relativePrefix(srcPrefix)
+	if err != nil {
+		return nil, &PathError{Op: "sync", Path: srcPrefix, Err: err}
+	}
+
+	dstDir, err := relativePrefix(dstPrefix)
+	if err != nil {
+		return nil, &PathError{Op: "sync", Path: dstPrefix, Err: err}
+	}
+
+	// Syncing "docs" into "docs/mirror" on the same disk would copy its own copies
+	if srcDisk == dstDisk && (prefixContains(srcDir, dstDir) || prefixContains(dstDir, srcDir)) {
+		return nil, &PathError{Op: "sync", Path: dstPrefix, Err: fmt.Errorf("%w: prefixes overlap", ErrInvalidPath)}
+	}
+
+	srcFiles, err := listFiles(ctx, src, srcDir)
+	if err != nil {
+		return nil, err
+	}
+
+	dstFiles, err := listFiles(ctx, dst, dstDir)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, 0, len(srcFiles))
+	for rel := range srcFiles {
+		paths = append(paths, rel)
+	}
+	sort.Strings(paths)
+
+	actions := make([]syncAction, len(paths))
+	errs := runConcurrently(ctx, len(paths), concurrency, func(i int) error {
+		rel := paths[i]
+		srcPath, dstPath := joinPrefix(srcDir, rel), joinPrefix(dstDir, rel)
+
+		action := syncCreate
+		if dstInfo, ok := dstFiles[rel]; ok {
+			var err error
+			action, err = syncActionFor(ctx, compare, src, srcPath, srcFiles[rel], dst, dstPath, dstInfo)
+			if err != nil {
+				return err
+			}
+		}
+		actions[i] = action
+
+		if action == syncUnchanged || opts.DryRun {
+			return nil
+		}
+
+		if srcDisk == dstDisk {
+			return src.copy(ctx, srcPath, dstPath)
+		}
+		return s.streamBetweenDisks(ctx, src, dst, srcDisk, srcPath, dstPath)
+	})
+
+	report := &SyncReport{DryRun: opts.DryRun}
+	for i, rel := range paths {
+		srcPath, dstPath := joinPrefix(srcDir, rel), joinPrefix(dstDir, rel)
+
+		if errs[i] != nil {
+			report.Failed = append(report.Failed, ObjectResult{Path: dstPath, SourcePath: srcPath, Err: errs[i]})
+			continue
+		}
+
+		switch actions[i] {
+		case syncUnchanged:
+			report.Unchanged++
+			continue
+		case syncCreate:
+			report.Created = append(report.Created, rel)
+		case syncUpdate:
+			report.Updated = append(report.Updated, rel)
+		}
+		report.Bytes += srcFiles[rel].Size
+
+		if !opts.DryRun {
+			s.emit(ctx, Event{
+				Type:       EventCopy,
+				Disk:       dstDisk,
+				Path:       dstPath,
+				SourceDisk: srcDisk,
+				SourcePath: srcPath,
+				Size:       srcFiles[rel].Size,
+				Operation:  "Sync",
+			})
+		}
+	}
+
+	if opts.Delete {
+		s.syncDelete(ctx, dst, dstDisk, dstDir, srcFiles, dstFiles, concurrency, report)
+	}
+
+	if len(report.Failed) > 0 {
+		err := fmt.Errorf("%d files failed, first error: %w", len(report.Failed), report.Failed[0].Err)
+		return report, &PathError{Op: "sync", Path: srcPrefix, Err: err}
+	}
+
+	return report, nil
+}
+
+// streamBetweenDisks copies a file with its metadata and visibility without reading it into memory
+func (s *Storage) streamBetweenDisks(ctx context.Context, src, dst Disk, sourceDisk, sourcePath, destPath string) error {
+	logger := loggerOrDiscard(s.Logger)
+
+	// Get metadata if available, a failure here only loses the metadata
+	metadata, err := src.getMetadata(ctx, sourcePath)
+	if err != nil {
+		logger.WarnContext(ctx, "copying without metadata",
+			slog.String("disk", sourceDisk),
+			slog.String("path", sourcePath),
+			slog.Any("error", err),
+		)
+	}
+
+	reader, err := src.getStream(ctx, sourcePath)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	if err := dst.putStream(ctx, destPath, reader, metadata); err != nil {
+		return err
+	}
+
+	// Keep the visibility, not every disk reports it in the metadata
+	if err := copyVisibility(ctx, src, sourcePath, dst, destPath); err != nil {
+		logger.WarnContext(ctx, "copying without visibility",
+			slog.String("disk", sourceDisk),
+			slog.String("path", sourcePath),
+			slog.Any("error", err),
+		)
+	}
+
+	return nil
+}
+
+// syncDelete deletes the destination files missing from the source and adds them to the report
+func (s *Storage) syncDelete(ctx context.Context, dst Disk, dstDisk, dstDir string, srcFiles, dstFiles map[string]FileInfo, concurrency int, report *SyncReport) {
+	var extraneous []string
+	for rel := range dstFiles {
+		if _, ok := srcFiles[rel]; !ok {
+			extraneous = append(extraneous, rel)
+		}
+	}
+	sort.Strings(extraneous)
+
+	if report.DryRun {
+		report.Deleted = extraneous
+		return
+	}
+
+	full := make([]string, len(extraneous))
+	for i, rel := range extraneous {
+		full[i] = joinPrefix(dstDir, rel)
+	}
+
+	for i, err := range deleteFiles(ctx, dst, full, concurrency) {
+		if err != nil {
+			report.Failed = append(report.Failed, ObjectResult{Path: full[i], Err: err})
+			continue
+		}
+
+		report.Deleted = append(report.Deleted, extraneous[i])
+		s.emit(ctx, Event{Type: EventDelete, Disk: dstDisk, Path: full[i], Size: -1, Operation: "Sync"})
+	}
+}
+
+// syncActionFor decides whether a source file has to be copied over an existing destination file
+func syncActionFor(ctx context.Context, compare CompareMode, src Disk, srcPath string, srcInfo FileInfo, dst Disk, dstPath string, dstInfo FileInfo) (syncAction, error) {
+	if srcInfo.Size != dstInfo.Size {
+		return syncUpdate, nil
+	}
+
+	switch compare {
+	case CompareModTime:
+		if srcInfo.LastModified.After(dstInfo.LastModified) {
+			return syncUpdate, nil
+		}
+	case CompareChecksum:
+		srcSum, err := fileChecksum(ctx, src, srcPath)
+		if err != nil {
+			return syncUnchanged, err
+		}
+		dstSum, err := fileChecksum(ctx, dst, dstPath)
+		if err != nil {
+			return syncUnchanged, err
+		}
+		if !bytes.Equal(srcSum, dstSum) {
+			return syncUpdate, nil
+		}
+	}
+
+	return syncUnchanged, nil
+}
+
+// fileChecksum returns the SHA-256 of a file's content
+func fileChecksum(ctx context.Context, d Disk, path string) ([]byte, error) {
+	reader, err := d.getStream(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, reader); err != nil {
+		return nil, &PathError{Op: "checksum", Path: path, Err: err}
+	}
+
+	return hash.Sum(nil), nil
+}
@@ -0,0 +1,197 @@
+package gostorage
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+// newSyncTestStorage returns a Storage syncing from a memory disk to a local disk
+func newSyncTestStorage(t *testing.T) *Storage {
+	local, err := NewLocalDisk(&LocalDiskConfig{Path: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create LocalDisk: %v", err)
+	}
+
+	storage := NewStorage()
+	storage.AddDisk("memory", NewMemoryDisk())
+	storage.AddDisk("local", local)
+
+	ctx := context.Background()
+	for _, path := range []string{"src/a.txt", "src/sub/b.txt", "src2/c.txt"} {
+		if err := storage.Put(ctx, "memory", path, []byte(path)); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+
+	return storage
+}
+
+func TestStorage_Sync(t *testing.T) {
+	storage := newSyncTestStorage(t)
+	ctx := context.Background()
+
+	if err := storage.Put(ctx, "local", "mirror/stale.txt", []byte("stale")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	// A dry run reports without copying or deleting
+	report, err := storage.Sync(ctx, "memory", "src", "local", "mirror", SyncOptions{Delete: true, DryRun: true})
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if !slices.Equal(report.Created, []string{"a.txt", "sub/b.txt"}) || !slices.Equal(report.Deleted, []string{"stale.txt"}) {
+		t.Errorf("unexpected dry run report: %+v", report)
+	}
+	if exists, _ := storage.Exists(ctx, "local", "mirror/a.txt"); exists {
+		t.Error("dry run copied a file")
+	}
+	if exists, _ := storage.Exists(ctx, "local", "mirror/stale.txt"); !exists {
+		t.Error("dry run deleted a file")
+	}
+
+	report, err = storage.Sync(ctx, "memory", "src", "local", "mirror", SyncOptions{Delete: true})
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if len(report.Created) != 2 || report.Bytes != int64(len("src/a.txt")+len("src/sub/b.txt")) {
+		t.Errorf("unexpected report: %+v", report)
+	}
+	content, err := storage.Get(ctx, "local", "mirror/sub/b.txt")
+	if err != nil || string(content) != "src/sub/b.txt" {
+		t.Errorf("expected synced content, got %q, %v", content, err)
+	}
+	if exists, _ := storage.Exists(ctx, "local", "mirror/stale.txt"); exists {
+		t.Error("extraneous file was not deleted")
+	}
+	if exists, _ := storage.Exists(ctx, "local", "mirror/c.txt"); exists {
+		t.Error("file outside the source prefix was synced")
+	}
+
+	// Nothing changed, nothing is copied
+	report, err = storage.Sync(ctx, "memory", "src", "local", "mirror", SyncOptions{})
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if report.Unchanged != 2 || len(report.Created)+len(report.Updated) != 0 {
+		t.Errorf("expected everything to be unchanged, got %+v", report)
+	}
+
+	if err := storage.Put(ctx, "memory", "src/a.txt", []byte("changed")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	report, err = storage.Sync(ctx, "memory", "src", "local", "mirror", SyncOptions{Compare: CompareSize})
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if !slices.Equal(report.Updated, []string{"a.txt"}) {
+		t.Errorf("expected a.txt to be updated, got %+v", report)
+	}
+}
+
+func TestStorage_Sync_Checksum(t *testing.T) {
+	storage := newSyncTestStorage(t)
+	ctx := context.Background()
+
+	if _, err := storage.Sync(ctx, "memory", "src", "local", "mirror", SyncOptions{}); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	// Same size, different content: only the checksum notices
+	if err := storage.Put(ctx, "local", "mirror/a.txt", []byte("src/a.TXT")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	report, err := storage.Sync(ctx, "memory", "src", "local", "mirror", SyncOptions{Compare: CompareSize})
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if len(report.Updated) != 0 {
+		t.Errorf("expected size comparison to miss the change, got %+v", report)
+	}
+
+	report, err = storage.Sync(ctx, "memory", "src", "local", "mirror", SyncOptions{Compare: CompareChecksum})
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if !slices.Equal(report.Updated, []string{"a.txt"}) || report.Unchanged != 1 {
+		t.Errorf("expected a.txt to be updated, got %+v", report)
+	}
+
+	content, err := storage.Get(ctx, "local", "mirror/a.txt")
+	if err != nil || string(content) != "src/a.txt" {
+		t.Errorf("expected synced content, got %q, %v", content, err)
+	}
+}
+
+func TestStorage_Sync_Validation(t *testing.T) {
+	storage := newSyncTestStorage(t)
+	ctx := context.Background()
+
+	// The whole disk
+	report, err := storage.Sync(ctx, "memory", "", "local", "", SyncOptions{})
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if len(report.Created) != 3 {
+		t.Errorf("expected 3 files to be created, got %+v", report)
+	}
+
+	if _, err := storage.Sync(ctx, "memory", "src", "memory", "src/mirror", SyncOptions{}); !errors.Is(err, ErrInvalidPath) {
+		t.Errorf("expected overlapping prefixes to be rejected, got %v", err)
+	}
+
+	if _, err := storage.Sync(ctx, "memory", "src", "local", "mirror", SyncOptions{Compare: "etag"}); err == nil {
+		t.Error("expected an unknown compare mode to be rejected")
+	}
+
+	if _, err := storage.Sync(ctx, "memory", "src", "missing", "mirror", SyncOptions{}); err == nil {
+		t.Error("expected an error for an unknown disk")
+	}
+}
+
+// streamOnlyDisk is a MemoryDisk that refuses to read whole files into memory
+type streamOnlyDisk struct {
+	*MemoryDisk
+}
+
+func (d *streamOnlyDisk) get(_ context.Context, path string) ([]byte, error) {
+	return nil, &PathError{Op: "get", Path: path, Err: errors.New("files must be streamed")}
+}
+
+func TestStorage_Sync_Streams(t *testing.T) {
+	local, err := NewLocalDisk(&LocalDiskConfig{Path: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create LocalDisk: %v", err)
+	}
+
+	storage := NewStorage()
+	storage.AddDisk("source", &streamOnlyDisk{MemoryDisk: NewMemoryDisk()})
+	storage.AddDisk("local", local)
+
+	ctx := context.Background()
+	meta := &Metadata{ContentType: "text/csv", Visibility: VisibilityPrivate}
+	if err := storage.PutWithMetadata(ctx, "source", "report", []byte("a,b"), meta); err != nil {
+		t.Fatalf("PutWithMetadata failed: %v", err)
+	}
+
+	if _, err := storage.Sync(ctx, "source", "", "local", "", SyncOptions{}); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	content, err := storage.Get(ctx, "local", "report")
+	if err != nil || string(content) != "a,b" {
+		t.Errorf("expected the synced content, got %q, %v", content, err)
+	}
+
+	// Metadata and visibility travel with the content
+	metadata, err := storage.GetMetadata(ctx, "local", "report")
+	if err != nil || metadata == nil || metadata.ContentType != "text/csv" {
+		t.Errorf("expected text/csv, got %+v, %v", metadata, err)
+	}
+	visibility, err := storage.GetVisibility(ctx, "local", "report")
+	if err != nil || visibility != VisibilityPrivate {
+		t.Errorf("expected private, got %q, %v", visibility, err)
+	}
+}
@@ -0,0 +1,492 @@
+package gostorage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// whiteoutPrefix marks a file deleted in the top layer, "docs/.wh.a.txt" hides "docs/a.txt" in lower layers
+const whiteoutPrefix = ".wh."
+
+// UnionDisk implements Disk interface by stacking disks.
+// Writes go to the top layer, reads fall through the layers in order and
+// deleting a file that exists in a lower layer records a whiteout in the top layer.
+// Lower layers are never modified.
+type UnionDisk struct {
+	layers []Disk
+}
+
+// NewUnionDisk creates a new UnionDisk, the first layer is the writable top layer
+func NewUnionDisk(layers ...Disk) (*UnionDisk, error) {
+	if len(layers) == 0 {
+		return nil, errors.New("at least one layer is required")
+	}
+
+	for i, layer := range layers {
+		if layer == nil {
+			return nil, fmt.Errorf("layer %d cannot be nil", i)
+		}
+	}
+
+	return &UnionDisk{
+		layers: layers,
+	}, nil
+}
+
+// top returns the writable layer
+func (d *UnionDisk) top() Disk {
+	return d.layers[0]
+}
+
+// unionPath validates a path and rejects names reserved for whiteouts
+func unionPath(p string) (string, error) {
+	validPath, err := ValidatePath(p)
+	if err != nil {
+		return "", err
+	}
+
+	validPath = filepath.ToSlash(validPath)
+	if strings.HasPrefix(path.Base(validPath), whiteoutPrefix) {
+		return "", fmt.Errorf("%w: names starting with %q are reserved", ErrInvalidPath, whiteoutPrefix)
+	}
+
+	return validPath, nil
+}
+
+// whiteoutPath returns the path of the whiteout hiding p
+func whiteoutPath(p string) string {
+	return path.Join(path.Dir(p), whiteoutPrefix+path.Base(p))
+}
+
+// find returns the layer holding the visible version of a file, or ErrFileNotFound
+func (d *UnionDisk) find(ctx context.Context, validPath string) (Disk, error) {
+	exists, err := d.top().exists(ctx, validPath)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return d.top(), nil
+	}
+
+	// A whiteout hides the file in every lower layer
+	whitedOut, err := d.top().exists(ctx, whiteoutPath(validPath))
+	if err != nil {
+		return nil, err
+	}
+	if whitedOut {
+		return nil, ErrFileNotFound
+	}
+
+	for _, layer := range d.layers[1:] {
+		exists, err := layer.exists(ctx, validPath)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			return layer, nil
+		}
+	}
+
+	return nil, ErrFileNotFound
+}
+
+// inLowerLayer checks if any lower layer holds a file
+func (d *UnionDisk) inLowerLayer(ctx context.Context, validPath string) (bool, error) {
+	for _, layer := range d.layers[1:] {
+		exists, err := layer.exists(ctx, validPath)
+		if err != nil {
+			return false, err
+		}
+		if exists {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// clearWhiteout removes the whiteout of a file that was written to the top layer
+func (d *UnionDisk) clearWhiteout(ctx context.Context, validPath string) error {
+	err := d.top().delete(ctx, whiteoutPath(validPath))
+	if err != nil && !errors.Is(err, ErrFileNotFound) {
+		return err
+	}
+	return nil
+}
+
+// put writes content to the top layer
+func (d *UnionDisk) put(ctx context.Context, path string, content []byte) error {
+	validPath, err := unionPath(path)
+	if err != nil {
+		return &PathError{Op: "put", Path: path, Err: err}
+	}
+
+	if err := d.top().put(ctx, validPath, content); err != nil {
+		return err
+	}
+
+	return d.clearWhiteout(ctx, validPath)
+}
+
+// get reads content from the first layer holding the file
+func (d *UnionDisk) get(ctx context.Context, path string) ([]byte, error) {
+	validPath, err := unionPath(path)
+	if err != nil {
+		return nil, &PathError{Op: "get", Path: path, Err: err}
+	}
+
+	layer, err := d.find(ctx, validPath)
+	if err != nil {
+		return nil, &PathError{Op: "get", Path: path, Err: err}
+	}
+
+	return layer.get(ctx, validPath)
+}
+
+// delete removes a file from the top layer and hides it in lower layers
+func (d *UnionDisk) delete(ctx context.Context, path string) error {
+	validPath, err := unionPath(path)
+	if err != nil {
+		return &PathError{Op: "delete", Path: path, Err: err}
+	}
+
+	if _, err := d.find(ctx, validPath); err != nil {
+		return &PathError{Op: "delete", Path: path, Err: err}
+	}
+
+	if err := d.top().delete(ctx, validPath); err != nil && !errors.Is(err, ErrFileNotFound) {
+		return err
+	}
+
+	lower, err := d.inLowerLayer(ctx, validPath)
+	if err != nil {
+		return &PathError{Op: "delete", Path: path, Err: err}
+	}
+	if lower {
+		if err := d.top().put(ctx, whiteoutPath(validPath), nil); err != nil {
+			return &PathError{Op: "delete", Path: path, Err: err}
+		}
+	}
+
+	return nil
+}
+
+// putStream writes content from a reader to the top layer
+func (d *UnionDisk) putStream(ctx context.Context, path string, reader io.Reader, metadata *Metadata) error {
+	validPath, err := unionPath(path)
+	if err != nil {
+		return &PathError{Op: "putStream", Path: path, Err: err}
+	}
+
+	if err := d.top().putStream(ctx, validPath, reader, metadata); err != nil {
+		return err
+	}
+
+	return d.clearWhiteout(ctx, validPath)
+}
+
+// getStream returns a reader for the file in the first layer holding it
+func (d *UnionDisk) getStream(ctx context.Context, path string) (io.ReadCloser, error) {
+	validPath, err := unionPath(path)
+	if err != nil {
+		return nil, &PathError{Op: "getStream", Path: path, Err: err}
+	}
+
+	layer, err := d.find(ctx, validPath)
+	if err != nil {
+		return nil, &PathError{Op: "getStream", Path: path, Err: err}
+	}
+
+	return layer.getStream(ctx, validPath)
+}
+
+// exists checks if a file is visible in any layer
+func (d *UnionDisk) exists(ctx context.Context, path string) (bool, error) {
+	validPath, err := unionPath(path)
+	if err != nil {
+		return false, &PathError{Op: "exists", Path: path, Err: err}
+	}
+
+	if _, err := d.find(ctx, validPath); err != nil {
+		if errors.Is(err, ErrFileNotFound) {
+			return false, nil
+		}
+		return false, &PathError{Op: "exists", Path: path, Err: err}
+	}
+
+	return true, nil
+}
+
+// size returns the size of the file in the first layer holding it
+func (d *UnionDisk) size(ctx context.Context, path string) (int64, error) {
+	validPath, err := unionPath(path)
+	if err != nil {
+		return 0, &PathError{Op: "size", Path: path, Err: err}
+	}
+
+	layer, err := d.find(ctx, validPath)
+	if err != nil {
+		return 0, &PathError{Op: "size", Path: path, Err: err}
+	}
+
+	return layer.size(ctx, validPath)
+}
+
+// list merges the listings of all layers, upper layers win and whiteouts hide lower entries
+func (d *UnionDisk) list(ctx context.Context, prefix string) ([]FileInfo, error) {
+	// Validate prefix
+	dir, err := relativePrefix(prefix)
+	if err != nil {
+		return nil, &PathError{Op: "list", Path: prefix, Err: err}
+	}
+
+	seen := make(map[string]bool)
+	hidden := make(map[string]bool)
+	var files []FileInfo
+
+	for i, layer := range d.layers {
+		entries, err := layer.list(ctx, prefix)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entry := range entries {
+			p := filepath.ToSlash(entry.Path)
+
+			if name, ok := strings.CutPrefix(path.Base(p), whiteoutPrefix); ok {
+				if i == 0 {
+					hidden[path.Join(path.Dir(p), name)] = true
+				}
+				continue
+			}
+
+			if seen[p] || (i > 0 && hidden[p]) {
+				continue
+			}
+
+			// The top listing only holds whiteouts under the prefix, "docs/a" misses "docs/.wh.a.txt"
+			if i > 0 && !entry.IsDir && !prefixContains(dir, path.Dir(p)) {
+				whitedOut, err := d.top().exists(ctx, whiteoutPath(p))
+				if err != nil {
+					return nil, err
+				}
+				if whitedOut {
+					hidden[p] = true
+					continue
+				}
+			}
+
+			seen[p] = true
+			files = append(files, entry)
+		}
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Path < files[j].Path
+	})
+
+	return files, nil
+}
+
+// copy copies a file from the first layer holding it to the top layer
+func (d *UnionDisk) copy(ctx context.Context, sourcePath, destPath string) error {
+	validSource, err := unionPath(sourcePath)
+	if err != nil {
+		return &PathError{Op: "copy", Path: sourcePath, Err: err}
+	}
+
+	validDest, err := unionPath(destPath)
+	if err != nil {
+		return &PathError{Op: "copy", Path: destPath, Err: err}
+	}
+
+	layer, err := d.find(ctx, validSource)
+	if err != nil {
+		return &PathError{Op: "copy", Path: sourcePath, Err: err}
+	}
+
+	if layer == d.top() {
+		if err := d.top().copy(ctx, validSource, validDest); err != nil {
+			return err
+		}
+		return d.clearWhiteout(ctx, validDest)
+	}
+
+	if err := d.copyUp(ctx, layer, validSource, validDest, nil); err != nil {
+		return &PathError{Op: "copy", Path: sourcePath, Err: err}
+	}
+
+	return nil
+}
+
+// copyUp streams a file from a lower layer into the top layer.
+// The source metadata is kept unless metadata is given.
+func (d *UnionDisk) copyUp(ctx context.Context, layer Disk, sourcePath, destPath string, metadata *Metadata) error {
+	if metadata == nil {
+		// Copying without the metadata would silently lose the content type and custom headers
+		var err error
+		metadata, err = layer.getMetadata(ctx, sourcePath)
+		if err != nil {
+			return err
+		}
+	}
+
+	reader, err := layer.getStream(ctx, sourcePath)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	if err := d.top().putStream(ctx, destPath, reader, metadata); err != nil {
+		return err
+	}
+
+	return d.clearWhiteout(ctx, destPath)
+}
+
+// move copies a file to the top layer and deletes the source
+func (d *UnionDisk) move(ctx context.Context, sourcePath, destPath string) error {
+	if err := d.copy(ctx, sourcePath, destPath); err != nil {
+		return err
+	}
+
+	if err := d.delete(ctx, sourcePath); err != nil {
+		return &PathError{Op: "move", Path: sourcePath, Err: err}
+	}
+
+	return nil
+}
+
+// putWithMetadata writes content and metadata to the top layer
+func (d *UnionDisk) putWithMetadata(ctx context.Context, path string, content []byte, metadata *Metadata) error {
+	validPath, err := unionPath(path)
+	if err != nil {
+		return &PathError{Op: "putWithMetadata", Path: path, Err: err}
+	}
+
+	if err := d.top().putWithMetadata(ctx, validPath, content, metadata); err != nil {
+		return err
+	}
+
+	return d.clearWhiteout(ctx, validPath)
+}
+
+// getMetadata retrieves metadata from the first layer holding the file
+func (d *UnionDisk) getMetadata(ctx context.Context, path string) (*Metadata, error) {
+	validPath, err := unionPath(path)
+	if err != nil {
+		return nil, &PathError{Op: "getMetadata", Path: path, Err: err}
+	}
+
+	layer, err := d.find(ctx, validPath)
+	if err != nil {
+		return nil, &PathError{Op: "getMetadata", Path: path, Err: err}
+	}
+
+	return layer.getMetadata(ctx, validPath)
+}
+
+// setMetadata updates metadata in the top layer, copying the file up from a lower layer first
+func (d *UnionDisk) setMetadata(ctx context.Context, path string, metadata *Metadata) error {
+	validPath, err := unionPath(path)
+	if err != nil {
+		return &PathError{Op: "setMetadata", Path: path, Err: err}
+	}
+
+	layer, err := d.find(ctx, validPath)
+	if err != nil {
+		return &PathError{Op: "setMetadata", Path: path, Err: err}
+	}
+
+	if layer == d.top() {
+		return d.top().setMetadata(ctx, validPath, metadata)
+	}
+
+	if err := d.copyUp(ctx, layer, validPath, validPath, metadata); err != nil {
+		return &PathError{Op: "setMetadata", Path: path, Err: err}
+	}
+
+	return nil
+}
+
+// ping checks every layer
+func (d *UnionDisk) ping(ctx context.Context) error {
+	for i, layer := range d.layers {
+		if err := layer.ping(ctx); err != nil {
+			return fmt.Errorf("layer %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// stat returns file information from the first layer holding the file
+func (d *UnionDisk) stat(ctx context.Context, path string) (*FileInfo, error) {
+	validPath, err := unionPath(path)
+	if err != nil {
+		return nil, &PathError{Op: "stat", Path: path, Err: err}
+	}
+
+	layer, err := d.find(ctx, validPath)
+	if err != nil {
+		return nil, &PathError{Op: "stat", Path: path, Err: err}
+	}
+
+	return statDisk(ctx, layer, validPath)
+}
+
+// setVisibility changes the visibility in the top layer, copying the file up from a lower layer first
+func (d *UnionDisk) setVisibility(ctx context.Context, path string, visibility Visibility) error {
+	validPath, err := unionPath(path)
+	if err != nil {
+		return &PathError{Op: "setVisibility", Path: path, Err: err}
+	}
+
+	layer, err := d.find(ctx, validPath)
+	if err != nil {
+		return &PathError{Op: "setVisibility", Path: path, Err: err}
+	}
+
+	if layer != d.top() {
+		if err := d.copyUp(ctx, layer, validPath, validPath, nil); err != nil {
+			return &PathError{Op: "setVisibility", Path: path, Err: err}
+		}
+	}
+
+	return setDiskVisibility(ctx, d.top(), validPath, visibility)
+}
+
+// getVisibility returns the visibility from the first layer holding the file
+func (d *UnionDisk) getVisibility(ctx context.Context, path string) (Visibility, error) {
+	validPath, err := unionPath(path)
+	if err != nil {
+		return "", &PathError{Op: "getVisibility", Path: path, Err: err}
+	}
+
+	layer, err := d.find(ctx, validPath)
+	if err != nil {
+		return "", &PathError{Op: "getVisibility", Path: path, Err: err}
+	}
+
+	return diskVisibility(ctx, layer, validPath)
+}
+
+// url returns the public URL from the first layer holding the file
+func (d *UnionDisk) url(ctx context.Context, path string) (string, error) {
+	validPath, err := unionPath(path)
+	if err != nil {
+		return "", &PathError{Op: "url", Path: path, Err: err}
+	}
+
+	layer, err := d.find(ctx, validPath)
+	if err != nil {
+		return "", &PathError{Op: "url", Path: path, Err: err}
+	}
+
+	return diskURL(ctx, layer, validPath)
+}
@@ -0,0 +1,192 @@
+package gostorage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"testing/fstest"
+)
+
+// brokenMetadataDisk is a MemoryDisk whose metadata cannot be read
+type brokenMetadataDisk struct {
+	*MemoryDisk
+}
+
+func (d *brokenMetadataDisk) getMetadata(_ context.Context, path string) (*Metadata, error) {
+	return nil, &PathError{Op: "getMetadata", Path: path, Err: errors.New("corrupt metadata")}
+}
+
+func newTestUnionDisk(t *testing.T) (*UnionDisk, *MemoryDisk) {
+	t.Helper()
+
+	template, err := NewFSDisk(fstest.MapFS{
+		"templates/invoice.html": {Data: []byte("shared invoice")},
+		"templates/email.html":   {Data: []byte("shared email")},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create FSDisk: %v", err)
+	}
+
+	tenant := NewMemoryDisk()
+	disk, err := NewUnionDisk(tenant, template)
+	if err != nil {
+		t.Fatalf("Failed to create UnionDisk: %v", err)
+	}
+
+	return disk, tenant
+}
+
+func TestUnionDisk_Overrides(t *testing.T) {
+	ctx := context.Background()
+	disk, tenant := newTestUnionDisk(t)
+
+	// Reads fall through to the lower layer
+	data, err := disk.get(ctx, "templates/invoice.html")
+	if err != nil || string(data) != "shared invoice" {
+		t.Errorf("Expected shared content, got %q, %v", data, err)
+	}
+
+	// Writes go to the top layer and take precedence
+	if err := disk.put(ctx, "templates/invoice.html", []byte("tenant invoice")); err != nil {
+		t.Fatalf("put failed: %v", err)
+	}
+	data, err = disk.get(ctx, "templates/invoice.html")
+	if err != nil || string(data) != "tenant invoice" {
+		t.Errorf("Expected override, got %q, %v", data, err)
+	}
+	if _, err := tenant.get(ctx, "templates/invoice.html"); err != nil {
+		t.Errorf("Expected override in top layer: %v", err)
+	}
+
+	// Listings are merged without duplicates
+	files, err := disk.list(ctx, "templates")
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	count := make(map[string]int)
+	for _, f := range files {
+		count[f.Path]++
+	}
+	if count["templates/invoice.html"] != 1 || count["templates/email.html"] != 1 {
+		t.Errorf("Expected each file exactly once, got %v", count)
+	}
+}
+
+func TestUnionDisk_Whiteouts(t *testing.T) {
+	ctx := context.Background()
+	disk, _ := newTestUnionDisk(t)
+
+	if err := disk.put(ctx, "templates/email.html", []byte("tenant email")); err != nil {
+		t.Fatalf("put failed: %v", err)
+	}
+
+	// Deleting removes the override and hides the shared file
+	if err := disk.delete(ctx, "templates/email.html"); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if exists, _ := disk.exists(ctx, "templates/email.html"); exists {
+		t.Error("File should be hidden after delete")
+	}
+	if _, err := disk.get(ctx, "templates/email.html"); !errors.Is(err, ErrFileNotFound) {
+		t.Errorf("Expected ErrFileNotFound, got %v", err)
+	}
+	if err := disk.delete(ctx, "templates/email.html"); !errors.Is(err, ErrFileNotFound) {
+		t.Errorf("Expected ErrFileNotFound on second delete, got %v", err)
+	}
+
+	files, err := disk.list(ctx, "")
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	for _, f := range files {
+		if f.Path == "templates/email.html" || f.Path == "templates/.wh.email.html" {
+			t.Errorf("Unexpected entry in listing: %s", f.Path)
+		}
+	}
+
+	// Prefixes that are not directories do not reach the whiteout in the top listing
+	for _, prefix := range []string{"templates/email", "templates/email.html"} {
+		files, err := disk.list(ctx, prefix)
+		if err != nil {
+			t.Fatalf("list failed: %v", err)
+		}
+		for _, f := range files {
+			if f.Path == "templates/email.html" {
+				t.Errorf("Unexpected entry in listing of %q: %s", prefix, f.Path)
+			}
+		}
+	}
+
+	// Writing again clears the whiteout
+	if err := disk.put(ctx, "templates/email.html", []byte("again")); err != nil {
+		t.Fatalf("put failed: %v", err)
+	}
+	if err := disk.delete(ctx, "templates/email.html"); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if err := disk.put(ctx, "templates/email.html", []byte("again")); err != nil {
+		t.Fatalf("put failed: %v", err)
+	}
+	data, err := disk.get(ctx, "templates/email.html")
+	if err != nil || string(data) != "again" {
+		t.Errorf("Expected rewritten content, got %q, %v", data, err)
+	}
+
+	// Whiteout names are reserved
+	if err := disk.put(ctx, "templates/.wh.invoice.html", nil); !errors.Is(err, ErrInvalidPath) {
+		t.Errorf("Expected ErrInvalidPath, got %v", err)
+	}
+}
+
+func TestUnionDisk_CopyUp(t *testing.T) {
+	ctx := context.Background()
+	disk, tenant := newTestUnionDisk(t)
+
+	if err := disk.setMetadata(ctx, "templates/invoice.html", &Metadata{ContentType: "text/html"}); err != nil {
+		t.Fatalf("setMetadata failed: %v", err)
+	}
+
+	data, err := tenant.get(ctx, "templates/invoice.html")
+	if err != nil || string(data) != "shared invoice" {
+		t.Errorf("Expected file copied up to top layer, got %q, %v", data, err)
+	}
+	metadata, err := disk.getMetadata(ctx, "templates/invoice.html")
+	if err != nil || metadata.ContentType != "text/html" {
+		t.Errorf("Expected updated metadata, got %+v, %v", metadata, err)
+	}
+
+	// Moving a shared file hides the source
+	if err := disk.move(ctx, "templates/email.html", "archive/email.html"); err != nil {
+		t.Fatalf("move failed: %v", err)
+	}
+	if exists, _ := disk.exists(ctx, "templates/email.html"); exists {
+		t.Error("Source should be hidden after move")
+	}
+	data, err = disk.get(ctx, "archive/email.html")
+	if err != nil || string(data) != "shared email" {
+		t.Errorf("Expected moved content, got %q, %v", data, err)
+	}
+}
+
+func TestUnionDisk_CopyUpKeepsMetadata(t *testing.T) {
+	ctx := context.Background()
+
+	lower := &brokenMetadataDisk{MemoryDisk: NewMemoryDisk()}
+	if err := lower.putWithMetadata(ctx, "shared.txt", []byte("shared"), &Metadata{ContentType: "text/csv"}); err != nil {
+		t.Fatalf("putWithMetadata failed: %v", err)
+	}
+
+	top := NewMemoryDisk()
+	disk, err := NewUnionDisk(top, lower)
+	if err != nil {
+		t.Fatalf("Failed to create UnionDisk: %v", err)
+	}
+
+	// Copying up without the source metadata would lose it silently
+	if err := disk.copy(ctx, "shared.txt", "copy.txt"); err == nil {
+		t.Error("Expected copy to fail when the source metadata cannot be read")
+	}
+	if exists, _ := top.exists(ctx, "copy.txt"); exists {
+		t.Error("Copy should not have been written")
+	}
+}
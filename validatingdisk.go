@@ -0,0 +1,367 @@
+package gostorage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// DefaultReservedNames are device names Windows does not allow as file names, with or without an extension
+var DefaultReservedNames = []string{
+	"CON", "PRN", "AUX", "NUL",
+	"COM1", "COM2", "COM3", "COM4", "COM5", "COM6", "COM7", "COM8", "COM9",
+	"LPT1", "LPT2", "LPT3", "LPT4", "LPT5", "LPT6", "LPT7", "LPT8", "LPT9",
+}
+
+// DefaultFilenamePolicy is used by a ValidatingDisk without a FilenamePolicy
+var DefaultFilenamePolicy = &FilenamePolicy{
+	MaxNameLength: 255,
+	MaxPathLength: 1024,
+	ReservedNames: DefaultReservedNames,
+}
+
+// FilenamePolicy restricts the paths files can be written to
+type FilenamePolicy struct {
+	// MaxNameLength is the maximum length in bytes of each path segment, 0 means no limit
+	MaxNameLength int
+
+	// MaxPathLength is the maximum length in bytes of the whole path, 0 means no limit
+	MaxPathLength int
+
+	// ReservedNames are rejected in any segment regardless of case and extension,
+	// "CON" also rejects "con.txt"
+	ReservedNames []string
+
+	// AllowControlCharacters permits characters such as newlines and tabs in names
+	AllowControlCharacters bool
+}
+
+// Validate returns an InvalidFilenameError when path does not satisfy the policy
+func (p *FilenamePolicy) Validate(path string) error {
+	path = strings.Trim(filepath.ToSlash(path), "/")
+
+	if p.MaxPathLength > 0 && len(path) > p.MaxPathLength {
+		return &InvalidFilenameError{Name: path, Reason: fmt.Sprintf("path is longer than %d bytes", p.MaxPathLength)}
+	}
+
+	for _, name := range strings.Split(path, "/") {
+		if p.MaxNameLength > 0 && len(name) > p.MaxNameLength {
+			return &InvalidFilenameError{Name: name, Reason: fmt.Sprintf("name is longer than %d bytes", p.MaxNameLength)}
+		}
+
+		if !p.AllowControlCharacters && strings.IndexFunc(name, unicode.IsControl) >= 0 {
+			return &InvalidFilenameError{Name: name, Reason: "name contains control characters"}
+		}
+
+		// "con.tar.gz" is as reserved as "con"
+		base, _, _ := strings.Cut(name, ".")
+		base = strings.TrimRight(base, " ")
+		for _, reserved := range p.ReservedNames {
+			if strings.EqualFold(base, reserved) {
+				return &InvalidFilenameError{Name: name, Reason: "name is reserved"}
+			}
+		}
+	}
+
+	return nil
+}
+
+// ValidatingDiskConfig contains configuration for a validating disk wrapper
+type ValidatingDiskConfig struct {
+	// Disk is the disk that stores accepted uploads (required)
+	Disk Disk
+
+	// MaxSize is the maximum size of an upload in bytes (default: 0, no limit)
+	MaxSize int64
+
+	// AllowedContentTypes are the content types that may be uploaded, such as "image/png" or "image/*".
+	// The type is sniffed from the content, the extension and Metadata.ContentType are not trusted (default: any)
+	AllowedContentTypes []string
+
+	// FilenamePolicy restricts the paths files can be written to (default: DefaultFilenamePolicy)
+	FilenamePolicy *FilenamePolicy
+}
+
+// ValidatingDisk wraps a Disk and rejects uploads that break its rules with a FileTooLargeError,
+// ContentTypeNotAllowedError or InvalidFilenameError, all of which match ErrValidationFailed.
+// Streams are checked while they are written, an oversize stream is cut off once it crosses the limit.
+// Copies and moves only check the destination path, their content was validated when it was uploaded.
+type ValidatingDisk struct {
+	disk         Disk
+	maxSize      int64
+	contentTypes []string
+	policy       *FilenamePolicy
+}
+
+// NewValidatingDisk creates a new ValidatingDisk with the given configuration
+func NewValidatingDisk(cfg *ValidatingDiskConfig) (*ValidatingDisk, error) {
+	if cfg == nil {
+		return nil, errors.New("ValidatingDiskConfig cannot be nil")
+	}
+
+	if cfg.Disk == nil {
+		return nil, errors.New("disk is required")
+	}
+
+	if cfg.MaxSize < 0 {
+		return nil, errors.New("max size cannot be negative")
+	}
+
+	policy := cfg.FilenamePolicy
+	if policy == nil {
+		policy = DefaultFilenamePolicy
+	}
+
+	return &ValidatingDisk{
+		disk:         cfg.Disk,
+		maxSize:      cfg.MaxSize,
+		contentTypes: cfg.AllowedContentTypes,
+		policy:       policy,
+	}, nil
+}
+
+// checkPath validates a path against the filename policy
+func (d *ValidatingDisk) checkPath(op string, path string) error {
+	validPath, err := ValidatePath(path)
+	if err != nil {
+		return &PathError{Op: op, Path: path, Err: err}
+	}
+
+	if err := d.policy.Validate(validPath); err != nil {
+		return &PathError{Op: op, Path: path, Err: err}
+	}
+
+	return nil
+}
+
+// checkContent validates the path, size and content type of an upload held in memory
+func (d *ValidatingDisk) checkContent(op string, path string, content []byte) error {
+	if err := d.checkPath(op, path); err != nil {
+		return err
+	}
+
+	if d.maxSize > 0 && int64(len(content)) > d.maxSize {
+		return &PathError{Op: op, Path: path, Err: &FileTooLargeError{Limit: d.maxSize}}
+	}
+
+	return d.checkContentType(op, path, content)
+}
+
+// checkContentType validates the content type sniffed from the first bytes of an upload
+func (d *ValidatingDisk) checkContentType(op string, path string, head []byte) error {
+	if len(d.contentTypes) == 0 {
+		return nil
+	}
+
+	contentType := http.DetectContentType(head)
+	if !matchContentType(contentType, d.contentTypes) {
+		return &PathError{Op: op, Path: path, Err: &ContentTypeNotAllowedError{ContentType: contentType}}
+	}
+
+	return nil
+}
+
+// put validates and writes content
+func (d *ValidatingDisk) put(ctx context.Context, path string, content []byte) error {
+	if err := d.checkContent("put", path, content); err != nil {
+		return err
+	}
+	return d.disk.put(ctx, path, content)
+}
+
+// get reads content
+func (d *ValidatingDisk) get(ctx context.Context, path string) ([]byte, error) {
+	return d.disk.get(ctx, path)
+}
+
+// delete removes a file
+func (d *ValidatingDisk) delete(ctx context.Context, path string) error {
+	return d.disk.delete(ctx, path)
+}
+
+// putStream validates content from a reader while writing it
+func (d *ValidatingDisk) putStream(ctx context.Context, path string, reader io.Reader, metadata *Metadata) error {
+	if err := d.checkPath("putStream", path); err != nil {
+		return err
+	}
+
+	var limited *limitReader
+	if d.maxSize > 0 {
+		// The size of a seekable reader is known up front, other readers are cut off while streaming
+		if size, ok := remainingSize(reader); ok {
+			if size > d.maxSize {
+				return &PathError{Op: "putStream", Path: path, Err: &FileTooLargeError{Limit: d.maxSize}}
+			}
+		} else {
+			limited = &limitReader{reader: reader, limit: d.maxSize}
+			reader = limited
+		}
+	}
+
+	if len(d.contentTypes) > 0 {
+		var head []byte
+		var err error
+		reader, head, err = peek(reader, sniffLen)
+		if err != nil {
+			return &PathError{Op: "putStream", Path: path, Err: err}
+		}
+
+		if err := d.checkContentType("putStream", path, head); err != nil {
+			return err
+		}
+	}
+
+	err := d.disk.putStream(ctx, path, reader, metadata)
+	if limited != nil && limited.err != nil {
+		// Report the limit rather than however the disk wrapped the read error
+		return &PathError{Op: "putStream", Path: path, Err: limited.err}
+	}
+
+	return err
+}
+
+// getStream returns a reader for a file
+func (d *ValidatingDisk) getStream(ctx context.Context, path string) (io.ReadCloser, error) {
+	return d.disk.getStream(ctx, path)
+}
+
+// exists checks if a file exists
+func (d *ValidatingDisk) exists(ctx context.Context, path string) (bool, error) {
+	return d.disk.exists(ctx, path)
+}
+
+// size returns the size of a file
+func (d *ValidatingDisk) size(ctx context.Context, path string) (int64, error) {
+	return d.disk.size(ctx, path)
+}
+
+// list returns a list of files matching a prefix
+func (d *ValidatingDisk) list(ctx context.Context, prefix string) ([]FileInfo, error) {
+	return d.disk.list(ctx, prefix)
+}
+
+// copy copies a file if the destination path is valid
+func (d *ValidatingDisk) copy(ctx context.Context, sourcePath, destPath string) error {
+	if err := d.checkPath("copy", destPath); err != nil {
+		return err
+	}
+	return d.disk.copy(ctx, sourcePath, destPath)
+}
+
+// move moves a file if the destination path is valid
+func (d *ValidatingDisk) move(ctx context.Context, sourcePath, destPath string) error {
+	if err := d.checkPath("move", destPath); err != nil {
+		return err
+	}
+	return d.disk.move(ctx, sourcePath, destPath)
+}
+
+// putWithMetadata validates and writes content together with metadata
+func (d *ValidatingDisk) putWithMetadata(ctx context.Context, path string, content []byte, metadata *Metadata) error {
+	if err := d.checkContent("putWithMetadata", path, content); err != nil {
+		return err
+	}
+	return d.disk.putWithMetadata(ctx, path, content, metadata)
+}
+
+// getMetadata retrieves metadata for a file
+func (d *ValidatingDisk) getMetadata(ctx context.Context, path string) (*Metadata, error) {
+	return d.disk.getMetadata(ctx, path)
+}
+
+// setMetadata updates metadata for a file
+func (d *ValidatingDisk) setMetadata(ctx context.Context, path string, metadata *Metadata) error {
+	return d.disk.setMetadata(ctx, path, metadata)
+}
+
+// ping checks the underlying disk
+func (d *ValidatingDisk) ping(ctx context.Context) error {
+	return d.disk.ping(ctx)
+}
+
+// stat returns file information from the underlying disk
+func (d *ValidatingDisk) stat(ctx context.Context, path string) (*FileInfo, error) {
+	return statDisk(ctx, d.disk, path)
+}
+
+// limitReader fails with a FileTooLargeError as soon as more than limit bytes have been read
+type limitReader struct {
+	reader io.Reader
+	limit  int64
+	n      int64
+	err    error
+}
+
+func (r *limitReader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+
+	n, err := r.reader.Read(p)
+	r.n += int64(n)
+	if r.n > r.limit {
+		r.err = &FileTooLargeError{Limit: r.limit}
+		return 0, r.err
+	}
+
+	return n, err
+}
+
+// remainingSize returns the number of bytes left in a seekable reader
+func remainingSize(reader io.Reader) (int64, bool) {
+	seeker, ok := reader.(io.Seeker)
+	if !ok {
+		return 0, false
+	}
+
+	current, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, false
+	}
+
+	end, err := seeker.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, false
+	}
+
+	if _, err := seeker.Seek(current, io.SeekStart); err != nil {
+		return 0, false
+	}
+
+	return end - current, true
+}
+
+// setVisibility changes the visibility of a file
+func (d *ValidatingDisk) setVisibility(ctx context.Context, path string, visibility Visibility) error {
+	return setDiskVisibility(ctx, d.disk, path, visibility)
+}
+
+// getVisibility returns the visibility of a file
+func (d *ValidatingDisk) getVisibility(ctx context.Context, path string) (Visibility, error) {
+	return diskVisibility(ctx, d.disk, path)
+}
+
+// url returns the public URL of a file
+func (d *ValidatingDisk) url(ctx context.Context, path string) (string, error) {
+	return diskURL(ctx, d.disk, path)
+}
+
+// deleteMany deletes files, in batches if the underlying disk supports it
+func (d *ValidatingDisk) deleteMany(ctx context.Context, paths []string, concurrency int) []error {
+	return deleteFiles(ctx, d.disk, paths, concurrency)
+}
+
+// typeDetector returns the detector of the underlying disk
+func (d *ValidatingDisk) typeDetector() *ContentTypeDetector {
+	return diskDetector(d.disk)
+}
+
+// removeDirectory removes an empty directory
+func (d *ValidatingDisk) removeDirectory(ctx context.Context, path string) error {
+	return removeDiskDirectory(ctx, d.disk, path)
+}
@@ -0,0 +1,195 @@
+package gostorage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// endlessReader yields zero bytes forever and counts how many were read
+type endlessReader struct {
+	n int64
+}
+
+func (r *endlessReader) Read(p []byte) (int, error) {
+	clear(p)
+	r.n += int64(len(p))
+	return len(p), nil
+}
+
+func TestValidatingDisk_MaxSize(t *testing.T) {
+	local, err := NewLocalDisk(&LocalDiskConfig{Path: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create LocalDisk: %v", err)
+	}
+
+	disk, err := NewValidatingDisk(&ValidatingDiskConfig{Disk: local, MaxSize: 1024})
+	if err != nil {
+		t.Fatalf("Failed to create ValidatingDisk: %v", err)
+	}
+
+	ctx := context.Background()
+
+	if err := disk.put(ctx, "small.bin", make([]byte, 1024)); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	var tooLarge *FileTooLargeError
+	err = disk.put(ctx, "large.bin", make([]byte, 1025))
+	if !errors.As(err, &tooLarge) || tooLarge.Limit != 1024 {
+		t.Errorf("expected FileTooLargeError, got %v", err)
+	}
+
+	// A seekable stream is rejected before anything is written
+	err = disk.putStream(ctx, "seekable.bin", bytes.NewReader(make([]byte, 2048)), nil)
+	if !errors.Is(err, ErrValidationFailed) {
+		t.Errorf("expected ErrValidationFailed, got %v", err)
+	}
+
+	// An endless stream is cut off right after the limit
+	endless := &endlessReader{}
+	err = disk.putStream(ctx, "endless.bin", endless, nil)
+	if !errors.As(err, &tooLarge) {
+		t.Fatalf("expected FileTooLargeError, got %v", err)
+	}
+	if endless.n > 64*1024 {
+		t.Errorf("expected the stream to be cut off early, read %d bytes", endless.n)
+	}
+
+	for _, path := range []string{"large.bin", "seekable.bin", "endless.bin"} {
+		exists, err := local.exists(ctx, path)
+		if err != nil {
+			t.Fatalf("Exists failed: %v", err)
+		}
+		if exists {
+			t.Errorf("%s should not have been written", path)
+		}
+	}
+}
+
+func TestValidatingDisk_ContentTypes(t *testing.T) {
+	disk, err := NewValidatingDisk(&ValidatingDiskConfig{
+		Disk:                NewMemoryDisk(),
+		AllowedContentTypes: []string{"image/*", "application/pdf"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create ValidatingDisk: %v", err)
+	}
+
+	ctx := context.Background()
+
+	if err := disk.put(ctx, "avatar.png", pngHeader); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := disk.putStream(ctx, "invoice.pdf", strings.NewReader("%PDF-1.7 ..."), nil); err != nil {
+		t.Fatalf("PutStream failed: %v", err)
+	}
+
+	// The extension and the given content type are not trusted
+	var notAllowed *ContentTypeNotAllowedError
+	err = disk.putWithMetadata(ctx, "avatar.png", []byte("<html><script>"), &Metadata{ContentType: "image/png"})
+	if !errors.As(err, &notAllowed) || notAllowed.ContentType != "text/html; charset=utf-8" {
+		t.Errorf("expected ContentTypeNotAllowedError, got %v", err)
+	}
+
+	err = disk.putStream(ctx, "photo.jpg", io.MultiReader(strings.NewReader("#!/bin/sh\n")), nil)
+	if !errors.As(err, &notAllowed) {
+		t.Errorf("expected ContentTypeNotAllowedError, got %v", err)
+	}
+
+	// The sniffed bytes are still written
+	content, err := disk.get(ctx, "invoice.pdf")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if string(content) != "%PDF-1.7 ..." {
+		t.Errorf("unexpected content: %q", content)
+	}
+}
+
+func TestFilenamePolicy_Validate(t *testing.T) {
+	tests := []struct {
+		path  string
+		valid bool
+	}{
+		{"docs/report.pdf", true},
+		{"docs/console.txt", true},
+		{"docs/CON", false},
+		{"con.tar.gz/file.txt", false},
+		{"lpt1 .txt", false},
+		{"docs/line\nbreak.txt", false},
+		{"docs/tab\t.txt", false},
+		{strings.Repeat("a", 256), false},
+		{strings.Repeat("a/", 600) + "file", false},
+	}
+
+	for _, tt := range tests {
+		err := DefaultFilenamePolicy.Validate(tt.path)
+		if tt.valid && err != nil {
+			t.Errorf("Validate(%q) failed: %v", tt.path, err)
+		}
+		if !tt.valid && !errors.Is(err, ErrInvalidPath) {
+			t.Errorf("Validate(%q) = %v, expected an invalid path", tt.path, err)
+		}
+	}
+
+	disk, err := NewValidatingDisk(&ValidatingDiskConfig{Disk: NewMemoryDisk()})
+	if err != nil {
+		t.Fatalf("Failed to create ValidatingDisk: %v", err)
+	}
+
+	ctx := context.Background()
+	if err := disk.put(ctx, "a.txt", []byte("a")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	var invalid *InvalidFilenameError
+	if err := disk.copy(ctx, "a.txt", "nul.txt"); !errors.As(err, &invalid) {
+		t.Errorf("expected InvalidFilenameError, got %v", err)
+	}
+}
+
+func TestValidatingDisk_RejectedReplacement(t *testing.T) {
+	local, err := NewLocalDisk(&LocalDiskConfig{Path: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create LocalDisk: %v", err)
+	}
+
+	disk, err := NewValidatingDisk(&ValidatingDiskConfig{Disk: local, MaxSize: 10})
+	if err != nil {
+		t.Fatalf("Failed to create ValidatingDisk: %v", err)
+	}
+
+	ctx := context.Background()
+
+	meta := &Metadata{ContentType: "text/plain", CustomHeaders: map[string]string{"owner": "me"}}
+	if err := disk.putWithMetadata(ctx, "a.txt", []byte("original"), meta); err != nil {
+		t.Fatalf("PutWithMetadata failed: %v", err)
+	}
+
+	// An oversize replacement is rejected without touching the original
+	err = disk.putStream(ctx, "a.txt", io.MultiReader(strings.NewReader(strings.Repeat("x", 64))), nil)
+	if !errors.Is(err, ErrValidationFailed) {
+		t.Fatalf("expected ErrValidationFailed, got %v", err)
+	}
+
+	data, err := local.get(ctx, "a.txt")
+	if err != nil || string(data) != "original" {
+		t.Errorf("expected the original content, got %q, %v", data, err)
+	}
+	metadata, err := local.getMetadata(ctx, "a.txt")
+	if err != nil || metadata == nil || metadata.CustomHeaders["owner"] != "me" {
+		t.Errorf("expected the original metadata, got %+v, %v", metadata, err)
+	}
+
+	files, err := local.list(ctx, "")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(files) != 1 {
+		t.Errorf("expected only a.txt, got %+v", files)
+	}
+}
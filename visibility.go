@@ -0,0 +1,165 @@
+package gostorage
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Visibility controls whether a file can be read without credentials.
+// Files written without a visibility are public on local and memory disks. S3 objects get no canned
+// ACL unless a visibility is given, so they follow the bucket's settings and are private by default;
+// forcing public-read would expose every upload and fails on buckets with ACLs disabled.
+// Copies keep the visibility of their source, within an S3 bucket public objects are copied with public-read.
+type Visibility string
+
+const (
+	// VisibilityPublic files can be read by anyone, e.g. through Storage.URL
+	VisibilityPublic Visibility = "public"
+
+	// VisibilityPrivate files can only be read through the disk
+	VisibilityPrivate Visibility = "private"
+)
+
+// visibilityDisk is implemented by disks that can make files public or private
+type visibilityDisk interface {
+	setVisibility(ctx context.Context, path string, visibility Visibility) error
+	getVisibility(ctx context.Context, path string) (Visibility, error)
+}
+
+// urlDisk is implemented by disks that can build public URLs for their files
+type urlDisk interface {
+	url(ctx context.Context, path string) (string, error)
+}
+
+// SetVisibility makes a file public or private.
+// S3 applies a canned ACL, local disks change the file permissions.
+func (s *Storage) SetVisibility(ctx context.Context, disk string, path string, visibility Visibility) error {
+	d := s.getDisk(disk)
+	if d == nil {
+		return ErrDiskNotFound(disk)
+	}
+
+	start := time.Now()
+	err := setDiskVisibility(ctx, d, path, visibility)
+	s.logOperation(ctx, "setVisibility", disk, path, start, err)
+	return err
+}
+
+// GetVisibility reports whether a file is public or private
+func (s *Storage) GetVisibility(ctx context.Context, disk string, path string) (Visibility, error) {
+	d := s.getDisk(disk)
+	if d == nil {
+		return "", ErrDiskNotFound(disk)
+	}
+
+	start := time.Now()
+	visibility, err := diskVisibility(ctx, d, path)
+	s.logOperation(ctx, "getVisibility", disk, path, start, err)
+	return visibility, err
+}
+
+// URL returns the permanent public URL of a file, built from the disk's PublicBaseURL.
+// The file is not checked, it has to be public for the URL to work.
+func (s *Storage) URL(ctx context.Context, disk string, path string) (string, error) {
+	d := s.getDisk(disk)
+	if d == nil {
+		return "", ErrDiskNotFound(disk)
+	}
+
+	start := time.Now()
+	u, err := diskURL(ctx, d, path)
+	s.logOperation(ctx, "url", disk, path, start, err)
+	return u, err
+}
+
+// setDiskVisibility sets the visibility of a file on disks that support it
+func setDiskVisibility(ctx context.Context, d Disk, path string, visibility Visibility) error {
+	if visibility != VisibilityPublic && visibility != VisibilityPrivate {
+		return &PathError{Op: "setVisibility", Path: path, Err: fmt.Errorf("unknown visibility %q", visibility)}
+	}
+
+	v, ok := d.(visibilityDisk)
+	if !ok {
+		return &PathError{Op: "setVisibility", Path: path, Err: ErrOperationNotSupported}
+	}
+
+	return v.setVisibility(ctx, path, visibility)
+}
+
+// diskVisibility returns the visibility of a file on disks that support it
+func diskVisibility(ctx context.Context, d Disk, path string) (Visibility, error) {
+	v, ok := d.(visibilityDisk)
+	if !ok {
+		return "", &PathError{Op: "getVisibility", Path: path, Err: ErrOperationNotSupported}
+	}
+
+	return v.getVisibility(ctx, path)
+}
+
+// copyVisibility gives a copied file the visibility of its source when both disks support
+// visibility and the copy ended up with another one
+func copyVisibility(ctx context.Context, src Disk, sourcePath string, dst Disk, destPath string) error {
+	if _, ok := src.(visibilityDisk); !ok {
+		return nil
+	}
+	if _, ok := dst.(visibilityDisk); !ok {
+		return nil
+	}
+
+	want, err := diskVisibility(ctx, src, sourcePath)
+	if err != nil {
+		return err
+	}
+
+	got, err := diskVisibility(ctx, dst, destPath)
+	if err != nil || got == want {
+		return err
+	}
+
+	return setDiskVisibility(ctx, dst, destPath, want)
+}
+
+// diskURL returns the public URL of a file on disks that support it
+func diskURL(ctx context.Context, d Disk, path string) (string, error) {
+	u, ok := d.(urlDisk)
+	if !ok {
+		return "", &PathError{Op: "url", Path: path, Err: ErrOperationNotSupported}
+	}
+
+	return u.url(ctx, path)
+}
+
+// validateBaseURL checks that a public base URL is absolute
+func validateBaseURL(baseURL string) error {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return fmt.Errorf("invalid public base URL: %w", err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("invalid public base URL %q: scheme and host are required", baseURL)
+	}
+	return nil
+}
+
+// publicURL joins a base URL and a validated path, escaping every path segment
+func publicURL(baseURL string, validPath string) (string, error) {
+	if baseURL == "" {
+		return "", fmt.Errorf("%w: no public base URL configured", ErrOperationNotSupported)
+	}
+
+	return strings.TrimSuffix(baseURL, "/") + "/" + escapeKey(filepath.ToSlash(validPath)), nil
+}
+
+// escapeKey escapes every segment of a key for use in a URL path.
+// "+" is escaped too, some servers including S3 decode it as a space.
+func escapeKey(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = strings.ReplaceAll(url.PathEscape(segment), "+", "%2B")
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,197 @@
+package gostorage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func TestStorage_Visibility(t *testing.T) {
+	tmpDir := t.TempDir()
+	local, err := NewLocalDisk(&LocalDiskConfig{Path: tmpDir})
+	if err != nil {
+		t.Fatalf("Failed to create LocalDisk: %v", err)
+	}
+
+	storage := NewStorage()
+	storage.AddDisk("memory", NewMemoryDisk())
+	storage.AddDisk("local", local)
+
+	ctx := context.Background()
+
+	for _, disk := range []string{"memory", "local"} {
+		err := storage.PutWithMetadata(ctx, disk, "a.txt", []byte("a"), &Metadata{Visibility: VisibilityPrivate})
+		if err != nil {
+			t.Fatalf("PutWithMetadata failed: %v", err)
+		}
+
+		visibility, err := storage.GetVisibility(ctx, disk, "a.txt")
+		if err != nil {
+			t.Fatalf("GetVisibility failed: %v", err)
+		}
+		if visibility != VisibilityPrivate {
+			t.Errorf("%s: expected private, got %q", disk, visibility)
+		}
+
+		if err := storage.SetVisibility(ctx, disk, "a.txt", VisibilityPublic); err != nil {
+			t.Fatalf("SetVisibility failed: %v", err)
+		}
+
+		visibility, err = storage.GetVisibility(ctx, disk, "a.txt")
+		if err != nil {
+			t.Fatalf("GetVisibility failed: %v", err)
+		}
+		if visibility != VisibilityPublic {
+			t.Errorf("%s: expected public, got %q", disk, visibility)
+		}
+
+		err = storage.SetVisibility(ctx, disk, "missing.txt", VisibilityPublic)
+		if !errors.Is(err, ErrFileNotFound) {
+			t.Errorf("%s: expected ErrFileNotFound, got %v", disk, err)
+		}
+
+		err = storage.SetVisibility(ctx, disk, "a.txt", "world-writable")
+		if err == nil {
+			t.Errorf("%s: expected an unknown visibility to be rejected", disk)
+		}
+	}
+
+	// Local files map visibility to permissions, the sidecar does not keep it
+	if err := storage.SetVisibility(ctx, "local", "a.txt", VisibilityPrivate); err != nil {
+		t.Fatalf("SetVisibility failed: %v", err)
+	}
+	info, err := os.Stat(filepath.Join(tmpDir, "a.txt"))
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("expected mode 0600, got %v", info.Mode().Perm())
+	}
+
+	// Wrappers pass visibility through, disks without it report ErrOperationNotSupported
+	scoped, err := storage.Scope("memory", "public")
+	if err != nil {
+		t.Fatalf("Scope failed: %v", err)
+	}
+	if err := scoped.Put(ctx, "memory", "b.txt", []byte("b")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := scoped.SetVisibility(ctx, "memory", "b.txt", VisibilityPublic); err != nil {
+		t.Fatalf("SetVisibility failed: %v", err)
+	}
+	visibility, err := storage.GetVisibility(ctx, "memory", "public/b.txt")
+	if err != nil {
+		t.Fatalf("GetVisibility failed: %v", err)
+	}
+	if visibility != VisibilityPublic {
+		t.Errorf("expected public, got %q", visibility)
+	}
+
+	fsDisk, err := NewFSDisk(fstest.MapFS{"c.txt": {Data: []byte("c")}})
+	if err != nil {
+		t.Fatalf("Failed to create FSDisk: %v", err)
+	}
+	storage.AddDisk("fs", fsDisk)
+	if _, err := storage.GetVisibility(ctx, "fs", "c.txt"); !errors.Is(err, ErrOperationNotSupported) {
+		t.Errorf("expected ErrOperationNotSupported, got %v", err)
+	}
+}
+
+func TestStorage_URL(t *testing.T) {
+	local, err := NewLocalDisk(&LocalDiskConfig{
+		Path:          t.TempDir(),
+		PublicBaseURL: "https://static.example.com/files/",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create LocalDisk: %v", err)
+	}
+
+	unconfigured, err := NewLocalDisk(&LocalDiskConfig{Path: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create LocalDisk: %v", err)
+	}
+
+	storage := NewStorage()
+	storage.AddDisk("local", local)
+	storage.AddDisk("unconfigured", unconfigured)
+
+	ctx := context.Background()
+
+	u, err := storage.URL(ctx, "local", "/reports/Q1 2024/a+b#1.pdf")
+	if err != nil {
+		t.Fatalf("URL failed: %v", err)
+	}
+	if want := "https://static.example.com/files/reports/Q1%202024/a%2Bb%231.pdf"; u != want {
+		t.Errorf("expected %s, got %s", want, u)
+	}
+
+	if _, err := storage.URL(ctx, "local", "../secret"); err == nil {
+		t.Error("expected traversal to be rejected")
+	}
+
+	if _, err := storage.URL(ctx, "unconfigured", "a.txt"); !errors.Is(err, ErrOperationNotSupported) {
+		t.Errorf("expected ErrOperationNotSupported, got %v", err)
+	}
+
+	scoped, err := storage.Scope("local", "tenant-1")
+	if err != nil {
+		t.Fatalf("Scope failed: %v", err)
+	}
+	u, err = scoped.URL(ctx, "local", "logo.png")
+	if err != nil {
+		t.Fatalf("URL failed: %v", err)
+	}
+	if want := "https://static.example.com/files/tenant-1/logo.png"; u != want {
+		t.Errorf("expected %s, got %s", want, u)
+	}
+}
+
+func TestStorage_VisibilityOfCopies(t *testing.T) {
+	local, err := NewLocalDisk(&LocalDiskConfig{Path: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create LocalDisk: %v", err)
+	}
+
+	storage := NewStorage()
+	storage.AddDisk("memory", NewMemoryDisk())
+	storage.AddDisk("local", local)
+
+	ctx := context.Background()
+
+	for _, disk := range []string{"memory", "local"} {
+		// Every disk defaults to public
+		if err := storage.Put(ctx, disk, "public.txt", []byte("a")); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+		visibility, err := storage.GetVisibility(ctx, disk, "public.txt")
+		if err != nil || visibility != VisibilityPublic {
+			t.Errorf("%s: expected public by default, got %q, %v", disk, visibility, err)
+		}
+
+		// Copies keep the visibility of their source, also when it changed after writing
+		if err := storage.Put(ctx, disk, "private.txt", []byte("b")); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+		if err := storage.SetVisibility(ctx, disk, "private.txt", VisibilityPrivate); err != nil {
+			t.Fatalf("SetVisibility failed: %v", err)
+		}
+		if err := storage.Copy(ctx, disk, "private.txt", "copy.txt"); err != nil {
+			t.Fatalf("Copy failed: %v", err)
+		}
+		visibility, err = storage.GetVisibility(ctx, disk, "copy.txt")
+		if err != nil || visibility != VisibilityPrivate {
+			t.Errorf("%s: expected the copy to be private, got %q, %v", disk, visibility, err)
+		}
+	}
+
+	if err := storage.CopyBetweenDisks(ctx, "memory", "local", "private.txt", "from-memory.txt"); err != nil {
+		t.Fatalf("CopyBetweenDisks failed: %v", err)
+	}
+	visibility, err := storage.GetVisibility(ctx, "local", "from-memory.txt")
+	if err != nil || visibility != VisibilityPrivate {
+		t.Errorf("expected the copy between disks to be private, got %q, %v", visibility, err)
+	}
+}
@@ -0,0 +1,142 @@
+package gostorage
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// DefaultWatchInterval is the polling interval used to watch disks without native change notifications
+const DefaultWatchInterval = 10 * time.Second
+
+// ChangeType identifies the kind of change reported by Watch
+type ChangeType string
+
+const (
+	// ChangeCreated is reported when a file appears
+	ChangeCreated ChangeType = "created"
+
+	// ChangeModified is reported when the content of a file changes
+	ChangeModified ChangeType = "modified"
+
+	// ChangeDeleted is reported when a file disappears
+	ChangeDeleted ChangeType = "deleted"
+)
+
+// ChangeEvent describes a change to a file on a watched disk
+type ChangeEvent struct {
+	Type         ChangeType
+	Path         string
+	Size         int64
+	LastModified time.Time
+}
+
+// watcher is implemented by disks that can report changes themselves
+type watcher interface {
+	watch(ctx context.Context, prefix string) (<-chan ChangeEvent, error)
+}
+
+// Watch reports files created, modified and deleted under prefix until ctx is cancelled.
+// Disks without native notifications are polled every DefaultWatchInterval.
+// The returned channel is closed when watching stops.
+func (s *Storage) Watch(ctx context.Context, disk string, prefix string) (<-chan ChangeEvent, error) {
+	d := s.getDisk(disk)
+	if d == nil {
+		return nil, ErrDiskNotFound(disk)
+	}
+
+	if w, ok := d.(watcher); ok {
+		return w.watch(ctx, prefix)
+	}
+
+	return pollChanges(ctx, d, prefix, DefaultWatchInterval, loggerOrDiscard(s.Logger))
+}
+
+// pollChanges watches a disk by listing it periodically and diffing the listings.
+// Files present when watching starts are not reported.
+func pollChanges(ctx context.Context, d Disk, prefix string, interval time.Duration, logger *slog.Logger) (<-chan ChangeEvent, error) {
+	previous, err := snapshot(ctx, d, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make(chan ChangeEvent)
+
+	go func() {
+		defer close(changes)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			current, err := snapshot(ctx, d, prefix)
+			if err != nil {
+				// Keep the previous snapshot so the changes are reported on the next successful poll
+				logger.WarnContext(ctx, "polling for changes failed",
+					slog.String("prefix", prefix),
+					slog.Any("error", err),
+				)
+				continue
+			}
+
+			for _, event := range diffSnapshots(previous, current) {
+				select {
+				case changes <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			previous = current
+		}
+	}()
+
+	return changes, nil
+}
+
+// snapshot lists the files under prefix keyed by path
+func snapshot(ctx context.Context, d Disk, prefix string) (map[string]FileInfo, error) {
+	files, err := d.list(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]FileInfo, len(files))
+	for _, file := range files {
+		if file.IsDir {
+			continue
+		}
+		result[file.Path] = file
+	}
+
+	return result, nil
+}
+
+// diffSnapshots returns the changes needed to go from previous to current
+func diffSnapshots(previous, current map[string]FileInfo) []ChangeEvent {
+	var events []ChangeEvent
+
+	for path, file := range current {
+		old, existed := previous[path]
+		switch {
+		case !existed:
+			events = append(events, ChangeEvent{Type: ChangeCreated, Path: path, Size: file.Size, LastModified: file.LastModified})
+		case old.Size != file.Size || !old.LastModified.Equal(file.LastModified):
+			events = append(events, ChangeEvent{Type: ChangeModified, Path: path, Size: file.Size, LastModified: file.LastModified})
+		}
+	}
+
+	for path, file := range previous {
+		if _, exists := current[path]; !exists {
+			events = append(events, ChangeEvent{Type: ChangeDeleted, Path: path, Size: file.Size, LastModified: file.LastModified})
+		}
+	}
+
+	return events
+}
@@ -0,0 +1,172 @@
+package gostorage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// nextChange waits for a change event or fails the test
+func nextChange(t *testing.T, changes <-chan ChangeEvent) ChangeEvent {
+	t.Helper()
+
+	select {
+	case change, ok := <-changes:
+		if !ok {
+			t.Fatal("Change channel closed unexpectedly")
+		}
+		return change
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for change")
+	}
+	return ChangeEvent{}
+}
+
+func TestStorage_WatchMemoryDisk(t *testing.T) {
+	storage := NewStorage()
+	storage.AddDisk("memory", NewMemoryDisk())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changes, err := storage.Watch(ctx, "memory", "inbox/")
+	if err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+
+	go func() {
+		_ = storage.Put(ctx, "memory", "other/ignored.txt", []byte("x"))
+		_ = storage.Put(ctx, "memory", "inbox/a.txt", []byte("one"))
+		_ = storage.Put(ctx, "memory", "inbox/a.txt", []byte("two"))
+		_ = storage.Delete(ctx, "memory", "inbox/a.txt")
+	}()
+
+	expected := []ChangeType{ChangeCreated, ChangeModified, ChangeDeleted}
+	for _, want := range expected {
+		change := nextChange(t, changes)
+		if change.Type != want || change.Path != "inbox/a.txt" {
+			t.Errorf("Expected %s inbox/a.txt, got %s %s", want, change.Type, change.Path)
+		}
+	}
+
+	cancel()
+	for range changes {
+	}
+}
+
+func TestMemoryDisk_WatchDoesNotBlockWriters(t *testing.T) {
+	disk := NewMemoryDisk()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// A watcher that never reads must not stall writes
+	if _, err := disk.watch(ctx, ""); err != nil {
+		t.Fatalf("watch failed: %v", err)
+	}
+
+	changes, err := disk.watch(ctx, "inbox/")
+	if err != nil {
+		t.Fatalf("watch failed: %v", err)
+	}
+
+	if err := disk.put(ctx, "inbox/a.txt", []byte("raw")); err != nil {
+		t.Fatalf("put failed: %v", err)
+	}
+
+	// Reacting to a change by writing under the watched prefix
+	change := nextChange(t, changes)
+	if err := disk.put(ctx, "inbox/a.processed", []byte("done")); err != nil {
+		t.Fatalf("put from watcher failed: %v", err)
+	}
+	if ctx.Err() != nil {
+		t.Fatal("Expected put from watcher to return without waiting for it")
+	}
+	if change.Path != "inbox/a.txt" {
+		t.Errorf("Expected inbox/a.txt, got %s", change.Path)
+	}
+	if change := nextChange(t, changes); change.Path != "inbox/a.processed" {
+		t.Errorf("Expected inbox/a.processed, got %s", change.Path)
+	}
+}
+
+func TestLocalDisk_Watch(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	disk, err := NewLocalDisk(&LocalDiskConfig{Path: tmpDir})
+	if err != nil {
+		t.Fatalf("Failed to create LocalDisk: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changes, err := disk.watch(ctx, "")
+	if err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+
+	// Files in new subdirectories are picked up as well
+	if err := os.MkdirAll(filepath.Join(tmpDir, "partner", "drop"), 0755); err != nil {
+		t.Fatalf("Mkdir failed: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if err := os.WriteFile(filepath.Join(tmpDir, "partner", "drop", "file.csv"), []byte("a,b"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	change := nextChange(t, changes)
+	if change.Type != ChangeCreated || change.Path != "partner/drop/file.csv" {
+		t.Errorf("Expected created partner/drop/file.csv, got %s %s", change.Type, change.Path)
+	}
+
+	if err := os.Remove(filepath.Join(tmpDir, "partner", "drop", "file.csv")); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+
+	for {
+		change = nextChange(t, changes)
+		if change.Type == ChangeDeleted {
+			break
+		}
+	}
+	if change.Path != "partner/drop/file.csv" {
+		t.Errorf("Expected deleted partner/drop/file.csv, got %s", change.Path)
+	}
+}
+
+func TestDiffSnapshots(t *testing.T) {
+	now := time.Now()
+
+	previous := map[string]FileInfo{
+		"same.txt":    {Path: "same.txt", Size: 1, LastModified: now},
+		"changed.txt": {Path: "changed.txt", Size: 1, LastModified: now},
+		"removed.txt": {Path: "removed.txt", Size: 1, LastModified: now},
+	}
+	current := map[string]FileInfo{
+		"same.txt":    {Path: "same.txt", Size: 1, LastModified: now},
+		"changed.txt": {Path: "changed.txt", Size: 2, LastModified: now.Add(time.Second)},
+		"added.txt":   {Path: "added.txt", Size: 1, LastModified: now},
+	}
+
+	got := make(map[string]ChangeType)
+	for _, change := range diffSnapshots(previous, current) {
+		got[change.Path] = change.Type
+	}
+
+	expected := map[string]ChangeType{
+		"changed.txt": ChangeModified,
+		"removed.txt": ChangeDeleted,
+		"added.txt":   ChangeCreated,
+	}
+	if len(got) != len(expected) {
+		t.Fatalf("Expected %d changes, got %v", len(expected), got)
+	}
+	for path, want := range expected {
+		if got[path] != want {
+			t.Errorf("Expected %s for %s, got %s", want, path, got[path])
+		}
+	}
+}